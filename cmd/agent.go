@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/junler/sysinfo/internal/fleet"
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+// agentTokenEnv holds the agent token when --token isn't given, keeping it
+// out of the process list
+const agentTokenEnv = "SYSINFO_AGENT_TOKEN"
+
+var agent fleet.Agent
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Push snapshots of this host to a central server",
+	Long: `Collect system information and open ports every --interval and push them
+to a central "sysinfo serve --central" server, which shows them under
+/api/nodes.
+
+Snapshots are sent gzip compressed. While the server is unreachable they
+are kept in memory, up to --buffer of them, and delivered in order once it
+is back, so the server's history of the node has no gap; retries back off
+up to 5 minutes. The token, from --token or $SYSINFO_AGENT_TOKEN, must be
+one of the server's --agent-token values. An https server is verified, and
+a client certificate presented, with the same --remote-ca, --remote-cert,
+--remote-key and --remote-insecure flags as --remote.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := sysinfo.ResolveCollectors(collectorNames); err != nil {
+			return err
+		}
+		u, err := url.Parse(agent.Server)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("--server must be an http or https URL, got %q", agent.Server)
+		}
+		if agent.Interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		if agent.Token == "" {
+			agent.Token = os.Getenv(agentTokenEnv)
+		}
+		if agent.Client, err = remoteOptions.HTTPClient(); err != nil {
+			return err
+		}
+		if agent.Node == "" {
+			if agent.Node, err = os.Hostname(); err != nil {
+				return fmt.Errorf("--node is required when the hostname is unknown: %w", err)
+			}
+		}
+
+		sysinfo.StartCPUSampler(sysinfo.DefaultSampleInterval)
+		defer sysinfo.StopCPUSampler()
+
+		agent.Version = Version
+		agent.Collect = func(ctx context.Context) (fleet.Snapshot, error) {
+			return fleet.CollectSnapshot(ctx, collectOptions())
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		log.Printf("Pushing snapshots of %s to %s every %s", agent.Node, agent.Server, agent.Interval)
+		agent.Run(ctx)
+		return nil
+	},
+}
+
+func init() {
+	agentCmd.Flags().StringVar(&agent.Server, "server", "", "URL of the central sysinfo server, e.g. http://central:8080")
+	agentCmd.Flags().StringVar(&agent.Token, "token", "", "Token to register with the server (default $"+agentTokenEnv+")")
+	agentCmd.Flags().StringVar(&agent.Node, "node", "", "Name of this node on the server (default the hostname)")
+	agentCmd.Flags().StringToStringVar(&agent.Labels, "label", nil, "Label to attach to this node, e.g. --label env=prod (repeatable)")
+	agentCmd.Flags().DurationVar(&agent.Interval, "interval", fleet.DefaultAgentInterval, "Interval between snapshots")
+	agentCmd.Flags().IntVar(&agent.Buffer, "buffer", fleet.DefaultAgentBuffer, "Snapshots kept while the server is unreachable")
+	agentCmd.MarkFlagRequired("server")
+	addCollectorsFlag(agentCmd)
+	rootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/junler/sysinfo/internal/alert"
+	"github.com/junler/sysinfo/internal/history"
+	"github.com/junler/sysinfo/internal/remote"
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	alertRulesPath string
+	alertsServer   string
+)
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "List alerts from a rules file or a running server",
+	Long: `List alerts.
+
+With --rules the rules are evaluated once against the current system state;
+rules with a "for" duration show as pending since they haven't been watched
+long enough to fire. With --server the alerts tracked by a running
+"sysinfo serve --alert-rules" are listed instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			alerts []alert.Alert
+			err    error
+		)
+		switch {
+		case alertsServer != "":
+			alerts, err = fetchAlerts(cmd.Context(), alertsServer)
+		case alertRulesPath != "":
+			alerts, err = evaluateRules(cmd.Context(), alertRulesPath)
+		default:
+			return fmt.Errorf("either --rules or --server is required")
+		}
+		if err != nil {
+			return &exitError{exitFailure, err}
+		}
+
+		if outputFormat != outputTable {
+			return writeRows(cmd.OutOrStdout(), alerts)
+		}
+		printAlerts(cmd.OutOrStdout(), alerts)
+		return nil
+	},
+}
+
+// evaluateRules checks a rules file against one sample of the system
+func evaluateRules(ctx context.Context, path string) ([]alert.Alert, error) {
+	rules, err := alert.LoadRules(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rate metrics need a second sample to compare against
+	needRates := false
+	for _, r := range rules {
+		metric := r.Metric()
+		needRates = needRates || strings.HasPrefix(metric, "net.") || strings.HasPrefix(metric, "diskio.")
+	}
+	opts := sysinfo.Options{Timeout: collectTimeout, Collectors: history.RecordedCollectors}
+	info, err := sysinfo.GetSystemInfoContext(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	var rates *sysinfo.Rates
+	if needRates {
+		start := time.Now()
+		time.Sleep(time.Second)
+		cur, err := sysinfo.GetSystemInfoContext(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		r := sysinfo.NewRates(info, cur, time.Since(start))
+		info, rates = cur, &r
+	}
+
+	values := history.Samples(info, rates)
+	for _, r := range rules {
+		if !r.HasData(values) {
+			fmt.Fprintf(os.Stderr, "Warning: rule %s: no data for %s\n", r.Name, r.Metric())
+		}
+	}
+	engine := alert.NewEngine(rules)
+	engine.Evaluate(time.Now(), values)
+	return engine.Alerts(), nil
+}
+
+// fetchAlerts lists the alerts of a running server
+func fetchAlerts(ctx context.Context, server string) ([]alert.Alert, error) {
+	client, err := remote.NewClient(server, remoteConnOptions())
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Alerts []alert.Alert `json:"alerts"`
+	}
+	if _, err := client.Get(ctx, "/api/alerts", &body); err != nil {
+		var status *remote.StatusError
+		if errors.As(err, &status) && status.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s has no alert rules configured (start serve with --alert-rules)", server)
+		}
+		return nil, err
+	}
+	return body.Alerts, nil
+}
+
+func printAlerts(w io.Writer, alerts []alert.Alert) {
+	fmt.Fprintln(w, "=== Alerts ===")
+	if len(alerts) == 0 {
+		fmt.Fprintln(w, "No active alerts.")
+		return
+	}
+	fmt.Fprintf(w, "%-9s %-9s %-20s %-15s %10s %10s  %s\n", "STATE", "SEVERITY", "RULE", "INSTANCE", "VALUE", "ACTIVE", "SUMMARY")
+	fmt.Fprintln(w, strings.Repeat("-", 90))
+	firing := 0
+	for _, a := range alerts {
+		end := time.Now()
+		if a.ResolvedAt != nil {
+			end = *a.ResolvedAt
+		}
+		summary := a.Summary
+		if summary == "" {
+			summary = a.Expr
+		}
+		fmt.Fprintf(w, "%-9s %-9s %-20s %-15s %10.2f %10s  %s\n",
+			a.State, a.Severity, a.Rule, a.Instance, a.Value, end.Sub(a.Since).Truncate(time.Second), summary)
+		if a.State == alert.StateFiring {
+			firing++
+		}
+	}
+	fmt.Fprintf(w, "\nTotal: %d alerts, %d firing\n", len(alerts), firing)
+}
+
+func init() {
+	alertsCmd.Flags().StringVar(&alertRulesPath, "rules", "", "Alert rules file to evaluate against this host")
+	alertsCmd.Flags().StringVar(&alertsServer, "server", "", "URL of a running sysinfo server to list alerts from, e.g. http://host:8080 (connects with the --remote-token and TLS flags)")
+	rootCmd.AddCommand(alertsCmd)
+}
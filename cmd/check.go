@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/junler/sysinfo/internal/check"
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkWarning  string
+	checkCritical string
+	checkMounts   []string
+	checkProcess  string
+	checkPort     string
+	checkProtocol string
+)
+
+// checkTargets maps each check target to the function that runs it
+var checkTargets = map[string]func(ctx context.Context) (check.Result, error){
+	"cpu":     checkCPU,
+	"load":    checkLoad,
+	"memory":  checkMemory,
+	"swap":    checkSwap,
+	"disk":    checkDisk,
+	"inodes":  checkInodes,
+	"process": checkProcessRunning,
+	"port":    checkPortListening,
+	"users":   checkUsers,
+}
+
+func checkTargetNames() []string {
+	names := make([]string, 0, len(checkTargets))
+	for name := range checkTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check <target>",
+	Short: "Run a Nagios compatible check",
+	Long: `Run a check in the format of a Nagios/Icinga plugin: one status line with
+performance data, and exit code 0 (OK), 1 (WARNING), 2 (CRITICAL) or
+3 (UNKNOWN).
+
+Targets:
+  cpu       average CPU usage in percent
+  load      1, 5 and 15 minute load average; thresholds take up to three
+            comma separated ranges, e.g. -w 4,3,2
+  memory    memory usage in percent
+  swap      swap usage in percent
+  disk      usage in percent of each mount, or only those given by --mount
+  inodes    inode usage in percent of each mount, or only those given by --mount
+  process   number of processes named --name, critical below 1 by default
+  port      number of sockets listening on --port, critical below 1 by default
+  users     number of logged in sessions
+
+Thresholds use the Nagios range syntax: "10" alerts outside 0..10, "10:"
+below 10, "~:10" above 10, "10:20" outside 10..20 and "@10:20" inside it.`,
+	Example: `  sysinfo check disk --mount / --mount /var -w 80 -c 90
+  sysinfo check load -w 8,6,4 -c 16,12,8
+  sysinfo check process --name nginx -c 1:
+  sysinfo check port --port 443 --protocol tcp`,
+	ValidArgs:    checkTargetNames(),
+	SilenceUsage: true,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)(cmd, args); err != nil {
+			return checkUsageError(cmd, fmt.Errorf("%w (targets: %s)", err, strings.Join(checkTargetNames(), ", ")))
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(cmd.Context(), collectTimeout)
+		defer cancel()
+		result, err := checkTargets[args[0]](ctx)
+		if err != nil {
+			result = check.Unknownf(args[0], "%v", err)
+		}
+
+		w := cmd.OutOrStdout()
+		if outputFormat != outputTable {
+			if err := writeDocument(w, result); err != nil {
+				return &exitError{int(check.Unknown), err}
+			}
+		} else {
+			fmt.Fprintln(w, result)
+		}
+		if result.Status != check.OK {
+			// The status line already says what is wrong
+			return &exitError{int(result.Status), nil}
+		}
+		return nil
+	},
+}
+
+// checkUsageError reports a usage mistake as an UNKNOWN result, so that
+// the monitoring system shows it instead of an empty status
+func checkUsageError(cmd *cobra.Command, err error) error {
+	fmt.Fprintln(cmd.OutOrStdout(), check.Unknownf("check", "%v", err))
+	return &exitError{int(check.Unknown), nil}
+}
+
+// checkThresholds parses --warning and --critical, falling back to
+// defaultCritical when neither is given
+func checkThresholds(defaultCritical string) (check.Thresholds, error) {
+	if checkWarning == "" && checkCritical == "" {
+		return check.ParseThresholds("", defaultCritical)
+	}
+	return check.ParseThresholds(checkWarning, checkCritical)
+}
+
+// collectSection collects a single section and fails if it couldn't be
+func collectSection(ctx context.Context, section string) (*sysinfo.SystemInfo, error) {
+	info, err := sysinfo.GetSystemInfoContext(ctx, sysinfo.Options{Timeout: collectTimeout, Collectors: []string{section}})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range info.Errors {
+		if e.Section == section {
+			return nil, e
+		}
+	}
+	return info, nil
+}
+
+// checkPercent checks a single percentage
+func checkPercent(name string, percent float64, message string, extra ...check.Perf) (check.Result, error) {
+	t, err := checkThresholds("")
+	if err != nil {
+		return check.Result{}, err
+	}
+	perf := append([]check.Perf{check.NewPerf(name, percent, "%", t).WithRange(0, 100)}, extra...)
+	return check.Result{Name: name, Status: t.Status(percent), Message: message, Perfdata: perf}, nil
+}
+
+func checkCPU(ctx context.Context) (check.Result, error) {
+	info, err := collectSection(ctx, sysinfo.SectionCPU)
+	if err != nil {
+		return check.Result{}, err
+	}
+	if len(info.CPU.Usage) == 0 {
+		return check.Result{}, fmt.Errorf("CPU usage unavailable")
+	}
+	var total float64
+	for _, u := range info.CPU.Usage {
+		total += u
+	}
+	usage := total / float64(len(info.CPU.Usage))
+	return checkPercent("cpu", usage, fmt.Sprintf("%.1f%% used on %d cores", usage, len(info.CPU.Usage)))
+}
+
+func checkLoad(ctx context.Context) (check.Result, error) {
+	warning, err := check.ParseRanges(checkWarning, 3)
+	if err != nil {
+		return check.Result{}, err
+	}
+	critical, err := check.ParseRanges(checkCritical, 3)
+	if err != nil {
+		return check.Result{}, err
+	}
+	info, err := collectSection(ctx, sysinfo.SectionLoad)
+	if err != nil {
+		return check.Result{}, err
+	}
+
+	load := info.LoadAverage
+	values := []float64{load.Load1, load.Load5, load.Load15}
+	labels := []string{"load1", "load5", "load15"}
+	result := check.Result{Name: "load", Message: fmt.Sprintf("load average %.2f, %.2f, %.2f", load.Load1, load.Load5, load.Load15)}
+	for i, v := range values {
+		t := check.Thresholds{Warning: warning[i], Critical: critical[i]}
+		result.Status = check.Worst(result.Status, t.Status(v))
+		result.Perfdata = append(result.Perfdata, check.NewPerf(labels[i], v, "", t).WithMin(0))
+	}
+	return result, nil
+}
+
+func checkMemory(ctx context.Context) (check.Result, error) {
+	info, err := collectSection(ctx, sysinfo.SectionMemory)
+	if err != nil {
+		return check.Result{}, err
+	}
+	m := info.Memory
+	return checkPercent("memory", m.UsedPercent,
+		fmt.Sprintf("%.1f%% used (%s of %s)", m.UsedPercent, humanBytes(float64(m.Used)), humanBytes(float64(m.Total))),
+		check.NewPerf("memory_used", float64(m.Used), "B", check.Thresholds{}).WithRange(0, float64(m.Total)))
+}
+
+func checkSwap(ctx context.Context) (check.Result, error) {
+	info, err := collectSection(ctx, sysinfo.SectionSwap)
+	if err != nil {
+		return check.Result{}, err
+	}
+	s := info.Swap
+	message := fmt.Sprintf("%.1f%% used (%s of %s)", s.UsedPercent, humanBytes(float64(s.Used)), humanBytes(float64(s.Total)))
+	if s.Total == 0 {
+		message = "no swap configured"
+	}
+	return checkPercent("swap", s.UsedPercent, message,
+		check.NewPerf("swap_used", float64(s.Used), "B", check.Thresholds{}).WithRange(0, float64(s.Total)))
+}
+
+func checkDisk(ctx context.Context) (check.Result, error) {
+	return checkMountUsage(ctx, "disk", func(d sysinfo.DiskInfo) (float64, bool) {
+		return d.UsedPercent, d.Total > 0
+	})
+}
+
+func checkInodes(ctx context.Context) (check.Result, error) {
+	return checkMountUsage(ctx, "inodes", func(d sysinfo.DiskInfo) (float64, bool) {
+		if d.InodesTotal == 0 {
+			return 0, false
+		}
+		return float64(d.InodesUsed) / float64(d.InodesTotal) * 100, true
+	})
+}
+
+// checkMountUsage checks a percentage of each mount, or of the mounts given
+// by --mount. usage returns false for mounts the percentage doesn't apply to.
+func checkMountUsage(ctx context.Context, name string, usage func(sysinfo.DiskInfo) (float64, bool)) (check.Result, error) {
+	t, err := checkThresholds("")
+	if err != nil {
+		return check.Result{}, err
+	}
+	info, err := collectSection(ctx, sysinfo.SectionDisk)
+	if err != nil {
+		return check.Result{}, err
+	}
+
+	disks := make(map[string]sysinfo.DiskInfo)
+	for _, d := range info.Disk {
+		disks[d.Mountpoint] = d
+	}
+	mounts := checkMounts
+	if len(mounts) == 0 {
+		for _, d := range info.Disk {
+			mounts = append(mounts, d.Mountpoint)
+		}
+	}
+
+	result := check.Result{Name: name}
+	var problems []string
+	var highest string
+	highestUsage := -1.0
+	for _, mount := range mounts {
+		d, ok := disks[mount]
+		if !ok {
+			return check.Result{}, fmt.Errorf("%s is not a mountpoint", mount)
+		}
+		used, ok := usage(d)
+		if !ok {
+			continue
+		}
+		status := t.Status(used)
+		result.Status = check.Worst(result.Status, status)
+		result.Perfdata = append(result.Perfdata, check.NewPerf(mount, used, "%", t).WithRange(0, 100))
+		if status != check.OK {
+			problems = append(problems, fmt.Sprintf("%s %.1f%% used", mount, used))
+		}
+		if used > highestUsage {
+			highest, highestUsage = mount, used
+		}
+	}
+
+	switch {
+	case len(result.Perfdata) == 0:
+		return check.Result{}, fmt.Errorf("no mounts to check")
+	case len(problems) > 0:
+		result.Message = strings.Join(problems, ", ")
+	default:
+		result.Message = fmt.Sprintf("%d mounts, highest %.1f%% used on %s", len(result.Perfdata), highestUsage, highest)
+	}
+	return result, nil
+}
+
+func checkProcessRunning(ctx context.Context) (check.Result, error) {
+	if checkProcess == "" {
+		return check.Result{}, fmt.Errorf("--name is required")
+	}
+	t, err := checkThresholds("1:")
+	if err != nil {
+		return check.Result{}, err
+	}
+	processes, err := sysinfo.GetProcesses(ctx)
+	if err != nil {
+		return check.Result{}, err
+	}
+
+	count := 0
+	for _, p := range processes {
+		if p.Name == checkProcess {
+			count++
+		}
+	}
+	return check.Result{
+		Name:     "process",
+		Status:   t.Status(float64(count)),
+		Message:  fmt.Sprintf("%d processes named %s", count, checkProcess),
+		Perfdata: []check.Perf{check.NewPerf("procs", float64(count), "", t).WithMin(0)},
+	}, nil
+}
+
+func checkPortListening(ctx context.Context) (check.Result, error) {
+	if checkPort == "" {
+		return check.Result{}, fmt.Errorf("--port is required")
+	}
+	protocol := strings.ToUpper(checkProtocol)
+	if protocol != "" && protocol != "TCP" && protocol != "UDP" {
+		return check.Result{}, fmt.Errorf("unknown protocol %q (want tcp or udp)", checkProtocol)
+	}
+	t, err := checkThresholds("1:")
+	if err != nil {
+		return check.Result{}, err
+	}
+	ports, err := sysinfo.GetOpenPorts()
+	if err != nil {
+		return check.Result{}, err
+	}
+
+	count := 0
+	var processes []string
+	for _, p := range ports {
+		if p.Port != checkPort || (protocol != "" && p.Protocol != protocol) {
+			continue
+		}
+		count++
+		if p.Process != "" && p.Process != "unknown" && !contains(processes, p.Process) {
+			processes = append(processes, p.Process)
+		}
+	}
+
+	port := checkPort
+	if protocol != "" {
+		port += "/" + protocol
+	}
+	message := fmt.Sprintf("port %s is not listening", port)
+	if count > 0 {
+		message = fmt.Sprintf("port %s is listening", port)
+		if len(processes) > 0 {
+			message += " (" + strings.Join(processes, ", ") + ")"
+		}
+	}
+	return check.Result{
+		Name:     "port",
+		Status:   t.Status(float64(count)),
+		Message:  message,
+		Perfdata: []check.Perf{check.NewPerf("listeners", float64(count), "", t).WithMin(0)},
+	}, nil
+}
+
+func checkUsers(ctx context.Context) (check.Result, error) {
+	t, err := checkThresholds("")
+	if err != nil {
+		return check.Result{}, err
+	}
+	info, err := collectSection(ctx, sysinfo.SectionUsers)
+	if err != nil {
+		return check.Result{}, err
+	}
+	count := len(info.Users)
+	return check.Result{
+		Name:     "users",
+		Status:   t.Status(float64(count)),
+		Message:  fmt.Sprintf("%d users logged in", count),
+		Perfdata: []check.Perf{check.NewPerf("users", float64(count), "", t).WithMin(0)},
+	}, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	checkCmd.Flags().StringVarP(&checkWarning, "warning", "w", "", "Warning threshold range")
+	checkCmd.Flags().StringVarP(&checkCritical, "critical", "c", "", "Critical threshold range")
+	checkCmd.Flags().StringSliceVar(&checkMounts, "mount", nil, "Mountpoint to check with disk and inodes (repeatable, all by default)")
+	checkCmd.Flags().StringVar(&checkProcess, "name", "", "Process name to count with process")
+	checkCmd.Flags().StringVar(&checkPort, "port", "", "Port number to look for with port")
+	checkCmd.Flags().StringVar(&checkProtocol, "protocol", "", "Protocol to look for with port: tcp or udp (both by default)")
+	checkCmd.SetFlagErrorFunc(checkUsageError)
+	rootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+// defaultForecastHorizon is how soon a mount has to be predicted to fill
+// for it to be flagged
+const defaultForecastHorizon = 7 * 24 * time.Hour
+
+var forecastHorizon time.Duration
+
+var diskCmd = &cobra.Command{
+	Use:   "disk",
+	Short: "Disk usage tools",
+}
+
+var diskForecastCmd = &cobra.Command{
+	Use:   "forecast",
+	Short: "Predict when each mount fills up",
+	Long: `Predict when each mount runs out of space and inodes from its growth.
+
+Every run of "sysinfo disk forecast", and of "sysinfo info" given a
+--forecast-state, records the usage of each mount in the --forecast-state
+file, so running either periodically (e.g. from cron) builds up the
+history the forecast is fitted to; samples
+closer than 20 minutes apart are skipped and a week is kept. The
+confidence shows how well the growth follows a straight line, reduced when
+the prediction reaches further ahead than the history goes back.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		forecaster := enableDiskForecast(cmd)
+		if forecaster == nil {
+			return fmt.Errorf("--forecast-state is required to forecast")
+		}
+		opts := sysinfo.Options{Timeout: collectTimeout, Collectors: []string{sysinfo.SectionDisk}}
+		info, err := sysinfo.GetSystemInfoContext(cmd.Context(), opts)
+		if err == nil && info.SectionFailed(sysinfo.SectionDisk) {
+			err = info.Errors[0]
+		}
+		if err != nil {
+			return &exitError{exitFailure, err}
+		}
+		for _, w := range info.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w.Error())
+		}
+
+		forecasts := forecaster.Forecast(info.Disk)
+		if outputFormat != outputTable {
+			return writeRows(cmd.OutOrStdout(), forecasts)
+		}
+		printForecasts(cmd.OutOrStdout(), forecasts)
+		return nil
+	},
+}
+
+// defaultForecastState is the --forecast-state file of disk forecast
+func defaultForecastState() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "sysinfo", "disk-forecast.json")
+	}
+	return ""
+}
+
+// addForecastFlags registers the disk forecast flags on a command, keeping
+// samples in state by default. --forecast-state is read back from the
+// command as its default differs between commands.
+func addForecastFlags(cmd *cobra.Command, state string) {
+	cmd.Flags().String("forecast-state", state, "File disk usage samples are kept in for forecasting (empty disables forecasting)")
+	cmd.Flags().DurationVar(&forecastHorizon, "forecast-horizon", defaultForecastHorizon, "Flag mounts predicted to fill within this time (0 disables)")
+}
+
+// enableDiskForecast makes collections record disk usage into the
+// --forecast-state file of cmd and returns the forecaster, nil when disabled
+func enableDiskForecast(cmd *cobra.Command) *sysinfo.DiskForecaster {
+	state, _ := cmd.Flags().GetString("forecast-state")
+	if state == "" {
+		return nil
+	}
+	f := sysinfo.NewDiskForecaster(sysinfo.DefaultForecastWindow, state)
+	sysinfo.SetDiskForecaster(f)
+	return f
+}
+
+// fillsWithin reports whether a predicted fill time is within the horizon
+func fillsWithin(full *time.Time, horizon time.Duration) bool {
+	return full != nil && horizon > 0 && time.Until(*full) <= horizon
+}
+
+// formatETA formats the time left until t, e.g. "3d 4h"
+func formatETA(t time.Time) string {
+	d := time.Until(t)
+	switch {
+	case d <= 0:
+		return "now"
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
+
+func printForecasts(w io.Writer, forecasts []sysinfo.DiskForecast) {
+	fmt.Fprintln(w, "=== Disk Forecast ===")
+	fmt.Fprintf(w, "  %-30s %6s %12s %-18s %5s %-18s %5s %7s\n",
+		"MOUNT", "USED%", "GROWTH/DAY", "FULL AT", "CONF", "INODES FULL AT", "CONF", "SAMPLES")
+	fmt.Fprintln(w, strings.Repeat("-", 112))
+	// Flag the same predictions info does
+	soon := func(full *time.Time, confidence float64) bool {
+		return confidence >= sysinfo.MinForecastConfidence && fillsWithin(full, forecastHorizon)
+	}
+	flagged, sparse := 0, 0
+	for _, fc := range forecasts {
+		mark := " "
+		if soon(fc.PredictedFullAt, fc.Confidence) || soon(fc.InodesPredictedFullAt, fc.InodesConfidence) {
+			mark = "*"
+			flagged++
+		}
+		if fc.PredictedFullAt == nil && fc.Samples < 3 {
+			sparse++
+		}
+		growth := humanBytes(fc.GrowthPerDay)
+		if fc.GrowthPerDay < 0 {
+			growth = "-" + humanBytes(-fc.GrowthPerDay)
+		}
+		fmt.Fprintf(w, "%s %-30s %5.1f%% %12s %-18s %5s %-18s %5s %7d\n",
+			mark, truncateString(fc.Mountpoint, 30), fc.UsedPercent, growth,
+			formatFullAt(fc.PredictedFullAt), formatConfidence(fc.PredictedFullAt, fc.Confidence),
+			formatFullAt(fc.InodesPredictedFullAt), formatConfidence(fc.InodesPredictedFullAt, fc.InodesConfidence),
+			fc.Samples)
+	}
+
+	if flagged > 0 {
+		fmt.Fprintf(w, "\n* %d mounts predicted to fill within %s with at least %.0f%% confidence\n",
+			flagged, forecastHorizon, sysinfo.MinForecastConfidence*100)
+	}
+	if sparse > 0 {
+		fmt.Fprintln(w, "\nForecasts need at least 3 samples: run this periodically, e.g. from cron.")
+	}
+}
+
+func formatFullAt(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func formatConfidence(t *time.Time, confidence float64) string {
+	if t == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", confidence*100)
+}
+
+func init() {
+	addForecastFlags(diskForecastCmd, defaultForecastState())
+	diskCmd.AddCommand(diskForecastCmd)
+	rootCmd.AddCommand(diskCmd)
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/junler/sysinfo/internal/fleet"
+	"github.com/junler/sysinfo/internal/remote"
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fleetNodesPath string
+	fleetWorkers   int
+	fleetWhere     []string
+)
+
+// fleetInfoCollectors are all "fleet info" needs from each node
+var fleetInfoCollectors = []string{sysinfo.SectionHost, sysinfo.SectionCPU, sysinfo.SectionMemory, sysinfo.SectionLoad, sysinfo.SectionDisk}
+
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Query many sysinfo servers at once",
+	Long: `Query every "sysinfo serve" listed in a nodes file concurrently and merge
+the results into one table with a host column.
+
+The nodes file has one node per line, either a URL or a name and a URL;
+lines starting with # are comments. Rows can be narrowed with --where
+field<op>value, where op is one of = != > >= < <= or ~ (contains) and
+fields are the json column names, e.g. --where port=5432 or
+--where 'worst_mount_percent>90'. Repeated --where filters must all match.
+
+Nodes that can't be queried are listed separately; the exit code is 2 when
+some nodes failed and 1 when all did. --remote-token and the other
+--remote-* TLS flags apply to every node.`,
+}
+
+var fleetInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Summarize every node",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rows, failures, err := queryFleet(cmd.Context(), func(ctx context.Context, host string, c *remote.Client) ([]fleetInfoRow, error) {
+			info, collectedAt, err := c.Info(ctx, fleetInfoCollectors)
+			if err != nil {
+				return nil, err
+			}
+			sum := fleet.Summarize(fleet.Snapshot{CollectedAt: collectedAt, Info: info})
+			return []fleetInfoRow{{
+				Host:              host,
+				Hostname:          sum.Hostname,
+				OS:                sum.OS,
+				Uptime:            sum.Uptime,
+				CPUPercent:        sum.CPUPercent,
+				MemoryPercent:     sum.MemoryPercent,
+				LoadAverageInfo:   sum.LoadAverage,
+				WorstMount:        sum.WorstMount,
+				WorstMountPercent: sum.WorstMountPercent,
+				ProcessCount:      sum.ProcessCount,
+				Problems:          sum.Problems,
+			}}, nil
+		})
+		if err != nil {
+			return err
+		}
+		return writeFleet(cmd.OutOrStdout(), rows, failures, func(w io.Writer) {
+			fmt.Fprintf(w, "%-16s %-20s %7s %7s %20s %-15s %7s %8s %s\n",
+				"HOST", "HOSTNAME", "CPU%", "MEM%", "LOAD (1/5/15)", "FULLEST MOUNT", "USE%", "PROCS", "UPTIME")
+			fmt.Fprintln(w, strings.Repeat("-", 120))
+			for _, r := range rows {
+				fmt.Fprintf(w, "%-16s %-20s %6.1f%% %6.1f%% %6.2f %6.2f %6.2f %-15s %6.1f%% %8d %s\n",
+					truncateString(r.Host, 16), truncateString(r.Hostname, 20), r.CPUPercent, r.MemoryPercent,
+					r.Load1, r.Load5, r.Load15, truncateString(r.WorstMount, 15), r.WorstMountPercent,
+					r.ProcessCount, r.Uptime)
+			}
+		})
+	},
+}
+
+var fleetPortsCmd = &cobra.Command{
+	Use:   "ports",
+	Short: "List the open ports of every node",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rows, failures, err := queryFleet(cmd.Context(), func(ctx context.Context, host string, c *remote.Client) ([]fleetPortRow, error) {
+			ports, err := c.Ports(ctx)
+			rows := make([]fleetPortRow, len(ports))
+			for i, p := range ports {
+				rows[i] = fleetPortRow{Host: host, PortInfo: p}
+			}
+			return rows, err
+		})
+		if err != nil {
+			return err
+		}
+		return writeFleet(cmd.OutOrStdout(), rows, failures, func(w io.Writer) {
+			fmt.Fprintf(w, "%-16s %-8s %-10s %-10s %-20s %-8s %-15s\n", "HOST", "PORT", "PROTOCOL", "STATUS", "PROCESS", "PID", "ADDRESS")
+			fmt.Fprintln(w, strings.Repeat("-", 90))
+			for _, r := range rows {
+				pidStr := "N/A"
+				if r.PID != 0 {
+					pidStr = fmt.Sprintf("%d", r.PID)
+				}
+				fmt.Fprintf(w, "%-16s %-8s %-10s %-10s %-20s %-8s %-15s\n",
+					truncateString(r.Host, 16), r.Port, r.Protocol, r.Status, truncateString(r.Process, 20), pidStr, r.Address)
+			}
+		})
+	},
+}
+
+var fleetProcessesCmd = &cobra.Command{
+	Use:   "processes",
+	Short: "List the top processes of every node",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rows, failures, err := queryFleet(cmd.Context(), func(ctx context.Context, host string, c *remote.Client) ([]fleetProcessRow, error) {
+			procs, err := c.Processes(ctx)
+			rows := make([]fleetProcessRow, len(procs))
+			for i, p := range procs {
+				rows[i] = fleetProcessRow{Host: host, ProcessInfo: p}
+			}
+			return rows, err
+		})
+		if err != nil {
+			return err
+		}
+		return writeFleet(cmd.OutOrStdout(), rows, failures, func(w io.Writer) {
+			fmt.Fprintf(w, "%-16s %-8s %-20s %-10s %8s %8s %10s %-15s\n",
+				"HOST", "PID", "NAME", "STATUS", "CPU%", "MEM%", "RSS(MB)", "USER")
+			fmt.Fprintln(w, strings.Repeat("-", 100))
+			for _, r := range rows {
+				fmt.Fprintf(w, "%-16s %-8d %-20s %-10s %7.1f%% %7.1f%% %9.1f %-15s\n",
+					truncateString(r.Host, 16), r.PID, truncateString(r.Name, 20), r.Status,
+					r.CPUPercent, r.MemPercent, float64(r.MemoryRSS)/1024/1024, truncateString(r.Username, 15))
+			}
+		})
+	},
+}
+
+// fleetInfoRow is one node in "fleet info"
+type fleetInfoRow struct {
+	Host          string  `json:"host"`
+	Hostname      string  `json:"hostname"`
+	OS            string  `json:"os"`
+	Uptime        string  `json:"uptime"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
+	sysinfo.LoadAverageInfo
+	WorstMount        string  `json:"worst_mount"`
+	WorstMountPercent float64 `json:"worst_mount_percent"`
+	ProcessCount      uint64  `json:"process_count"`
+	Problems          int     `json:"problems"`
+}
+
+// fleetPortRow is one open port in "fleet ports"
+type fleetPortRow struct {
+	Host string `json:"host"`
+	sysinfo.PortInfo
+}
+
+// fleetProcessRow is one process in "fleet processes"
+type fleetProcessRow struct {
+	Host string `json:"host"`
+	sysinfo.ProcessInfo
+}
+
+// fleetFailure is a node that couldn't be queried
+type fleetFailure struct {
+	Host  string
+	Error string
+}
+
+// queryFleet runs query against every node in --nodes with at most
+// --workers at a time and returns the rows that pass --where, in the order
+// of the nodes file, along with the nodes that failed
+func queryFleet[R any](ctx context.Context, query func(ctx context.Context, host string, c *remote.Client) ([]R, error)) ([]R, []fleetFailure, error) {
+	var filters []fleet.Filter
+	for _, where := range fleetWhere {
+		f, err := fleet.ParseFilter(where)
+		if err != nil {
+			return nil, nil, err
+		}
+		var zero R
+		if err := f.Check(zero); err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, f)
+	}
+	targets, err := fleet.LoadTargets(fleetNodesPath)
+	if err != nil {
+		return nil, nil, &exitError{exitFailure, err}
+	}
+	if len(targets) == 0 {
+		return nil, nil, &exitError{exitFailure, fmt.Errorf("%s lists no nodes", fleetNodesPath)}
+	}
+	opts := remoteConnOptions()
+	client, err := opts.HTTPClient()
+	if err != nil {
+		return nil, nil, &exitError{exitFailure, err}
+	}
+
+	results := fleet.Query(ctx, targets, fleetWorkers, func(ctx context.Context, t fleet.Target) ([]R, error) {
+		return query(ctx, t.Name, remote.NewClientWith(t.URL, opts.Token, client))
+	})
+	var (
+		rows     []R
+		failures []fleetFailure
+	)
+	for _, r := range results {
+		if r.Err != nil {
+			failures = append(failures, fleetFailure{Host: r.Target.Name, Error: r.Err.Error()})
+			continue
+		}
+		for _, row := range r.Value {
+			if fleet.MatchAll(filters, row) {
+				rows = append(rows, row)
+			}
+		}
+	}
+	if len(failures) == len(targets) {
+		reportFleetFailures(os.Stderr, failures)
+		return nil, nil, &exitError{exitFailure, fmt.Errorf("none of the %d nodes could be queried", len(targets))}
+	}
+	return rows, failures, nil
+}
+
+// writeFleet writes the merged rows, with printTable in table mode, and
+// lists the nodes that failed
+func writeFleet[R any](w io.Writer, rows []R, failures []fleetFailure, printTable func(io.Writer)) error {
+	if outputFormat != outputTable {
+		if err := writeRows(w, rows); err != nil {
+			return err
+		}
+		// Keep machine-readable output parseable
+		reportFleetFailures(os.Stderr, failures)
+	} else {
+		if len(rows) == 0 {
+			fmt.Fprintln(w, "No matching rows.")
+		} else {
+			printTable(w)
+			fmt.Fprintf(w, "\nTotal: %d rows\n", len(rows))
+		}
+		if len(failures) > 0 {
+			fmt.Fprintln(w, "\n=== Unreachable Nodes ===")
+			for _, f := range failures {
+				fmt.Fprintf(w, "%-16s %s\n", truncateString(f.Host, 16), f.Error)
+			}
+		}
+	}
+	if len(failures) > 0 {
+		hosts := make([]string, len(failures))
+		for i, f := range failures {
+			hosts[i] = f.Host
+		}
+		return &exitError{exitPartial, fmt.Errorf("could not query %s", strings.Join(hosts, ", "))}
+	}
+	return nil
+}
+
+// reportFleetFailures warns about each node that failed
+func reportFleetFailures(w io.Writer, failures []fleetFailure) {
+	for _, f := range failures {
+		fmt.Fprintf(w, "Warning: %s: %s\n", f.Host, f.Error)
+	}
+}
+
+func init() {
+	flags := fleetCmd.PersistentFlags()
+	flags.StringVar(&fleetNodesPath, "nodes", "", "File listing the sysinfo servers to query, one URL or \"name URL\" per line")
+	flags.IntVar(&fleetWorkers, "workers", fleet.DefaultQueryWorkers, "How many nodes to query at once")
+	flags.StringArrayVar(&fleetWhere, "where", nil, "Only show rows matching field<op>value, e.g. port=5432 (repeatable)")
+	fleetCmd.MarkPersistentFlagRequired("nodes")
+	fleetCmd.AddCommand(fleetInfoCmd, fleetPortsCmd, fleetProcessesCmd)
+	rootCmd.AddCommand(fleetCmd)
+}
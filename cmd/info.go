@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,27 +15,38 @@ import (
 var infoCmd = &cobra.Command{
 	Use:   "info",
 	Short: "Show system information",
-	Run: func(cmd *cobra.Command, args []string) {
-		info, err := sysinfo.GetSystemInfo()
-		if err != nil {
-			fmt.Println("Error:", err)
-			return
-		}
-
-		fmt.Println("=== System Information ===")
-		fmt.Printf("OS: %s\n", info.OS)
-		fmt.Printf("Hostname: %s\n", info.Hostname)
-		fmt.Printf("Architecture: %s\n", info.Architecture)
-		fmt.Printf("Kernel Version: %s\n", info.KernelVersion)
-		fmt.Printf("Uptime: %s\n", info.Uptime)
-		fmt.Printf("Last Boot: %s\n", info.LastBoot)
-		fmt.Printf("Process Count: %d\n", info.ProcessCount)
-
-		fmt.Println("\n=== CPU Information ===")
-		fmt.Printf("Model: %s\n", info.CPU.ModelName)
-		fmt.Printf("Physical Cores: %d\n", info.CPU.Cores)
-		fmt.Printf("Logical Cores: %d\n", info.CPU.LogicalCores)
-		fmt.Printf("Frequency: %.2f MHz\n", info.CPU.Frequency)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if remoteClient == nil {
+			// A remote server forecasts its own disks
+			enableDiskForecast(cmd)
+		}
+		info, _, err := collectInfo(cmd.Context(), collectOptions())
+		if info == nil {
+			return err
+		}
+		if outputFormat != outputTable {
+			if werr := writeSystemInfo(cmd.OutOrStdout(), info); werr != nil {
+				return werr
+			}
+			return collectionError(info, err)
+		}
+
+		if showSection(os.Stdout, info, sysinfo.SectionHost, "=== System Information ===") {
+			fmt.Printf("OS: %s\n", info.OS)
+			fmt.Printf("Hostname: %s\n", info.Hostname)
+			fmt.Printf("Architecture: %s\n", info.Architecture)
+			fmt.Printf("Kernel Version: %s\n", info.KernelVersion)
+			fmt.Printf("Uptime: %s\n", info.Uptime)
+			fmt.Printf("Last Boot: %s\n", info.LastBoot)
+			fmt.Printf("Process Count: %d\n", info.ProcessCount)
+		}
+
+		if showSection(os.Stdout, info, sysinfo.SectionCPU, "\n=== CPU Information ===") {
+			fmt.Printf("Model: %s\n", info.CPU.ModelName)
+			fmt.Printf("Physical Cores: %d\n", info.CPU.Cores)
+			fmt.Printf("Logical Cores: %d\n", info.CPU.LogicalCores)
+			fmt.Printf("Frequency: %.2f MHz\n", info.CPU.Frequency)
+		}
 		if len(info.CPU.Usage) > 0 {
 			fmt.Printf("CPU Usage per core: ")
 			for i, usage := range info.CPU.Usage {
@@ -44,27 +58,32 @@ var infoCmd = &cobra.Command{
 			fmt.Println()
 		}
 
-		fmt.Println("\n=== Memory Information ===")
-		fmt.Printf("Total: %.2f GB\n", float64(info.Memory.Total)/1e9)
-		fmt.Printf("Used: %.2f GB (%.1f%%)\n", float64(info.Memory.Used)/1e9, info.Memory.UsedPercent)
-		fmt.Printf("Free: %.2f GB\n", float64(info.Memory.Free)/1e9)
-		fmt.Printf("Available: %.2f GB\n", float64(info.Memory.Available)/1e9)
-		fmt.Printf("Cached: %.2f GB\n", float64(info.Memory.Cached)/1e9)
-		fmt.Printf("Buffers: %.2f GB\n", float64(info.Memory.Buffers)/1e9)
-		fmt.Printf("Shared: %.2f GB\n", float64(info.Memory.Shared)/1e9)
-		fmt.Printf("Active: %.2f GB\n", float64(info.Memory.Active)/1e9)
-		fmt.Printf("Inactive: %.2f GB\n", float64(info.Memory.Inactive)/1e9)
+		if showSection(os.Stdout, info, sysinfo.SectionMemory, "\n=== Memory Information ===") {
+			fmt.Printf("Total: %.2f GB\n", float64(info.Memory.Total)/1e9)
+			fmt.Printf("Used: %.2f GB (%.1f%%)\n", float64(info.Memory.Used)/1e9, info.Memory.UsedPercent)
+			fmt.Printf("Free: %.2f GB\n", float64(info.Memory.Free)/1e9)
+			fmt.Printf("Available: %.2f GB\n", float64(info.Memory.Available)/1e9)
+			fmt.Printf("Cached: %.2f GB\n", float64(info.Memory.Cached)/1e9)
+			fmt.Printf("Buffers: %.2f GB\n", float64(info.Memory.Buffers)/1e9)
+			fmt.Printf("Shared: %.2f GB\n", float64(info.Memory.Shared)/1e9)
+			fmt.Printf("Active: %.2f GB\n", float64(info.Memory.Active)/1e9)
+			fmt.Printf("Inactive: %.2f GB\n", float64(info.Memory.Inactive)/1e9)
+		}
 
-		fmt.Println("\n=== Swap Information ===")
-		if info.Swap.Total > 0 {
-			fmt.Printf("Total: %.2f GB\n", float64(info.Swap.Total)/1e9)
-			fmt.Printf("Used: %.2f GB (%.1f%%)\n", float64(info.Swap.Used)/1e9, info.Swap.UsedPercent)
-			fmt.Printf("Free: %.2f GB\n", float64(info.Swap.Free)/1e9)
-		} else {
-			fmt.Println("No swap configured")
+		if showSection(os.Stdout, info, sysinfo.SectionSwap, "\n=== Swap Information ===") {
+			if info.Swap.Total > 0 {
+				fmt.Printf("Total: %.2f GB\n", float64(info.Swap.Total)/1e9)
+				fmt.Printf("Used: %.2f GB (%.1f%%)\n", float64(info.Swap.Used)/1e9, info.Swap.UsedPercent)
+				fmt.Printf("Free: %.2f GB\n", float64(info.Swap.Free)/1e9)
+			} else {
+				fmt.Println("No swap configured")
+			}
 		}
 
-		if len(info.Disk) > 0 {
+		if info.SectionFailed(sysinfo.SectionDisk) {
+			fmt.Println("\n=== Disk Information ===")
+			printUnavailable(os.Stdout, info, sysinfo.SectionDisk)
+		} else if len(info.Disk) > 0 {
 			fmt.Println("\n=== Disk Information ===")
 			for _, disk := range info.Disk {
 				fmt.Printf("%s (%s) - %s\n", disk.Device, disk.Mountpoint, disk.Fstype)
@@ -74,27 +93,38 @@ var infoCmd = &cobra.Command{
 					fmt.Printf("  Inodes: Total: %d, Used: %d, Free: %d\n",
 						disk.InodesTotal, disk.InodesUsed, disk.InodesFree)
 				}
+				if fillsWithin(disk.PredictedFullAt, forecastHorizon) {
+					fmt.Printf("  Warning: predicted to run out of space at %s (in %s)\n",
+						formatFullAt(disk.PredictedFullAt), formatETA(*disk.PredictedFullAt))
+				}
+				if fillsWithin(disk.InodesPredictedFullAt, forecastHorizon) {
+					fmt.Printf("  Warning: predicted to run out of inodes at %s (in %s)\n",
+						formatFullAt(disk.InodesPredictedFullAt), formatETA(*disk.InodesPredictedFullAt))
+				}
 			}
 		}
 
-		fmt.Println("\n=== Network Information ===")
-		fmt.Printf("Bytes Sent: %.2f MB\n", float64(info.Network.BytesSent)/1e6)
-		fmt.Printf("Bytes Received: %.2f MB\n", float64(info.Network.BytesRecv)/1e6)
-		fmt.Printf("Packets Sent: %d\n", info.Network.PacketsSent)
-		fmt.Printf("Packets Received: %d\n", info.Network.PacketsRecv)
-		fmt.Printf("Errors In: %d, Errors Out: %d\n", info.Network.ErrorsIn, info.Network.ErrorsOut)
-		fmt.Printf("Drops In: %d, Drops Out: %d\n", info.Network.DropsIn, info.Network.DropsOut)
-		fmt.Printf("Network Interfaces: %d\n", len(info.Network.Interfaces))
+		if showSection(os.Stdout, info, sysinfo.SectionNetwork, "\n=== Network Information ===") {
+			fmt.Printf("Bytes Sent: %.2f MB\n", float64(info.Network.BytesSent)/1e6)
+			fmt.Printf("Bytes Received: %.2f MB\n", float64(info.Network.BytesRecv)/1e6)
+			fmt.Printf("Packets Sent: %d\n", info.Network.PacketsSent)
+			fmt.Printf("Packets Received: %d\n", info.Network.PacketsRecv)
+			fmt.Printf("Errors In: %d, Errors Out: %d\n", info.Network.ErrorsIn, info.Network.ErrorsOut)
+			fmt.Printf("Drops In: %d, Drops Out: %d\n", info.Network.DropsIn, info.Network.DropsOut)
+			fmt.Printf("Network Interfaces: %d\n", len(info.Network.Interfaces))
+		}
 
-		fmt.Println("\n=== Load Average ===")
-		fmt.Printf("1 min: %.2f, 5 min: %.2f, 15 min: %.2f\n",
-			info.LoadAverage.Load1, info.LoadAverage.Load5, info.LoadAverage.Load15)
+		if showSection(os.Stdout, info, sysinfo.SectionLoad, "\n=== Load Average ===") {
+			fmt.Printf("1 min: %.2f, 5 min: %.2f, 15 min: %.2f\n",
+				info.LoadAverage.Load1, info.LoadAverage.Load5, info.LoadAverage.Load15)
+		}
 
-		fmt.Println("\n=== I/O Statistics ===")
-		fmt.Printf("Disk Read: %.2f MB (%d operations, %d ms)\n",
-			float64(info.IOStats.DiskReadBytes)/1e6, info.IOStats.DiskReadCount, info.IOStats.DiskReadTime)
-		fmt.Printf("Disk Write: %.2f MB (%d operations, %d ms)\n",
-			float64(info.IOStats.DiskWriteBytes)/1e6, info.IOStats.DiskWriteCount, info.IOStats.DiskWriteTime)
+		if showSection(os.Stdout, info, sysinfo.SectionIO, "\n=== I/O Statistics ===") {
+			fmt.Printf("Disk Read: %.2f MB (%d operations, %d ms)\n",
+				float64(info.IOStats.DiskReadBytes)/1e6, info.IOStats.DiskReadCount, info.IOStats.DiskReadTime)
+			fmt.Printf("Disk Write: %.2f MB (%d operations, %d ms)\n",
+				float64(info.IOStats.DiskWriteBytes)/1e6, info.IOStats.DiskWriteCount, info.IOStats.DiskWriteTime)
+		}
 
 		if len(info.TopProcesses) > 0 {
 			fmt.Println("\n=== Top Processes ===")
@@ -129,13 +159,76 @@ var infoCmd = &cobra.Command{
 			}
 		}
 
-		if info.Temperature.CPUTemp > 0 {
+		if info.Temperature.CPUTemp > 0 || len(info.Temperature.Sensors) > 0 || len(info.Temperature.ThermalZone) > 0 {
 			fmt.Println("\n=== Temperature Information ===")
-			fmt.Printf("CPU Temperature: %.1f°C\n", info.Temperature.CPUTemp)
+			if info.Temperature.CPUTemp > 0 {
+				fmt.Printf("CPU Temperature: %.1f°C\n", info.Temperature.CPUTemp)
+			}
+			for _, sensor := range info.Temperature.Sensors {
+				fmt.Printf("%-30s %6.1f°C", truncateString(sensor.Name, 30), sensor.Temperature)
+				if sensor.High > 0 {
+					fmt.Printf("  (high: %.1f°C", sensor.High)
+					if sensor.Critical > 0 {
+						fmt.Printf(", crit: %.1f°C", sensor.Critical)
+					}
+					fmt.Printf(")")
+				} else if sensor.Critical > 0 {
+					fmt.Printf("  (crit: %.1f°C)", sensor.Critical)
+				}
+				fmt.Println()
+			}
+			zones := make([]string, 0, len(info.Temperature.ThermalZone))
+			for zone := range info.Temperature.ThermalZone {
+				zones = append(zones, zone)
+			}
+			sort.Strings(zones)
+			for _, zone := range zones {
+				fmt.Printf("Thermal zone %-17s %6.1f°C\n", truncateString(zone, 17), info.Temperature.ThermalZone[zone])
+			}
 		}
+
+		printCollectionProblems(os.Stdout, info)
+		return collectionError(info, err)
 	},
 }
 
+// showSection prints the header of a section that was collected and reports
+// whether its body should be printed. Sections whose collector failed get
+// the reason printed in place of their body.
+func showSection(w io.Writer, info *sysinfo.SystemInfo, section, header string) bool {
+	if !info.Collected(section) {
+		return false
+	}
+	fmt.Fprintln(w, header)
+	return !printUnavailable(w, info, section)
+}
+
+// printUnavailable prints why a section is missing and reports whether it is
+func printUnavailable(w io.Writer, info *sysinfo.SystemInfo, section string) bool {
+	for _, e := range info.Errors {
+		if e.Section == section {
+			fmt.Fprintf(w, "Unavailable: %s\n", e.Message)
+			return true
+		}
+	}
+	return false
+}
+
+// printCollectionProblems lists every section that failed or is incomplete
+func printCollectionProblems(w io.Writer, info *sysinfo.SystemInfo) {
+	if len(info.Errors) == 0 && len(info.Warnings) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\n=== Collection Problems ===")
+	for _, e := range info.Errors {
+		fmt.Fprintf(w, "ERROR   %-12s %s\n", e.Section, e.Message)
+	}
+	for _, warn := range info.Warnings {
+		fmt.Fprintf(w, "WARNING %-12s %s\n", warn.Section, warn.Message)
+	}
+}
+
 // truncateString truncates a string to the specified length
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -145,5 +238,10 @@ func truncateString(s string, maxLen int) string {
 }
 
 func init() {
+	addCollectorsFlag(infoCmd)
+	addSectionFlag(infoCmd)
+	// info only inspects the host unless asked to record samples too
+	addForecastFlags(infoCmd, "")
+	supportsRemote(infoCmd)
 	rootCmd.AddCommand(infoCmd)
 }
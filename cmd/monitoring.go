@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"time"
 
@@ -19,169 +21,220 @@ var monitoringCmd = &cobra.Command{
 - System temperature (if available)
 - Load averages
 - Logged in users
-- System services status`,
-	Run: func(cmd *cobra.Command, args []string) {
-		info, err := sysinfo.GetSystemInfo()
-		if err != nil {
-			fmt.Println("Error:", err)
-			return
+- System services status
+
+With --watch the dashboard is redrawn every --interval and shows
+per-second network and disk I/O rates between refreshes. It also learns
+the usual CPU usage, load, network and disk I/O rates and process count
+while running and lists metrics that stray far from them under ANOMALIES,
+once about 40 refreshes have been seen.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watchMode {
+			if outputFormat != outputTable && outputFormat != outputNDJSON {
+				return fmt.Errorf("--watch supports table and ndjson output, not %s", outputFormat)
+			}
+			if watchInterval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			return watchDashboard(cmd)
 		}
 
-		// System Overview
-		fmt.Println("=== SYSTEM MONITORING DASHBOARD ===")
-		fmt.Printf("Host: %s | OS: %s | Uptime: %s\n", info.Hostname, info.OS, info.Uptime)
-		fmt.Printf("Architecture: %s | Kernel: %s\n", info.Architecture, info.KernelVersion)
-		fmt.Printf("Processes: %d | Last Boot: %s\n", info.ProcessCount, info.LastBoot)
+		info, _, err := collectInfo(cmd.Context(), collectOptions())
+		if info == nil {
+			return err
+		}
+		if outputFormat != outputTable {
+			if werr := writeSystemInfo(cmd.OutOrStdout(), info); werr != nil {
+				return werr
+			}
+			return collectionError(info, err)
+		}
+
+		printDashboard(os.Stdout, info, nil)
+		return collectionError(info, err)
+	},
+}
 
-		// Load Average
-		fmt.Println("\n=== LOAD AVERAGE ===")
-		fmt.Printf("1min: %6.2f | 5min: %6.2f | 15min: %6.2f\n",
+// printDashboard writes the monitoring dashboard for one snapshot. rates,
+// when set, adds per-second rates since the previous snapshot.
+func printDashboard(w io.Writer, info *sysinfo.SystemInfo, rates *sysinfo.Rates) {
+	// System Overview
+	fmt.Fprintln(w, "=== SYSTEM MONITORING DASHBOARD ===")
+	if info.Collected(sysinfo.SectionHost) && !printUnavailable(w, info, sysinfo.SectionHost) {
+		fmt.Fprintf(w, "Host: %s | OS: %s | Uptime: %s\n", info.Hostname, info.OS, info.Uptime)
+		fmt.Fprintf(w, "Architecture: %s | Kernel: %s\n", info.Architecture, info.KernelVersion)
+		fmt.Fprintf(w, "Processes: %d | Last Boot: %s\n", info.ProcessCount, info.LastBoot)
+	}
+
+	// Load Average
+	if showSection(w, info, sysinfo.SectionLoad, "\n=== LOAD AVERAGE ===") {
+		fmt.Fprintf(w, "1min: %6.2f | 5min: %6.2f | 15min: %6.2f\n",
 			info.LoadAverage.Load1, info.LoadAverage.Load5, info.LoadAverage.Load15)
+	}
 
-		// CPU Details
-		fmt.Println("\n=== CPU METRICS ===")
-		fmt.Printf("Model: %s\n", info.CPU.ModelName)
-		fmt.Printf("Cores: %d Physical / %d Logical | Frequency: %.0f MHz\n",
+	// CPU Details
+	if showSection(w, info, sysinfo.SectionCPU, "\n=== CPU METRICS ===") {
+		fmt.Fprintf(w, "Model: %s\n", info.CPU.ModelName)
+		fmt.Fprintf(w, "Cores: %d Physical / %d Logical | Frequency: %.0f MHz\n",
 			info.CPU.Cores, info.CPU.LogicalCores, info.CPU.Frequency)
-		if len(info.CPU.Usage) > 0 {
-			fmt.Printf("Per-Core Usage: ")
-			for i, usage := range info.CPU.Usage {
-				if i > 0 && i%8 == 0 {
-					fmt.Printf("\n                ")
-				}
-				fmt.Printf("%5.1f%% ", usage)
+	}
+	if len(info.CPU.Usage) > 0 {
+		fmt.Fprintf(w, "Per-Core Usage: ")
+		for i, usage := range info.CPU.Usage {
+			if i > 0 && i%8 == 0 {
+				fmt.Fprintf(w, "\n                ")
 			}
-			fmt.Println()
-		}
-		if info.CPU.Temperature > 0 {
-			fmt.Printf("Temperature: %.1f°C\n", info.CPU.Temperature)
+			fmt.Fprintf(w, "%5.1f%% ", usage)
 		}
+		fmt.Fprintln(w)
+	}
+	if info.CPU.Temperature > 0 {
+		fmt.Fprintf(w, "Temperature: %.1f°C\n", info.CPU.Temperature)
+	}
 
-		// Memory and Swap
-		fmt.Println("\n=== MEMORY METRICS ===")
-		fmt.Printf("RAM:  Total: %7.1f GB | Used: %7.1f GB (%5.1f%%) | Available: %7.1f GB\n",
+	// Memory and Swap
+	if showSection(w, info, sysinfo.SectionMemory, "\n=== MEMORY METRICS ===") {
+		fmt.Fprintf(w, "RAM:  Total: %7.1f GB | Used: %7.1f GB (%5.1f%%) | Available: %7.1f GB\n",
 			float64(info.Memory.Total)/1e9, float64(info.Memory.Used)/1e9,
 			info.Memory.UsedPercent, float64(info.Memory.Available)/1e9)
-		fmt.Printf("      Cached: %6.1f GB | Buffers: %5.1f GB | Shared: %7.1f GB\n",
+		fmt.Fprintf(w, "      Cached: %6.1f GB | Buffers: %5.1f GB | Shared: %7.1f GB\n",
 			float64(info.Memory.Cached)/1e9, float64(info.Memory.Buffers)/1e9, float64(info.Memory.Shared)/1e9)
+	}
 
+	if info.Collected(sysinfo.SectionSwap) {
 		if info.Swap.Total > 0 {
-			fmt.Printf("Swap: Total: %7.1f GB | Used: %7.1f GB (%5.1f%%) | Free: %9.1f GB\n",
+			fmt.Fprintf(w, "Swap: Total: %7.1f GB | Used: %7.1f GB (%5.1f%%) | Free: %9.1f GB\n",
 				float64(info.Swap.Total)/1e9, float64(info.Swap.Used)/1e9,
 				info.Swap.UsedPercent, float64(info.Swap.Free)/1e9)
-		} else {
-			fmt.Println("Swap: Not configured")
+		} else if !info.SectionFailed(sysinfo.SectionSwap) {
+			fmt.Fprintln(w, "Swap: Not configured")
 		}
+	}
 
-		// I/O Statistics
-		fmt.Println("\n=== DISK I/O METRICS ===")
-		fmt.Printf("Read:  %8.1f MB (%8d ops) | Avg Time: %6.1f ms\n",
+	// I/O Statistics
+	if showSection(w, info, sysinfo.SectionIO, "\n=== DISK I/O METRICS ===") {
+		fmt.Fprintf(w, "Read:  %8.1f MB (%8d ops) | Avg Time: %6.1f ms\n",
 			float64(info.IOStats.DiskReadBytes)/1e6, info.IOStats.DiskReadCount,
 			getAvgTime(info.IOStats.DiskReadTime, info.IOStats.DiskReadCount))
-		fmt.Printf("Write: %8.1f MB (%8d ops) | Avg Time: %6.1f ms\n",
+		fmt.Fprintf(w, "Write: %8.1f MB (%8d ops) | Avg Time: %6.1f ms\n",
 			float64(info.IOStats.DiskWriteBytes)/1e6, info.IOStats.DiskWriteCount,
 			getAvgTime(info.IOStats.DiskWriteTime, info.IOStats.DiskWriteCount))
+		if rates != nil && rates.IO {
+			fmt.Fprintf(w, "Rate:  Read: %7.2f MB/s %7.0f IOPS %6.1f ms | Write: %7.2f MB/s %7.0f IOPS %6.1f ms\n",
+				rates.DiskRead/1e6, rates.DiskReadIOPS, rates.DiskReadAwait,
+				rates.DiskWrite/1e6, rates.DiskWriteIOPS, rates.DiskWriteAwait)
+		}
+	}
 
-		// Network Statistics
-		fmt.Println("\n=== NETWORK METRICS ===")
-		fmt.Printf("Traffic:  Sent: %8.1f MB | Received: %8.1f MB\n",
+	// Network Statistics
+	if showSection(w, info, sysinfo.SectionNetwork, "\n=== NETWORK METRICS ===") {
+		fmt.Fprintf(w, "Traffic:  Sent: %8.1f MB | Received: %8.1f MB\n",
 			float64(info.Network.BytesSent)/1e6, float64(info.Network.BytesRecv)/1e6)
-		fmt.Printf("Packets:  Sent: %8d    | Received: %8d\n",
+		fmt.Fprintf(w, "Packets:  Sent: %8d    | Received: %8d\n",
 			info.Network.PacketsSent, info.Network.PacketsRecv)
-		fmt.Printf("Errors:   In: %10d    | Out: %12d\n",
+		fmt.Fprintf(w, "Errors:   In: %10d    | Out: %12d\n",
 			info.Network.ErrorsIn, info.Network.ErrorsOut)
-		fmt.Printf("Drops:    In: %10d    | Out: %12d\n",
+		fmt.Fprintf(w, "Drops:    In: %10d    | Out: %12d\n",
 			info.Network.DropsIn, info.Network.DropsOut)
+		if rates != nil && rates.Network {
+			fmt.Fprintf(w, "Rate:     Sent: %8.2f MB/s %8.0f pkt/s | Received: %8.2f MB/s %8.0f pkt/s\n",
+				rates.NetSent/1e6, rates.NetPacketsSent, rates.NetRecv/1e6, rates.NetPacketsRecv)
+			fmt.Fprintf(w, "Last %s: Errors +%d in / +%d out | Drops +%d in / +%d out\n",
+				rates.Elapsed.Round(100*time.Millisecond),
+				rates.NetErrorsIn, rates.NetErrorsOut, rates.NetDropsIn, rates.NetDropsOut)
+		}
+	}
 
-		// Top Processes
-		if len(info.TopProcesses) > 0 {
-			fmt.Println("\n=== TOP PROCESSES BY CPU ===")
-			fmt.Printf("%-8s %-20s %-10s %8s %8s %10s %-15s\n",
-				"PID", "NAME", "STATUS", "CPU%", "MEM%", "RSS(MB)", "USER")
-			fmt.Println(strings.Repeat("-", 90))
-			for i, proc := range info.TopProcesses {
-				if i >= 15 { // Show top 15
-					break
-				}
-				fmt.Printf("%-8d %-20s %-10s %7.1f%% %7.1f%% %9.1f %-15s\n",
-					proc.PID,
-					truncateString(proc.Name, 20),
-					proc.Status,
-					proc.CPUPercent,
-					proc.MemPercent,
-					float64(proc.MemoryRSS)/1024/1024,
-					truncateString(proc.Username, 15))
+	// Top Processes
+	if len(info.TopProcesses) > 0 {
+		fmt.Fprintln(w, "\n=== TOP PROCESSES BY CPU ===")
+		fmt.Fprintf(w, "%-8s %-20s %-10s %8s %8s %10s %-15s\n",
+			"PID", "NAME", "STATUS", "CPU%", "MEM%", "RSS(MB)", "USER")
+		fmt.Fprintln(w, strings.Repeat("-", 90))
+		for i, proc := range info.TopProcesses {
+			if i >= 15 { // Show top 15
+				break
 			}
+			fmt.Fprintf(w, "%-8d %-20s %-10s %7.1f%% %7.1f%% %9.1f %-15s\n",
+				proc.PID,
+				truncateString(proc.Name, 20),
+				proc.Status,
+				proc.CPUPercent,
+				proc.MemPercent,
+				float64(proc.MemoryRSS)/1024/1024,
+				truncateString(proc.Username, 15))
 		}
+	}
 
-		// Disk Usage
-		if len(info.Disk) > 0 {
-			fmt.Println("\n=== DISK USAGE ===")
-			fmt.Printf("%-20s %-15s %-10s %10s %10s %10s %8s\n",
-				"DEVICE", "MOUNTPOINT", "FSTYPE", "TOTAL", "USED", "FREE", "USE%")
-			fmt.Println(strings.Repeat("-", 95))
-			for _, disk := range info.Disk {
-				fmt.Printf("%-20s %-15s %-10s %9.1fG %9.1fG %9.1fG %7.1f%%\n",
-					truncateString(disk.Device, 20),
-					truncateString(disk.Mountpoint, 15),
-					disk.Fstype,
-					float64(disk.Total)/1e9,
-					float64(disk.Used)/1e9,
-					float64(disk.Free)/1e9,
-					disk.UsedPercent)
-			}
+	// Disk Usage
+	if len(info.Disk) > 0 {
+		fmt.Fprintln(w, "\n=== DISK USAGE ===")
+		fmt.Fprintf(w, "%-20s %-15s %-10s %10s %10s %10s %8s\n",
+			"DEVICE", "MOUNTPOINT", "FSTYPE", "TOTAL", "USED", "FREE", "USE%")
+		fmt.Fprintln(w, strings.Repeat("-", 95))
+		for _, disk := range info.Disk {
+			fmt.Fprintf(w, "%-20s %-15s %-10s %9.1fG %9.1fG %9.1fG %7.1f%%\n",
+				truncateString(disk.Device, 20),
+				truncateString(disk.Mountpoint, 15),
+				disk.Fstype,
+				float64(disk.Total)/1e9,
+				float64(disk.Used)/1e9,
+				float64(disk.Free)/1e9,
+				disk.UsedPercent)
 		}
+	}
 
-		// Active Users
-		if len(info.Users) > 0 {
-			fmt.Println("\n=== ACTIVE USERS ===")
-			fmt.Printf("%-15s %-10s %-15s %-20s\n", "USER", "TTY", "HOST", "LOGIN TIME")
-			fmt.Println(strings.Repeat("-", 65))
-			for _, user := range info.Users {
-				fmt.Printf("%-15s %-10s %-15s %-20s\n",
-					truncateString(user.User, 15),
-					truncateString(user.Terminal, 10),
-					truncateString(user.Host, 15),
-					time.Unix(user.Started, 0).Format("2006-01-02 15:04"))
-			}
+	// Active Users
+	if len(info.Users) > 0 {
+		fmt.Fprintln(w, "\n=== ACTIVE USERS ===")
+		fmt.Fprintf(w, "%-15s %-10s %-15s %-20s\n", "USER", "TTY", "HOST", "LOGIN TIME")
+		fmt.Fprintln(w, strings.Repeat("-", 65))
+		for _, user := range info.Users {
+			fmt.Fprintf(w, "%-15s %-10s %-15s %-20s\n",
+				truncateString(user.User, 15),
+				truncateString(user.Terminal, 10),
+				truncateString(user.Host, 15),
+				time.Unix(user.Started, 0).Format("2006-01-02 15:04"))
 		}
+	}
 
-		// System Services (sample)
-		if len(info.SystemServices) > 0 {
-			fmt.Println("\n=== SYSTEM SERVICES (Sample) ===")
-			fmt.Printf("%-25s %-12s %8s\n", "SERVICE", "STATUS", "PID")
-			fmt.Println(strings.Repeat("-", 50))
-			serviceCount := 0
-			for _, service := range info.SystemServices {
-				if serviceCount >= 15 { // Limit to 15 services
-					break
-				}
-				fmt.Printf("%-25s %-12s %8d\n",
-					truncateString(service.Name, 25),
-					service.Status,
-					service.PID)
-				serviceCount++
+	// System Services (sample)
+	if len(info.SystemServices) > 0 {
+		fmt.Fprintln(w, "\n=== SYSTEM SERVICES (Sample) ===")
+		fmt.Fprintf(w, "%-25s %-12s %8s\n", "SERVICE", "STATUS", "PID")
+		fmt.Fprintln(w, strings.Repeat("-", 50))
+		serviceCount := 0
+		for _, service := range info.SystemServices {
+			if serviceCount >= 15 { // Limit to 15 services
+				break
 			}
+			fmt.Fprintf(w, "%-25s %-12s %8d\n",
+				truncateString(service.Name, 25),
+				service.Status,
+				service.PID)
+			serviceCount++
 		}
+	}
 
-		// Network Interfaces
-		if len(info.Network.Interfaces) > 0 {
-			fmt.Println("\n=== NETWORK INTERFACES ===")
-			for _, iface := range info.Network.Interfaces {
-				fmt.Printf("Interface: %s (MTU: %d)\n", iface.Name, iface.MTU)
-				if len(iface.Addresses) > 0 {
-					fmt.Printf("  Addresses: %s\n", strings.Join(iface.Addresses, ", "))
-				}
-				if iface.HardwareAddr != "" {
-					fmt.Printf("  Hardware: %s\n", iface.HardwareAddr)
-				}
-				if len(iface.Flags) > 0 {
-					fmt.Printf("  Flags: %s\n", strings.Join(iface.Flags, ", "))
-				}
-				fmt.Println()
+	// Network Interfaces
+	if len(info.Network.Interfaces) > 0 {
+		fmt.Fprintln(w, "\n=== NETWORK INTERFACES ===")
+		for _, iface := range info.Network.Interfaces {
+			fmt.Fprintf(w, "Interface: %s (MTU: %d)\n", iface.Name, iface.MTU)
+			if len(iface.Addresses) > 0 {
+				fmt.Fprintf(w, "  Addresses: %s\n", strings.Join(iface.Addresses, ", "))
+			}
+			if iface.HardwareAddr != "" {
+				fmt.Fprintf(w, "  Hardware: %s\n", iface.HardwareAddr)
 			}
+			if len(iface.Flags) > 0 {
+				fmt.Fprintf(w, "  Flags: %s\n", strings.Join(iface.Flags, ", "))
+			}
+			fmt.Fprintln(w)
 		}
-	},
+	}
+
+	printCollectionProblems(w, info)
 }
 
 // getAvgTime calculates average time per operation
@@ -193,5 +246,11 @@ func getAvgTime(totalTime, count uint64) float64 {
 }
 
 func init() {
+	addCollectorsFlag(monitoringCmd)
+	addSectionFlag(monitoringCmd)
+	monitoringCmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Refresh the dashboard in place until interrupted")
+	monitoringCmd.Flags().DurationVar(&watchInterval, "interval", defaultWatchInterval, "Refresh interval for --watch")
+	monitoringCmd.Flags().IntVar(&watchCount, "count", 0, "Exit after this many refreshes with --watch (0 = no limit)")
+	supportsRemote(monitoringCmd)
 	rootCmd.AddCommand(monitoringCmd)
 }
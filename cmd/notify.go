@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/junler/sysinfo/internal/alert"
+	"github.com/junler/sysinfo/internal/anomaly"
+	"github.com/junler/sysinfo/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	notifyConfigPath string
+	notifyChannel    string
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage notification channels",
+	Long: `Manage the notification channels "sysinfo serve --notify-config" delivers
+host events and alerts to.`,
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a test notification to the configured channels",
+	Long: `Send a test notification to every channel in the config file, or only the
+one named by --channel. Routes and grouping are bypassed, retries are not.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := notify.LoadConfig(notifyConfigPath)
+		if err != nil {
+			return &exitError{exitFailure, err}
+		}
+		if len(cfg.Channels) == 0 {
+			return &exitError{exitFailure, fmt.Errorf("%s has no channels", notifyConfigPath)}
+		}
+		n := notify.New(cfg)
+		results, err := n.Test(cmd.Context(), notifyChannel)
+		if err != nil {
+			return &exitError{exitFailure, err}
+		}
+
+		w := cmd.OutOrStdout()
+		var failed []string
+		for _, name := range n.Channels() {
+			err, ok := results[name]
+			if !ok {
+				continue
+			}
+			if err != nil {
+				fmt.Fprintf(w, "%-20s FAILED  %v\n", name, err)
+				failed = append(failed, name)
+			} else {
+				fmt.Fprintf(w, "%-20s ok\n", name)
+			}
+		}
+		if len(failed) > 0 {
+			return &exitError{exitFailure, fmt.Errorf("test notification failed for %s", strings.Join(failed, ", "))}
+		}
+		return nil
+	},
+}
+
+// alertEvents converts alert state changes to notification events
+func alertEvents(changed []alert.Alert) []notify.Event {
+	var events []notify.Event
+	for _, a := range changed {
+		// Pending alerts haven't held long enough to be worth a notification
+		if a.State == alert.StatePending {
+			continue
+		}
+		severity := string(a.Severity)
+		if a.State == alert.StateResolved {
+			severity = notify.SeverityInfo
+		}
+		name := a.Rule
+		if a.Instance != "" {
+			name += "[" + a.Instance + "]"
+		}
+		summary := a.Summary
+		if summary == "" {
+			summary = a.Expr
+		}
+		labels := map[string]string{"rule": a.Rule, "instance": a.Instance, "state": string(a.State)}
+		for k, v := range a.Labels {
+			labels[k] = v
+		}
+		events = append(events, notify.NewEvent(notify.KindAlert, severity,
+			fmt.Sprintf("%s %s: %s (value %.2f)", name, a.State, summary, a.Value), labels))
+	}
+	return events
+}
+
+// anomalyEvents converts anomalies that started or ended to notification
+// events
+func anomalyEvents(changed []anomaly.Anomaly) []notify.Event {
+	events := make([]notify.Event, 0, len(changed))
+	for _, a := range changed {
+		severity := notify.SeverityWarning
+		if a.State == anomaly.StateEnded {
+			severity = notify.SeverityInfo
+		}
+		labels := map[string]string{"metric": a.Metric, "state": string(a.State)}
+		events = append(events, notify.NewEvent(notify.KindAnomaly, severity,
+			fmt.Sprintf("%s %s: %.2f, expected %.2f (score %+.1f)", a.Metric, anomalyStateText(a.State), a.Value, a.Expected, a.Score), labels))
+	}
+	return events
+}
+
+func anomalyStateText(s anomaly.State) string {
+	if s == anomaly.StateEnded {
+		return "back to normal"
+	}
+	return "anomalous"
+}
+
+func init() {
+	notifyTestCmd.Flags().StringVar(&notifyConfigPath, "config", "", "Notification config file")
+	notifyTestCmd.Flags().StringVar(&notifyChannel, "channel", "", "Only test the channel with this name")
+	notifyTestCmd.MarkFlagRequired("config")
+	notifyCmd.AddCommand(notifyTestCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
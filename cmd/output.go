@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported values for --output
+const (
+	outputTable  = "table"
+	outputJSON   = "json"
+	outputYAML   = "yaml"
+	outputCSV    = "csv"
+	outputNDJSON = "ndjson"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputYAML, outputCSV, outputNDJSON}
+
+var (
+	outputFormat string
+	sectionName  string
+)
+
+// infoSections are the tabular parts of SystemInfo selectable with --section
+var infoSections = map[string]func(*sysinfo.SystemInfo) interface{}{
+	"processes":  func(i *sysinfo.SystemInfo) interface{} { return i.TopProcesses },
+	"disks":      func(i *sysinfo.SystemInfo) interface{} { return i.Disk },
+	"interfaces": func(i *sysinfo.SystemInfo) interface{} { return i.Network.Interfaces },
+	"users":      func(i *sysinfo.SystemInfo) interface{} { return i.Users },
+	"services":   func(i *sysinfo.SystemInfo) interface{} { return i.SystemServices },
+	"sensors":    func(i *sysinfo.SystemInfo) interface{} { return i.Temperature.Sensors },
+	"errors":     func(i *sysinfo.SystemInfo) interface{} { return i.Errors },
+}
+
+// sectionNames returns the valid --section values in sorted order
+func sectionNames() []string {
+	names := make([]string, 0, len(infoSections))
+	for name := range infoSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateOutput checks the global --output flag
+func validateOutput() error {
+	for _, f := range outputFormats {
+		if outputFormat == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format %q (supported: %s)", outputFormat, strings.Join(outputFormats, ", "))
+}
+
+// writeSystemInfo renders info in the selected machine-readable format.
+// With --section only that table is written; csv always needs one.
+func writeSystemInfo(w io.Writer, info *sysinfo.SystemInfo) error {
+	if sectionName == "" {
+		if outputFormat == outputCSV {
+			return fmt.Errorf("csv output needs --section (one of: %s)", strings.Join(sectionNames(), ", "))
+		}
+		return writeDocument(w, info)
+	}
+
+	rows, ok := infoSections[sectionName]
+	if !ok {
+		return fmt.Errorf("unknown section %q (available: %s)", sectionName, strings.Join(sectionNames(), ", "))
+	}
+	return writeRows(w, rows(info))
+}
+
+// writeDocument writes a single value as json, yaml or one ndjson line
+func writeDocument(w io.Writer, v interface{}) error {
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputNDJSON:
+		return json.NewEncoder(w).Encode(v)
+	case outputYAML:
+		return writeYAML(w, v)
+	}
+	return fmt.Errorf("%s output is not supported here", outputFormat)
+}
+
+// writeRows writes a slice of flat structs as a json array, yaml list,
+// csv table or one ndjson line per row
+func writeRows(w io.Writer, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+	if v.IsNil() {
+		// Render an empty table rather than null
+		v = reflect.MakeSlice(v.Type(), 0, 0)
+	}
+
+	switch outputFormat {
+	case outputCSV:
+		return writeCSV(w, v)
+	case outputNDJSON:
+		enc := json.NewEncoder(w)
+		for i := 0; i < v.Len(); i++ {
+			if err := enc.Encode(v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return writeDocument(w, v.Interface())
+}
+
+// writeYAML converts through JSON so the output uses the same field names
+// and ordering as the json tags
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle clears the flow and quoting styles JSON input leaves on every
+// node, so the encoder emits idiomatic block YAML
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// writeCSV writes one row per element with a header taken from json tags
+func writeCSV(w io.Writer, rows reflect.Value) error {
+	columns, header := csvColumns(rows.Type().Elem(), nil)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		record := make([]string, len(columns))
+		for j, col := range columns {
+			record[j] = csvValue(row.FieldByIndex(col))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvColumns returns the field index and name of each column of a struct
+// type, flattening embedded structs the way encoding/json does
+func csvColumns(t reflect.Type, parent []int) ([][]int, []string) {
+	var columns [][]int
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(append([]int(nil), parent...), i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			cols, names := csvColumns(field.Type, index)
+			columns = append(columns, cols...)
+			header = append(header, names...)
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, index)
+		header = append(header, name)
+	}
+	return columns, header
+}
+
+func csvValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	// Times and similar values have a natural text form
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = csvValue(v.Index(i))
+		}
+		return strings.Join(parts, ";")
+	}
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(v.Interface())
+	return strings.TrimSpace(buf.String())
+}
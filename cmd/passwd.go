@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/junler/sysinfo/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+var passwdRole string
+
+var passwdCmd = &cobra.Command{
+	Use:   "passwd <user>",
+	Short: "Print a password file line for serve --auth-users",
+	Long: `Read a password and print a "name:bcrypt-hash:role" line to add to the
+password file of "sysinfo serve --auth-users".
+
+On a terminal the password is asked for twice without echoing it; otherwise
+the first line of standard input is used, e.g.
+  echo "$PASSWORD" | sysinfo passwd alice --role admin >> users.txt`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		role, err := auth.ParseRole(passwdRole)
+		if err != nil {
+			return err
+		}
+		password, err := readPassword()
+		if err != nil {
+			return &exitError{exitFailure, err}
+		}
+		line, err := auth.HashPassword(args[0], password, role)
+		if err != nil {
+			return &exitError{exitFailure, err}
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), line)
+		return nil
+	},
+}
+
+// readPassword prompts for a password on a terminal, or reads the first
+// line of standard input
+func readPassword() (string, error) {
+	in := bufio.NewReader(os.Stdin)
+	readLine := func() (string, error) {
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	restore, err := disableEcho(os.Stdin.Fd())
+	if err != nil {
+		// Not a terminal
+		password, err := readLine()
+		if err == nil && password == "" {
+			err = errors.New("empty password")
+		}
+		return password, err
+	}
+	defer restore()
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := readLine()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	again, err := readLine()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if password != again {
+		return "", errors.New("passwords do not match")
+	}
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
+
+func init() {
+	passwdCmd.Flags().StringVar(&passwdRole, "role", string(auth.RoleReadOnly), "Role of the user: readonly or admin")
+	rootCmd.AddCommand(passwdCmd)
+}
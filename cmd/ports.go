@@ -2,19 +2,19 @@ package cmd
 
 import (
 	"fmt"
-
-	"github.com/junler/sysinfo/internal/sysinfo"
 	"github.com/spf13/cobra"
 )
 
 var portsCmd = &cobra.Command{
 	Use:   "ports",
 	Short: "Show open ports and their status",
-	Run: func(cmd *cobra.Command, args []string) {
-		ports, err := sysinfo.GetOpenPorts()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ports, err := openPorts(cmd.Context())
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return &exitError{exitFailure, err}
+		}
+		if outputFormat != outputTable {
+			return writeRows(cmd.OutOrStdout(), ports)
 		}
 
 		fmt.Println("=== Open Ports ===")
@@ -34,9 +34,11 @@ var portsCmd = &cobra.Command{
 		} else {
 			fmt.Printf("\nTotal: %d open ports\n", len(ports))
 		}
+		return nil
 	},
 }
 
 func init() {
+	supportsRemote(portsCmd)
 	rootCmd.AddCommand(portsCmd)
 }
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/junler/sysinfo/internal/remote"
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+// remoteTokenEnv holds the bearer token for --remote when --remote-token
+// isn't given, keeping it out of the process list
+const remoteTokenEnv = "SYSINFO_REMOTE_TOKEN"
+
+// annotationRemote marks commands that can read from a server with --remote
+const annotationRemote = "remote"
+
+var (
+	remoteURL     string
+	remoteOptions remote.Options
+	remoteClient  *remote.Client
+)
+
+// supportsRemote marks cmd as able to run against a --remote server
+func supportsRemote(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[annotationRemote] = "true"
+}
+
+// setupRemote connects to the --remote server, if one was given, for
+// commands that support it
+func setupRemote(cmd *cobra.Command) error {
+	if remoteURL == "" {
+		return nil
+	}
+	if cmd.Annotations[annotationRemote] != "true" {
+		return fmt.Errorf("%s does not support --remote", cmd.CommandPath())
+	}
+	client, err := remote.NewClient(remoteURL, remoteConnOptions())
+	if err != nil {
+		return fmt.Errorf("--remote: %w", err)
+	}
+	remoteClient = client
+	return nil
+}
+
+// remoteConnOptions returns the token and TLS settings given with the
+// --remote-* flags
+func remoteConnOptions() remote.Options {
+	opts := remoteOptions
+	if opts.Token == "" {
+		opts.Token = os.Getenv(remoteTokenEnv)
+	}
+	return opts
+}
+
+// collectInfo collects system information from the --remote server, or
+// from this host without one, and returns when it was collected
+func collectInfo(ctx context.Context, opts sysinfo.Options) (*sysinfo.SystemInfo, time.Time, error) {
+	if remoteClient == nil {
+		info, err := sysinfo.GetSystemInfoContext(ctx, opts)
+		return info, time.Now(), err
+	}
+	return remoteClient.Info(ctx, opts.Collectors)
+}
+
+// openPorts lists the listening ports of the --remote server, or of this
+// host without one
+func openPorts(ctx context.Context) ([]sysinfo.PortInfo, error) {
+	if remoteClient == nil {
+		return sysinfo.GetOpenPorts()
+	}
+	return remoteClient.Ports(ctx)
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&remoteURL, "remote", "", "Read from a running sysinfo server instead of this host, e.g. http://host:8080 (info, monitor, ports)")
+	flags.StringVar(&remoteOptions.Token, "remote-token", "", "Bearer token for --remote (default $"+remoteTokenEnv+")")
+	flags.DurationVar(&remoteOptions.Timeout, "remote-timeout", remote.DefaultTimeout, "Deadline for each request to --remote")
+	flags.StringVar(&remoteOptions.CAFile, "remote-ca", "", "PEM file with the CA certificates that sign the --remote server's certificate")
+	flags.StringVar(&remoteOptions.CertFile, "remote-cert", "", "Client certificate to present to --remote")
+	flags.StringVar(&remoteOptions.KeyFile, "remote-key", "", "Private key of --remote-cert")
+	flags.BoolVar(&remoteOptions.Insecure, "remote-insecure", false, "Skip verifying the --remote server's TLS certificate")
+}
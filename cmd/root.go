@@ -1,20 +1,106 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/junler/sysinfo/internal/sysinfo"
 	"github.com/spf13/cobra"
 )
 
+var (
+	collectTimeout time.Duration
+	collectorNames []string
+)
+
+// Exit codes for collection failures
+const (
+	exitFailure = 1 // nothing could be collected
+	exitPartial = 2 // some collectors failed
+)
+
 var rootCmd = &cobra.Command{
-	Use:   "sysinfo",
-	Short: "A CLI tool to show system information",
+	Use:           "sysinfo",
+	Short:         "A CLI tool to show system information",
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutput(); err != nil {
+			return err
+		}
+		// Flags are valid, so later errors are runtime failures, not usage mistakes
+		cmd.SilenceUsage = true
+		return setupRemote(cmd)
+	},
+}
+
+// exitError carries a specific process exit code out of a command, err is
+// nil when the command has already reported the problem
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+func (e *exitError) Unwrap() error { return e.err }
+
+// collectionError maps the outcome of a collection to the command's error:
+// exitFailure when nothing was collected, exitPartial when some collectors
+// failed, nil otherwise
+func collectionError(info *sysinfo.SystemInfo, err error) error {
+	if err != nil {
+		return &exitError{exitFailure, err}
+	}
+	if len(info.Errors) > 0 {
+		var sections []string
+		for _, e := range info.Errors {
+			sections = append(sections, e.Section)
+		}
+		return &exitError{exitPartial, fmt.Errorf("collection incomplete, failed collectors: %s", strings.Join(sections, ", "))}
+	}
+	return nil
+}
+
+// collectOptions builds the collection options from the global flags
+func collectOptions() sysinfo.Options {
+	return sysinfo.Options{Timeout: collectTimeout, Collectors: collectorNames}
+}
+
+// addSectionFlag registers --section on a command that outputs SystemInfo
+func addSectionFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&sectionName, "section", "",
+		"Only output one table in json/yaml/csv/ndjson mode ("+strings.Join(sectionNames(), ", ")+")")
+}
+
+// addCollectorsFlag registers --collectors on a command that collects system information
+func addCollectorsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&collectorNames, "collectors", nil,
+		"Collectors to run, e.g. cpu,memory or -services to disable one ("+
+			strings.Join(sysinfo.CollectorNames(), ", ")+")")
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		var exit *exitError
+		if !errors.As(err, &exit) {
+			exit = &exitError{exitFailure, err}
+		}
+		// Commands that already reported the problem return no error message
+		if exit.err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(exit.code)
 	}
 }
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: "+strings.Join(outputFormats, ", "))
+	rootCmd.PersistentFlags().DurationVar(&collectTimeout, "timeout", sysinfo.DefaultSectionTimeout, "Deadline for each individual collector")
+}
@@ -1,25 +1,294 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/junler/sysinfo/internal/alert"
+	"github.com/junler/sysinfo/internal/anomaly"
+	"github.com/junler/sysinfo/internal/auth"
+	"github.com/junler/sysinfo/internal/fleet"
+	"github.com/junler/sysinfo/internal/history"
+	"github.com/junler/sysinfo/internal/notify"
+	"github.com/junler/sysinfo/internal/sysinfo"
 	"github.com/junler/sysinfo/internal/webserver"
 	"github.com/spf13/cobra"
 )
 
-var port string
+var (
+	port        string
+	cpuInterval time.Duration
+	cacheTTL    time.Duration
+	metricsOnly bool
+
+	historyResolution  time.Duration
+	historyRetention   time.Duration
+	historyRetention1m time.Duration
+	historyRetention1h time.Duration
+	dataDir            string
+	forecastWindow     time.Duration
+
+	alertRules    string
+	alertInterval time.Duration
+
+	notifyConfig string
+
+	anomalyInterval  time.Duration
+	anomalyThreshold float64
+
+	central            bool
+	agentTokens        []string
+	allowAnonymousPush bool
+	maxAgents          int
+	agentTTL           time.Duration
+	nodesFile          string
+	pollInterval       time.Duration
+
+	authUsersFile  string
+	authTokensFile string
+
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsClientCAFile string
+	tlsRedirectPort string
+)
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the web server",
 	Long:  "Start the web server to display system information in a web interface",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Starting web server on port %s...\n", port)
-		fmt.Printf("Open http://localhost:%s in your browser\n", port)
+		if _, err := sysinfo.ResolveCollectors(collectorNames); err != nil {
+			log.Fatal(err)
+		}
+		tlsConfig, err := serveTLSConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Pushes need a token unless explicitly opened up; polling alone
+		// takes no pushes
+		if central && len(agentTokens) == 0 {
+			if token := os.Getenv(agentTokenEnv); token != "" {
+				agentTokens = []string{token}
+			} else if !allowAnonymousPush {
+				log.Fatalf("--central needs --agent-token or $%s; pass --allow-anonymous-push to accept pushes from any client", agentTokenEnv)
+			} else {
+				log.Print("Warning: no --agent-token set, any client can push snapshots")
+			}
+		}
+		scheme := "http"
+		if tlsConfig != nil {
+			scheme = "https"
+		}
+
+		if metricsOnly {
+			fmt.Printf("Starting metrics exporter on port %s...\n", port)
+			fmt.Printf("Metrics available at %s://localhost:%s/metrics\n", scheme, port)
+		} else {
+			fmt.Printf("Starting web server on port %s...\n", port)
+			fmt.Printf("Open %s://localhost:%s in your browser\n", scheme, port)
+		}
+
+		// Stop on SIGINT or SIGTERM by shutting the server down and
+		// returning, so the deferred cleanup below runs
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Sample CPU usage in the background so API requests don't block
+		sysinfo.StartCPUSampler(cpuInterval)
+		defer sysinfo.StopCPUSampler()
+
+		// Predict when disks fill up from the usage seen by collections,
+		// keeping the samples in the data directory across restarts
+		if forecastWindow > 0 {
+			state := ""
+			if dataDir != "" {
+				state = filepath.Join(dataDir, "disk-forecast.json")
+			}
+			sysinfo.SetDiskForecaster(sysinfo.NewDiskForecaster(forecastWindow, state))
+		}
+
+		// One collection loop in the background records history for
+		// /api/history and hands the same samples to notifications, alert
+		// rules and anomaly detection below
+		recorder := &history.Recorder{Timeout: collectTimeout}
+		var store history.Store
+		if !metricsOnly && historyResolution > 0 {
+			if dataDir != "" {
+				disk, err := history.OpenDiskStore(dataDir, history.DiskOptions{
+					Retention:   historyRetention,
+					Retention1m: historyRetention1m,
+					Retention1h: historyRetention1h,
+				})
+				if err != nil {
+					log.Fatal(err)
+				}
+				defer disk.Close()
+				store = disk
+			} else {
+				store = history.NewMemoryStore(historyRetention, historyResolution)
+			}
+			recorder.Store, recorder.Interval = store, historyResolution
+		}
+
+		// Deliver host events, and alert and anomaly changes below, to
+		// notification channels
+		var notifier *notify.Notifier
+		if notifyConfig != "" {
+			cfg, err := notify.LoadConfig(notifyConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			notifier = notify.New(cfg)
+			defer func() {
+				// Send the groups still waiting, giving up on slow channels
+				closeCtx, cancel := context.WithTimeout(context.Background(), notifyCloseTimeout)
+				defer cancel()
+				notifier.Close(closeCtx)
+			}()
+			watcher := notify.NewWatcher(cfg.Events)
+			recorder.Collectors = append(recorder.Collectors, watcher.Collectors()...)
+			recorder.Listen(cfg.Events.Interval, func(_ time.Time, info *sysinfo.SystemInfo, _ map[string]float64) {
+				if events := watcher.Observe(info); len(events) > 0 {
+					notifier.Notify(events...)
+				}
+			})
+		}
 
-		server := webserver.NewWebServer(port)
-		if err := server.Start(); err != nil {
+		// Evaluate alert rules in the background for /api/alerts
+		var alerts *alert.Engine
+		if alertRules != "" {
+			rules, err := alert.LoadRules(alertRules)
+			if err != nil {
+				log.Fatal(err)
+			}
+			alerts = alert.NewEngine(rules)
+			onChange := logAlerts
+			if notifier != nil {
+				onChange = func(changed []alert.Alert) {
+					logAlerts(changed)
+					if events := alertEvents(changed); len(events) > 0 {
+						notifier.Notify(events...)
+					}
+				}
+			}
+			recorder.Listen(alertInterval, func(t time.Time, _ *sysinfo.SystemInfo, values map[string]float64) {
+				if changed := alerts.Evaluate(t, values); len(changed) > 0 {
+					onChange(changed)
+				}
+			})
+		}
+
+		// Learn the normal range of core metrics and flag deviations for
+		// /api/anomalies, keeping the baselines in the data directory
+		var detector *anomaly.Detector
+		if !metricsOnly && anomalyInterval > 0 {
+			state := ""
+			if dataDir != "" {
+				state = filepath.Join(dataDir, "anomaly-baselines.json")
+			}
+			detector = anomaly.NewDetector(anomaly.Config{Threshold: anomalyThreshold, StatePath: state})
+			if state != "" {
+				// Observe saves every few minutes; keep what was learned
+				// since then when serve stops
+				defer func() {
+					if err := detector.Save(); err != nil {
+						log.Printf("anomaly: %v", err)
+					}
+				}()
+			}
+			onChange := logAnomalies
+			if notifier != nil {
+				onChange = func(changed []anomaly.Anomaly) {
+					logAnomalies(changed)
+					notifier.Notify(anomalyEvents(changed)...)
+				}
+			}
+			recorder.Listen(anomalyInterval, func(t time.Time, _ *sysinfo.SystemInfo, values map[string]float64) {
+				if changed := detector.Observe(t, values); len(changed) > 0 {
+					onChange(changed)
+				}
+			})
+		}
+
+		if recorder.Enabled() {
+			// Let the last sample reach the listeners before the cleanup
+			// above runs
+			done := make(chan struct{})
+			go func() {
+				recorder.Run(ctx)
+				close(done)
+			}()
+			defer func() { <-done }()
+		}
+
+		// Accept snapshots pushed by "sysinfo agent", and poll the servers
+		// in --nodes, for /api/nodes and the fleet overview
+		var registry *fleet.Registry
+		if central || nodesFile != "" {
+			registry = fleet.NewRegistry(fleet.RegistryConfig{
+				HistoryRetention:  historyRetention,
+				HistoryResolution: historyResolution,
+				MaxPushedNodes:    maxAgents,
+				PushedNodeTTL:     agentTTL,
+			})
+			if nodesFile != "" {
+				targets, err := fleet.LoadTargets(nodesFile)
+				if err != nil {
+					log.Fatal(err)
+				}
+				// Polled servers may need the same token and TLS settings
+				// as "--remote"
+				opts := remoteConnOptions()
+				client, err := opts.HTTPClient()
+				if err != nil {
+					log.Fatal(err)
+				}
+				poller := &fleet.Poller{Registry: registry, Targets: targets, Interval: pollInterval, Token: opts.Token, Client: client}
+				go poller.Run(ctx)
+			}
+			fmt.Printf("Fleet overview at %s://localhost:%s/fleet\n", scheme, port)
+		}
+
+		// Require a token or password for everything but health checks
+		var authenticators []auth.Authenticator
+		if authTokensFile != "" {
+			tokens, err := auth.LoadTokens(authTokensFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			authenticators = append(authenticators, tokens)
+		}
+		if authUsersFile != "" {
+			users, err := auth.LoadUsers(authUsersFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			authenticators = append(authenticators, users)
+		}
+
+		server := webserver.NewWebServer(webserver.Config{
+			Port:           port,
+			CollectOptions: collectOptions(),
+			CacheTTL:       cacheTTL,
+			MetricsOnly:    metricsOnly,
+			History:        store,
+			Alerts:         alerts,
+			Anomalies:      detector,
+			Central:        registry,
+			AcceptPushes:   central,
+			AgentTokens:    agentTokens,
+			Auth:           authenticators,
+			TLS:            tlsConfig,
+		})
+		if err := server.Start(ctx); err != nil {
 			log.Fatal("Failed to start web server:", err)
 		}
 	},
@@ -27,5 +296,84 @@ var serveCmd = &cobra.Command{
 
 func init() {
 	serveCmd.Flags().StringVarP(&port, "port", "p", "8080", "Port to run the web server on")
+	serveCmd.Flags().DurationVar(&cpuInterval, "cpu-interval", sysinfo.DefaultSampleInterval, "Interval between background CPU usage samples")
+	serveCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", webserver.DefaultCacheTTL, "How long a collected snapshot is shared between API requests (0 disables caching)")
+	serveCmd.Flags().BoolVar(&metricsOnly, "metrics-only", false, "Serve only /metrics and /api/health, without the web UI and JSON API")
+	serveCmd.Flags().DurationVar(&historyResolution, "history-resolution", history.DefaultResolution, "Interval between samples recorded for /api/history (0 disables history)")
+	serveCmd.Flags().DurationVar(&historyRetention, "history-retention", history.DefaultRetention, "How long raw history samples are kept")
+	serveCmd.Flags().DurationVar(&historyRetention1m, "history-retention-1m", history.DefaultRetention1m, "How long 1 minute rollups are kept with --data-dir")
+	serveCmd.Flags().DurationVar(&historyRetention1h, "history-retention-1h", history.DefaultRetention1h, "How long 1 hour rollups are kept with --data-dir")
+	serveCmd.Flags().StringVar(&dataDir, "data-dir", "", "Directory to persist history in (kept in memory when empty)")
+	serveCmd.Flags().DurationVar(&forecastWindow, "forecast-window", sysinfo.DefaultForecastWindow, "How much disk usage history predicted_full_at is fitted to (0 disables disk forecasts)")
+	serveCmd.Flags().StringVar(&alertRules, "alert-rules", "", "Alert rules file to evaluate, see /api/alerts")
+	serveCmd.Flags().DurationVar(&alertInterval, "alert-interval", defaultAlertInterval, "Interval between alert rule evaluations")
+	serveCmd.Flags().StringVar(&notifyConfig, "notify-config", "", "Notification config file; host events, alert and anomaly changes are sent to its channels")
+	serveCmd.Flags().DurationVar(&anomalyInterval, "anomaly-interval", defaultAnomalyInterval, "Interval between samples checked for anomalies, see /api/anomalies (0 disables anomaly detection)")
+	serveCmd.Flags().Float64Var(&anomalyThreshold, "anomaly-threshold", anomaly.DefaultThreshold, "Deviation from the baseline, in standard deviations, that counts as an anomaly")
+	serveCmd.Flags().BoolVar(&central, "central", false, "Accept snapshots pushed by \"sysinfo agent\" and serve them under /api/nodes")
+	serveCmd.Flags().StringVar(&nodesFile, "nodes", "", "File listing sysinfo servers to poll, one \"[name] URL\" per line; serves /api/nodes and /fleet, but takes agent pushes only with --central")
+	serveCmd.Flags().DurationVar(&pollInterval, "poll-interval", fleet.DefaultPollInterval, "Interval between polls of the --nodes servers")
+	serveCmd.Flags().StringSliceVar(&agentTokens, "agent-token", nil, "Token agents must push with (repeatable, default $"+agentTokenEnv+"); required by --central")
+	serveCmd.Flags().BoolVar(&allowAnonymousPush, "allow-anonymous-push", false, "Let --central accept pushes without an agent token")
+	serveCmd.Flags().IntVar(&maxAgents, "max-agents", fleet.DefaultMaxPushedNodes, "Most pushing nodes --central keeps; pushes from further nodes are refused")
+	serveCmd.Flags().DurationVar(&agentTTL, "agent-ttl", fleet.DefaultPushedNodeTTL, "How long --central keeps a node after its last push")
+	serveCmd.Flags().StringVar(&authTokensFile, "auth-tokens", "", "File of API tokens, one \"name:token[:role]\" per line; enables authentication")
+	serveCmd.Flags().StringVar(&authUsersFile, "auth-users", "", "Password file, one \"name:bcrypt-hash[:role]\" per line; enables authentication and the login page")
+	serveCmd.Flags().StringVar(&tlsCertFile, "tls-cert", "", "PEM certificate file; serves HTTPS, generating a self-signed certificate if it and --tls-key don't exist")
+	serveCmd.Flags().StringVar(&tlsKeyFile, "tls-key", "", "PEM private key file for --tls-cert")
+	serveCmd.Flags().StringVar(&tlsClientCAFile, "tls-client-ca", "", "PEM CA bundle client certificates must be signed by; clients without one are refused")
+	serveCmd.Flags().StringVar(&tlsRedirectPort, "tls-redirect-port", "", "Port to serve plain HTTP on, redirecting every request to HTTPS")
+	addCollectorsFlag(serveCmd)
 	rootCmd.AddCommand(serveCmd)
 }
+
+// serveTLSConfig returns the HTTPS settings from the --tls flags, or nil
+// to serve plain HTTP
+func serveTLSConfig() (*webserver.TLSConfig, error) {
+	if tlsCertFile == "" && tlsKeyFile == "" {
+		if tlsClientCAFile != "" || tlsRedirectPort != "" {
+			return nil, errors.New("--tls-client-ca and --tls-redirect-port need --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	if tlsCertFile == "" || tlsKeyFile == "" {
+		return nil, errors.New("--tls-cert and --tls-key must be given together")
+	}
+	if tlsRedirectPort == port {
+		return nil, fmt.Errorf("--tls-redirect-port %s is the HTTPS port", port)
+	}
+	return &webserver.TLSConfig{
+		CertFile:     tlsCertFile,
+		KeyFile:      tlsKeyFile,
+		ClientCAFile: tlsClientCAFile,
+		RedirectPort: tlsRedirectPort,
+	}, nil
+}
+
+// defaultAlertInterval is how often serve evaluates alert rules by default
+const defaultAlertInterval = 15 * time.Second
+
+// notifyCloseTimeout bounds how long serve spends delivering pending
+// notifications when it stops
+const notifyCloseTimeout = 10 * time.Second
+
+// logAlerts logs alert state changes
+func logAlerts(changed []alert.Alert) {
+	for _, a := range changed {
+		name := a.Rule
+		if a.Instance != "" {
+			name += "[" + a.Instance + "]"
+		}
+		log.Printf("alert %s %s (%s, value %.2f)", name, a.State, a.Severity, a.Value)
+	}
+}
+
+// defaultAnomalyInterval is how often serve checks metrics for anomalies
+const defaultAnomalyInterval = 15 * time.Second
+
+// logAnomalies logs anomalies that started or ended
+func logAnomalies(changed []anomaly.Anomaly) {
+	for _, a := range changed {
+		log.Printf("anomaly %s %s (value %.2f, expected %.2f, score %+.1f)", a.Metric, a.State, a.Value, a.Expected, a.Score)
+	}
+}
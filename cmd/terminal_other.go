@@ -0,0 +1,24 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// terminalSize is not implemented on this platform, so watch mode falls
+// back to printing one snapshot after another
+func terminalSize(fd uintptr) (width, height int, ok bool) {
+	return 0, 0, false
+}
+
+func notifyResize(c chan<- os.Signal) {}
+
+func makeRaw(fd uintptr) (restore func(), err error) {
+	return nil, errors.New("interactive mode is not supported on this platform")
+}
+
+func disableEcho(fd uintptr) (restore func(), err error) {
+	return nil, errors.New("hiding input is not supported on this platform")
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalSize returns the size of the terminal on fd, ok is false when fd
+// is not a terminal
+func terminalSize(fd uintptr) (width, height int, ok bool) {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, false
+	}
+	return int(ws.Col), int(ws.Row), true
+}
+
+// notifyResize relays terminal resize events to c
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, unix.SIGWINCH)
+}
+
+// makeRaw puts the terminal on fd into raw mode so single keystrokes can be
+// read, and returns a function that restores the previous state. Output
+// processing is left on so "\n" still starts a new line.
+func makeRaw(fd uintptr) (restore func(), err error) {
+	old, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(int(fd), ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() { unix.IoctlSetTermios(int(fd), ioctlSetTermios, old) }, nil
+}
+
+// disableEcho stops the terminal on fd from echoing typed characters, for
+// reading passwords, and returns a function that restores the previous state
+func disableEcho(fd uintptr) (restore func(), err error) {
+	old, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	quiet := *old
+	quiet.Lflag &^= unix.ECHO
+	quiet.Lflag |= unix.ICANON | unix.ISIG
+	if err := unix.IoctlSetTermios(int(fd), ioctlSetTermios, &quiet); err != nil {
+		return nil, err
+	}
+	return func() { unix.IoctlSetTermios(int(fd), ioctlSetTermios, old) }, nil
+}
@@ -0,0 +1,599 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+var topInterval time.Duration
+
+// topCollectors are the sections the top screen shows besides the process
+// table, which is collected separately with every process included
+var topCollectors = []string{
+	sysinfo.SectionHost, sysinfo.SectionCPU, sysinfo.SectionMemory, sysinfo.SectionSwap,
+	sysinfo.SectionLoad, sysinfo.SectionNetwork, sysinfo.SectionIO,
+}
+
+// topHistory is how many samples the throughput sparklines keep
+const topHistory = 120
+
+// Control sequences only used by the full-screen UI
+const (
+	ansiAltScreen  = "\033[?1049h"
+	ansiMainScreen = "\033[?1049l"
+	ansiReverse    = "\033[7m"
+	ansiReset      = "\033[0m"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Interactive full-screen system monitor",
+	Long: `Interactive terminal UI with per-core CPU bars, memory and swap gauges,
+network and disk throughput sparklines and a scrollable process table.
+CPU% is each process's usage since the previous refresh.
+
+Keys:
+  c m r t p     sort by CPU, memory, RSS, threads or PID (again to reverse)
+  /             filter processes by name, user or command line
+  Esc           clear the filter
+  ↑ ↓ j k       scroll one line
+  PgUp PgDn     scroll one page
+  Home End g G  jump to the top or bottom
+  q Ctrl-C      quit`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if topInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		return runTop(cmd.Context(), os.Stdin, os.Stdout)
+	},
+}
+
+// topSnapshot is one refresh of everything the top screen shows
+type topSnapshot struct {
+	info    *sysinfo.SystemInfo
+	procs   []sysinfo.ProcessInfo
+	procErr error
+	at      time.Time
+}
+
+func runTop(ctx context.Context, in, out *os.File) error {
+	if _, _, ok := terminalSize(out.Fd()); !ok {
+		return errors.New("top needs an interactive terminal, use monitor --watch when output is redirected")
+	}
+	restore, err := makeRaw(in.Fd())
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	fmt.Fprint(out, ansiAltScreen+ansiHideCursor)
+	defer fmt.Fprint(out, ansiShowCursor+ansiMainScreen)
+
+	// Ctrl-C arrives as a key in raw mode, these cover kill and hangup
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+
+	keys := make(chan []byte)
+	go readKeys(in, keys)
+
+	snapshots := make(chan topSnapshot)
+	go collectTop(ctx, topInterval, snapshots)
+
+	t := &topModel{sort: 'c'}
+	for {
+		width, height, _ := terminalSize(out.Fd())
+		drawTop(out, t.render(width, height))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-resized:
+		case s := <-snapshots:
+			t.update(s)
+		case k, ok := <-keys:
+			if !ok || t.handleKeys(k) {
+				return nil
+			}
+		}
+	}
+}
+
+// readKeys forwards raw input from the terminal until it is closed
+func readKeys(r io.Reader, keys chan<- []byte) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// collectTop sends a snapshot every interval until ctx is done. Collection
+// runs here so the screen keeps reacting to keys while it is in progress.
+func collectTop(ctx context.Context, interval time.Duration, snapshots chan<- topSnapshot) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var s topSnapshot
+		s.info, _ = sysinfo.GetSystemInfoContext(ctx, sysinfo.Options{Timeout: collectTimeout, Collectors: topCollectors})
+		s.procs, s.procErr = sysinfo.GetProcesses(ctx)
+		s.at = time.Now()
+
+		select {
+		case snapshots <- s:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drawTop replaces the screen with lines that already fit the terminal
+func drawTop(w io.Writer, lines []string) {
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(line)
+		b.WriteString(ansiClearLine)
+	}
+	b.WriteString(ansiClearBelow)
+	io.WriteString(w, b.String())
+}
+
+// topSort orders the process table; less reports whether a is listed before b
+type topSort struct {
+	title string
+	less  func(a, b *sysinfo.ProcessInfo) bool
+}
+
+var topSorts = map[rune]topSort{
+	'c': {"CPU%", func(a, b *sysinfo.ProcessInfo) bool { return a.CPUPercent > b.CPUPercent }},
+	'm': {"MEM%", func(a, b *sysinfo.ProcessInfo) bool { return a.MemPercent > b.MemPercent }},
+	'r': {"RSS", func(a, b *sysinfo.ProcessInfo) bool { return a.MemoryRSS > b.MemoryRSS }},
+	't': {"THR", func(a, b *sysinfo.ProcessInfo) bool { return a.NumThreads > b.NumThreads }},
+	'p': {"PID", func(a, b *sysinfo.ProcessInfo) bool { return a.PID < b.PID }},
+}
+
+// topModel is the state of the top screen between redraws
+type topModel struct {
+	last  *topSnapshot
+	rates *sysinfo.Rates
+
+	netRecv, netSent    []float64
+	diskRead, diskWrite []float64
+
+	sort    rune
+	reverse bool
+	filter  string
+	editing bool
+	offset  int
+	page    int
+}
+
+// update records a new snapshot and extends the sparkline history
+func (t *topModel) update(s topSnapshot) {
+	if t.last != nil {
+		intervalCPU(t.last, &s)
+	}
+	if t.last != nil && t.last.info != nil && s.info != nil {
+		r := sysinfo.NewRates(t.last.info, s.info, s.at.Sub(t.last.at))
+		t.rates = &r
+		if r.Network {
+			t.netRecv = pushSample(t.netRecv, r.NetRecv)
+			t.netSent = pushSample(t.netSent, r.NetSent)
+		}
+		if r.IO {
+			t.diskRead = pushSample(t.diskRead, r.DiskRead)
+			t.diskWrite = pushSample(t.diskWrite, r.DiskWrite)
+		}
+	}
+	t.last = &s
+}
+
+// intervalCPU replaces the lifetime CPU averages of the processes in s
+// with their usage since prev, so a process busy right now sorts above a
+// long running one that was busy in the past
+func intervalCPU(prev, s *topSnapshot) {
+	elapsed := s.at.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	// The create time tells a reused PID from the process that had it
+	type procKey struct {
+		pid     int32
+		created int64
+	}
+	before := make(map[procKey]float64, len(prev.procs))
+	for _, p := range prev.procs {
+		before[procKey{p.PID, p.CreateTime}] = p.CPUTime
+	}
+	for i := range s.procs {
+		p := &s.procs[i]
+		used, ok := before[procKey{p.PID, p.CreateTime}]
+		window := elapsed
+		if !ok {
+			created := time.UnixMilli(p.CreateTime)
+			if created.Before(prev.at) {
+				// Missed by the previous snapshot, keep its lifetime average
+				continue
+			}
+			window = s.at.Sub(created).Seconds()
+		}
+		if window > 0 {
+			p.CPUPercent = max(0, 100*(p.CPUTime-used)/window)
+		}
+	}
+}
+
+func pushSample(samples []float64, v float64) []float64 {
+	samples = append(samples, v)
+	if len(samples) > topHistory {
+		samples = samples[len(samples)-topHistory:]
+	}
+	return samples
+}
+
+// handleKeys applies one read from the terminal and reports whether to quit
+func (t *topModel) handleKeys(b []byte) bool {
+	// A read starting with Esc is a single key sequence such as an arrow
+	if b[0] == 0x1b {
+		t.handleSequence(string(b))
+		return false
+	}
+	for _, r := range string(b) {
+		if t.handleKey(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *topModel) handleSequence(seq string) {
+	if t.editing {
+		if seq == "\x1b" {
+			t.filter, t.editing = "", false
+		}
+		return
+	}
+	switch seq {
+	case "\x1b":
+		t.filter = ""
+	case "\x1b[A", "\x1bOA":
+		t.offset--
+	case "\x1b[B", "\x1bOB":
+		t.offset++
+	case "\x1b[5~":
+		t.offset -= t.page
+	case "\x1b[6~":
+		t.offset += t.page
+	case "\x1b[H", "\x1bOH", "\x1b[1~":
+		t.offset = 0
+	case "\x1b[F", "\x1bOF", "\x1b[4~":
+		t.offset = math.MaxInt32
+	}
+}
+
+func (t *topModel) handleKey(r rune) bool {
+	if r == 0x03 { // Ctrl-C
+		return true
+	}
+
+	if t.editing {
+		switch {
+		case r == '\r' || r == '\n':
+			t.editing = false
+		case r == 0x7f || r == 0x08:
+			if n := len(t.filter); n > 0 {
+				_, size := utf8.DecodeLastRuneInString(t.filter)
+				t.filter = t.filter[:n-size]
+			}
+		case unicode.IsPrint(r):
+			t.filter += string(r)
+			t.offset = 0
+		}
+		return false
+	}
+
+	switch r {
+	case 'q':
+		return true
+	case '/':
+		t.editing = true
+	case 'j':
+		t.offset++
+	case 'k':
+		t.offset--
+	case 'g':
+		t.offset = 0
+	case 'G':
+		t.offset = math.MaxInt32
+	default:
+		if _, ok := topSorts[r]; ok {
+			if t.sort == r {
+				t.reverse = !t.reverse
+			} else {
+				t.sort, t.reverse = r, false
+			}
+		}
+	}
+	return false
+}
+
+// processes returns the filtered and sorted process table
+func (t *topModel) processes() []sysinfo.ProcessInfo {
+	if t.last == nil {
+		return nil
+	}
+
+	filter := strings.ToLower(t.filter)
+	procs := make([]sysinfo.ProcessInfo, 0, len(t.last.procs))
+	for _, p := range t.last.procs {
+		if filter == "" ||
+			strings.Contains(strings.ToLower(p.Name), filter) ||
+			strings.Contains(strings.ToLower(p.Username), filter) ||
+			strings.Contains(strings.ToLower(p.CommandLine), filter) {
+			procs = append(procs, p)
+		}
+	}
+
+	less := topSorts[t.sort].less
+	sort.SliceStable(procs, func(i, j int) bool {
+		a, b := &procs[i], &procs[j]
+		if t.reverse {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.PID < b.PID
+	})
+	return procs
+}
+
+// render lays out the whole screen for a terminal of the given size
+func (t *topModel) render(width, height int) []string {
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+
+	var lines []string
+	add := func(format string, a ...interface{}) {
+		lines = append(lines, clipLine(fmt.Sprintf(format, a...), width))
+	}
+
+	if t.last == nil || t.last.info == nil {
+		add("sysinfo top - collecting...")
+		return lines
+	}
+	info := t.last.info
+
+	add("sysinfo top - %s up %s | load %.2f %.2f %.2f | %s",
+		info.Hostname, info.Uptime, info.LoadAverage.Load1, info.LoadAverage.Load5,
+		info.LoadAverage.Load15, t.last.at.Format("15:04:05"))
+
+	// Per-core CPU bars, as many columns as fit
+	if n := len(info.CPU.Usage); n > 0 {
+		cols := width / 24
+		if cols < 1 {
+			cols = 1
+		}
+		if cols > 8 {
+			cols = 8
+		}
+		if cols > n {
+			cols = n
+		}
+		cell := width / cols
+		rows := (n + cols - 1) / cols
+		for row := 0; row < rows; row++ {
+			var line strings.Builder
+			for col := 0; col < cols; col++ {
+				// Fill column by column like htop so core numbers read downwards
+				i := col*rows + row
+				if i >= n {
+					break
+				}
+				line.WriteString(meter(fmt.Sprintf("%-4d", i), info.CPU.Usage[i], "", cell-1))
+				line.WriteString(" ")
+			}
+			add("%s", line.String())
+		}
+	}
+
+	if info.SectionFailed(sysinfo.SectionMemory) {
+		add("Mem  unavailable")
+	} else {
+		used := fmt.Sprintf("%s/%s", humanBytes(float64(info.Memory.Used)), humanBytes(float64(info.Memory.Total)))
+		add("%s", meter("Mem ", info.Memory.UsedPercent, used, width-1))
+	}
+	if info.Swap.Total > 0 {
+		used := fmt.Sprintf("%s/%s", humanBytes(float64(info.Swap.Used)), humanBytes(float64(info.Swap.Total)))
+		add("%s", meter("Swp ", info.Swap.UsedPercent, used, width-1))
+	} else {
+		add("Swp  not configured")
+	}
+
+	// Throughput sparklines share the line evenly between their two series
+	spark := (width-44)/2 - 1
+	if t.rates != nil && t.rates.Network {
+		add("Net  rx %9s/s %s  tx %9s/s %s",
+			humanBytes(t.rates.NetRecv), sparkline(t.netRecv, spark),
+			humanBytes(t.rates.NetSent), sparkline(t.netSent, spark))
+	} else {
+		add("Net  waiting for the next sample")
+	}
+	if t.rates != nil && t.rates.IO {
+		add("Disk rd %9s/s %s  wr %9s/s %s",
+			humanBytes(t.rates.DiskRead), sparkline(t.diskRead, spark),
+			humanBytes(t.rates.DiskWrite), sparkline(t.diskWrite, spark))
+		add("     %.0f read IOPS, %.0f write IOPS", t.rates.DiskReadIOPS, t.rates.DiskWriteIOPS)
+	} else {
+		add("Disk waiting for the next sample")
+	}
+	add("")
+
+	procs := t.processes()
+	order := "desc"
+	if (t.sort == 'p') != t.reverse {
+		order = "asc"
+	}
+	status := fmt.Sprintf("Tasks: %d, %d shown | sort: %s %s", len(t.last.procs), len(procs), topSorts[t.sort].title, order)
+	if t.editing {
+		status += " | filter: " + t.filter + "_"
+	} else if t.filter != "" {
+		status += " | filter: " + t.filter + " (Esc to clear)"
+	}
+	if t.last.procErr != nil {
+		status += " | " + t.last.procErr.Error()
+	}
+	add("%s", status)
+	lines = append(lines, highlight(fmt.Sprintf("%7s %-10s %5s %5s %7s %4s %1s %s",
+		"PID", "USER", "CPU%", "MEM%", "RSS", "THR", "S", "COMMAND"), width))
+
+	// The process table takes whatever rows are left above the footer
+	t.page = height - len(lines) - 1
+	if t.page < 1 {
+		t.page = 1
+	}
+	if visible := len(procs) - t.page; t.offset > visible {
+		t.offset = visible
+	}
+	if t.offset < 0 {
+		t.offset = 0
+	}
+	for i := t.offset; i < len(procs) && i < t.offset+t.page; i++ {
+		p := procs[i]
+		command := p.CommandLine
+		if command == "" {
+			command = p.Name
+		}
+		add("%7d %-10s %5.1f %5.1f %7s %4d %1s %s",
+			p.PID, truncateString(p.Username, 10), p.CPUPercent, p.MemPercent,
+			humanBytes(float64(p.MemoryRSS)), p.NumThreads, processState(p.Status), command)
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, highlight("q quit  c/m/r/t/p sort  / filter  ↑↓ PgUp PgDn scroll", width))
+	return lines
+}
+
+// meter draws "label[|||||    12.3%] suffix" in width columns
+func meter(label string, percent float64, suffix string, width int) string {
+	if suffix != "" {
+		suffix = " " + suffix
+	}
+	value := fmt.Sprintf("%5.1f%%", percent)
+	inner := width - utf8.RuneCountInString(label) - len(value) - len(suffix) - 2
+	if inner < 0 {
+		inner = 0
+	}
+	filled := int(math.Round(percent / 100 * float64(inner)))
+	if filled > inner {
+		filled = inner
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	return label + "[" + strings.Repeat("|", filled) + strings.Repeat(" ", inner-filled) + value + "]" + suffix
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the last width samples scaled to the largest of them
+func sparkline(samples []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+	var peak float64
+	for _, v := range samples {
+		peak = math.Max(peak, v)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", width-len(samples)))
+	for _, v := range samples {
+		i := 0
+		if peak > 0 {
+			i = int(v / peak * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// highlight pads a line to the terminal width in reverse video
+func highlight(line string, width int) string {
+	line = clipLine(line, width)
+	if pad := width - 1 - utf8.RuneCountInString(line); pad > 0 {
+		line += strings.Repeat(" ", pad)
+	}
+	return ansiReverse + line + ansiReset
+}
+
+// processState shortens a gopsutil status such as "sleep" to its initial
+func processState(status string) string {
+	if status == "" {
+		return "?"
+	}
+	return strings.ToUpper(status[:1])
+}
+
+// humanBytes formats a byte count with a binary unit suffix
+func humanBytes(b float64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%.0fB", b)
+	}
+	exp := 0
+	for n := b / unit; n >= unit && exp < 4; n /= unit {
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", b/math.Pow(unit, float64(exp+1)), "KMGTP"[exp])
+}
+
+func init() {
+	topCmd.Flags().DurationVar(&topInterval, "interval", defaultWatchInterval, "Refresh interval")
+	rootCmd.AddCommand(topCmd)
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/junler/sysinfo/internal/anomaly"
+	"github.com/junler/sysinfo/internal/history"
+	"github.com/junler/sysinfo/internal/sysinfo"
+	"github.com/spf13/cobra"
+)
+
+const defaultWatchInterval = 2 * time.Second
+
+var (
+	watchMode     bool
+	watchInterval time.Duration
+	watchCount    int
+)
+
+// Control sequences used to redraw the dashboard in place
+const (
+	ansiHome       = "\033[H"
+	ansiClear      = "\033[2J"
+	ansiClearLine  = "\033[K"
+	ansiClearBelow = "\033[J"
+	ansiHideCursor = "\033[?25l"
+	ansiShowCursor = "\033[?25h"
+)
+
+// watchDashboard collects a snapshot every watchInterval and redraws the
+// dashboard with rates since the previous one, until interrupted or
+// watchCount snapshots have been shown
+func watchDashboard(cmd *cobra.Command) error {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+
+	out := cmd.OutOrStdout()
+	var scr *screen
+	if outputFormat == outputTable {
+		scr = newScreen(out)
+		defer scr.close()
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var (
+		prev   *sysinfo.SystemInfo
+		prevAt time.Time
+		rates  *sysinfo.Rates
+		frame  string
+	)
+	// Learn what is normal for this host while watching and flag metrics
+	// that stray from it
+	detector := anomaly.NewDetector(anomaly.Config{})
+	for n := 1; ; n++ {
+		info, at, err := collectInfo(ctx, collectOptions())
+		if ctx.Err() != nil {
+			// Interrupted, leave the last complete frame on screen
+			return nil
+		}
+		if info == nil {
+			return err
+		}
+		// A --remote server may answer from the snapshot already shown, which
+		// leaves the rates and the anomaly baselines as they were
+		fresh := prev == nil || at.After(prevAt)
+
+		if scr == nil {
+			if werr := writeSystemInfo(out, info); werr != nil {
+				return werr
+			}
+		} else {
+			if fresh {
+				if prev != nil {
+					r := sysinfo.NewRates(prev, info, at.Sub(prevAt))
+					rates = &r
+				}
+				detector.Evaluate(at, history.Samples(info, rates))
+			}
+			var b strings.Builder
+			fmt.Fprintf(&b, "Every %s | %s | Ctrl-C to quit\n\n", watchInterval, at.Local().Format("2006-01-02 15:04:05"))
+			printAnomalies(&b, detector.Anomalies())
+			printDashboard(&b, info, rates)
+			frame = b.String()
+			scr.draw(frame)
+		}
+
+		if watchCount > 0 && n >= watchCount {
+			return collectionError(info, err)
+		}
+		if fresh {
+			prev, prevAt = info, at
+		}
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-resized:
+				scr.draw(frame)
+			case <-ticker.C:
+				break wait
+			}
+		}
+	}
+}
+
+// printAnomalies lists the active anomalies above the dashboard
+func printAnomalies(w io.Writer, anomalies []anomaly.Anomaly) {
+	var active []anomaly.Anomaly
+	for _, a := range anomalies {
+		if a.State == anomaly.StateActive {
+			active = append(active, a)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "=== ANOMALIES ===")
+	for _, a := range active {
+		fmt.Fprintf(w, "! %-28s %14.2f  expected %14.2f  score %+5.1f  since %s\n",
+			a.Metric, a.Value, a.Expected, a.Score, a.Since.Format("15:04:05"))
+	}
+	fmt.Fprintln(w)
+}
+
+// screen redraws frames in place on a terminal, or writes them one after
+// another when output is redirected
+type screen struct {
+	w   io.Writer
+	fd  uintptr
+	tty bool
+}
+
+func newScreen(w io.Writer) *screen {
+	s := &screen{w: w}
+	if f, ok := w.(*os.File); ok {
+		s.fd = f.Fd()
+		_, _, s.tty = terminalSize(s.fd)
+	}
+	if s.tty {
+		fmt.Fprint(w, ansiHideCursor+ansiClear)
+	}
+	return s
+}
+
+// draw replaces the previous frame, clipped to the current terminal size so
+// nothing wraps or scrolls
+func (s *screen) draw(frame string) {
+	if s == nil {
+		return
+	}
+	if !s.tty {
+		fmt.Fprintln(s.w, frame)
+		return
+	}
+
+	width, height, _ := terminalSize(s.fd)
+	lines := strings.Split(strings.TrimRight(frame, "\n"), "\n")
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(clipLine(line, width))
+		b.WriteString(ansiClearLine)
+	}
+	b.WriteString(ansiClearBelow)
+	io.WriteString(s.w, b.String())
+}
+
+// close moves the cursor below the last frame and shows it again
+func (s *screen) close() {
+	if s.tty {
+		fmt.Fprint(s.w, "\n"+ansiShowCursor)
+	}
+}
+
+// clipLine cuts a line to fit the terminal, keeping the last column free so
+// the cursor never wraps
+func clipLine(line string, width int) string {
+	if width <= 1 || utf8.RuneCountInString(line) < width {
+		return line
+	}
+	return string([]rune(line)[:width-1])
+}
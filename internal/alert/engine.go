@@ -0,0 +1,207 @@
+package alert
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is where an alert is in its lifecycle
+type State string
+
+const (
+	// StatePending means the condition holds but not yet for the rule's For
+	StatePending State = "pending"
+	StateFiring  State = "firing"
+	// StateResolved means a firing alert's value crossed back over the clear
+	// threshold. Resolved alerts are listed for ResolvedRetention.
+	StateResolved State = "resolved"
+)
+
+func (s State) rank() int {
+	switch s {
+	case StateFiring:
+		return 2
+	case StatePending:
+		return 1
+	}
+	return 0
+}
+
+// ResolvedRetention is how long resolved alerts stay in Alerts
+const ResolvedRetention = 15 * time.Minute
+
+// Alert is one rule applied to one instance of its metric
+type Alert struct {
+	Rule     string            `json:"rule"`
+	Instance string            `json:"instance,omitempty"`
+	Severity Severity          `json:"severity"`
+	State    State             `json:"state"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Expr     string            `json:"expr"`
+	// Value is the latest value seen while the alert was active
+	Value   float64 `json:"value"`
+	Summary string  `json:"summary,omitempty"`
+	// Since is when the condition started to hold
+	Since      time.Time  `json:"since"`
+	FiredAt    *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+func alertKey(rule, instance string) string {
+	return rule + "\x00" + instance
+}
+
+// Engine evaluates rules against samples and tracks the resulting alerts
+type Engine struct {
+	rules []*Rule
+
+	mu       sync.Mutex
+	active   map[string]*Alert // pending and firing, by alertKey
+	resolved []Alert
+}
+
+func NewEngine(rules []*Rule) *Engine {
+	return &Engine{rules: rules, active: make(map[string]*Alert)}
+}
+
+// Rules returns the rules the engine evaluates
+func (e *Engine) Rules() []*Rule {
+	return e.rules
+}
+
+// Evaluate applies every rule to the values sampled at t, as produced by
+// history.Samples, and returns the alerts whose state changed
+func (e *Engine) Evaluate(t time.Time, values map[string]float64) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []Alert
+	for _, rule := range e.rules {
+		matched := rule.match(values)
+		for instance, value := range matched {
+			key := alertKey(rule.Name, instance)
+			a := e.active[key]
+			firing := a != nil && a.State == StateFiring
+			if !rule.holds(value, firing) {
+				if a != nil {
+					if c, ok := e.clear(key, a, t); ok {
+						changed = append(changed, c)
+					}
+				}
+				continue
+			}
+
+			created := a == nil
+			if created {
+				a = &Alert{
+					Rule:     rule.Name,
+					Instance: instance,
+					Severity: rule.Severity,
+					State:    StatePending,
+					Labels:   rule.labels(instance),
+					Expr:     rule.Expr,
+					Since:    t,
+				}
+				e.active[key] = a
+			}
+			a.Value = value
+			switch {
+			case a.State == StatePending && t.Sub(a.Since) >= rule.For:
+				fired := t
+				a.State, a.FiredAt = StateFiring, &fired
+				a.Summary = rule.renderSummary(a)
+				changed = append(changed, *a)
+			case created:
+				a.Summary = rule.renderSummary(a)
+				changed = append(changed, *a)
+			}
+		}
+
+		// Instances that disappeared, e.g. an unmounted disk, resolve. When
+		// nothing matched at all the collector likely failed, so the alerts
+		// are left as they are until it recovers.
+		if len(matched) == 0 {
+			continue
+		}
+		for key, a := range e.active {
+			if _, ok := matched[a.Instance]; a.Rule == rule.Name && !ok {
+				if c, ok := e.clear(key, a, t); ok {
+					changed = append(changed, c)
+				}
+			}
+		}
+	}
+
+	// Forget resolved alerts past their retention
+	kept := e.resolved[:0]
+	for _, a := range e.resolved {
+		if t.Sub(*a.ResolvedAt) < ResolvedRetention {
+			kept = append(kept, a)
+		}
+	}
+	e.resolved = kept
+
+	sort.Slice(changed, func(i, j int) bool {
+		if changed[i].Rule != changed[j].Rule {
+			return changed[i].Rule < changed[j].Rule
+		}
+		return changed[i].Instance < changed[j].Instance
+	})
+	return changed
+}
+
+// clear ends an active alert. A firing alert becomes resolved and is
+// returned; a pending one is dropped silently.
+func (e *Engine) clear(key string, a *Alert, t time.Time) (Alert, bool) {
+	delete(e.active, key)
+	if a.State != StateFiring {
+		return Alert{}, false
+	}
+	resolved := t
+	a.State, a.ResolvedAt = StateResolved, &resolved
+	e.resolved = append(e.resolved, *a)
+	return *a, true
+}
+
+// Alerts returns the pending, firing and recently resolved alerts, most
+// urgent first
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	alerts := make([]Alert, 0, len(e.active)+len(e.resolved))
+	for _, a := range e.active {
+		alerts = append(alerts, *a)
+	}
+	alerts = append(alerts, e.resolved...)
+	e.mu.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if a.State != b.State {
+			return a.State.rank() > b.State.rank()
+		}
+		if a.Severity != b.Severity {
+			return a.Severity.rank() > b.Severity.rank()
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Instance < b.Instance
+	})
+	return alerts
+}
+
+// labels returns the rule's labels plus the instance
+func (r *Rule) labels(instance string) map[string]string {
+	if len(r.Labels) == 0 && instance == "" {
+		return nil
+	}
+	labels := make(map[string]string, len(r.Labels)+1)
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+	if instance != "" {
+		labels["instance"] = instance
+	}
+	return labels
+}
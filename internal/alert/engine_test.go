@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"testing"
+	"time"
+)
+
+var base = time.Unix(1700000000, 0)
+
+func newTestEngine(t *testing.T, rules string) *Engine {
+	t.Helper()
+	parsed, err := ParseRules([]byte(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEngine(parsed)
+}
+
+func states(alerts []Alert) []string {
+	var s []string
+	for _, a := range alerts {
+		s = append(s, a.Rule+"/"+a.Instance+"="+string(a.State))
+	}
+	return s
+}
+
+func TestEngineLifecycle(t *testing.T) {
+	e := newTestEngine(t, `rules: [{name: mem, expr: memory.used_percent > 90 for 1m, clear: 85}]`)
+	step := func(offset time.Duration, value float64) []string {
+		return states(e.Evaluate(base.Add(offset), map[string]float64{"memory.used_percent": value}))
+	}
+
+	if got := step(0, 95); len(got) != 1 || got[0] != "mem/=pending" {
+		t.Fatalf("first breach: %v, want pending", got)
+	}
+	if got := step(30*time.Second, 96); len(got) != 0 {
+		t.Errorf("still pending: %v, want no change", got)
+	}
+	if got := step(time.Minute, 97); len(got) != 1 || got[0] != "mem/=firing" {
+		t.Fatalf("after for: %v, want firing", got)
+	}
+	// Between clear and threshold the alert keeps firing
+	if got := step(90*time.Second, 88); len(got) != 0 {
+		t.Errorf("above clear: %v, want still firing", got)
+	}
+	if got := step(2*time.Minute, 80); len(got) != 1 || got[0] != "mem/=resolved" {
+		t.Fatalf("below clear: %v, want resolved", got)
+	}
+
+	alerts := e.Alerts()
+	if len(alerts) != 1 || alerts[0].State != StateResolved || alerts[0].Value != 88 || alerts[0].FiredAt == nil {
+		t.Errorf("Alerts() = %+v", alerts)
+	}
+	// Resolved alerts are forgotten after ResolvedRetention
+	step(2*time.Minute+ResolvedRetention, 10)
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Errorf("Alerts() after retention = %+v", alerts)
+	}
+}
+
+func TestEnginePendingDropsWithoutResolve(t *testing.T) {
+	e := newTestEngine(t, `rules: [{name: load, expr: load.1 > 4 for 5m}]`)
+	e.Evaluate(base, map[string]float64{"load.1": 8})
+	if got := e.Evaluate(base.Add(time.Minute), map[string]float64{"load.1": 1}); len(got) != 0 {
+		t.Errorf("pending alert that never fired reported %v", states(got))
+	}
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Errorf("Alerts() = %v", states(alerts))
+	}
+	// The for window restarts
+	e.Evaluate(base.Add(2*time.Minute), map[string]float64{"load.1": 8})
+	if got := e.Evaluate(base.Add(6*time.Minute), map[string]float64{"load.1": 8}); len(got) != 0 {
+		t.Errorf("fired before holding for 5m: %v", states(got))
+	}
+}
+
+func TestEngineInstances(t *testing.T) {
+	e := newTestEngine(t, `rules: [{name: disk, expr: disk.used_percent > 85, severity: critical, labels: {team: ops}}]`)
+	got := e.Evaluate(base, map[string]float64{"disk.used_percent:/": 50, "disk.used_percent:/var": 90, "disk.used_percent:/data": 99})
+	if s := states(got); len(s) != 2 || s[0] != "disk//data=firing" || s[1] != "disk//var=firing" {
+		t.Fatalf("got %v, want /data and /var firing", s)
+	}
+	if got[1].Labels["instance"] != "/var" || got[1].Labels["team"] != "ops" || got[1].Severity != SeverityCritical {
+		t.Errorf("alert = %+v", got[1])
+	}
+
+	// Nothing matched: the disk collector failed, keep the alerts
+	if got := e.Evaluate(base.Add(time.Minute), map[string]float64{"load.1": 1}); len(got) != 0 {
+		t.Errorf("missing samples changed %v", states(got))
+	}
+	// /data was unmounted
+	got = e.Evaluate(base.Add(2*time.Minute), map[string]float64{"disk.used_percent:/": 50, "disk.used_percent:/var": 90})
+	if s := states(got); len(s) != 1 || s[0] != "disk//data=resolved" {
+		t.Errorf("got %v, want /data resolved", s)
+	}
+}
+
+func TestEngineAlertsOrder(t *testing.T) {
+	e := newTestEngine(t, `
+rules:
+  - {name: a, expr: load.1 > 1, severity: info}
+  - {name: b, expr: load.5 > 1, severity: critical}
+  - {name: c, expr: load.15 > 1 for 1h}
+`)
+	e.Evaluate(base, map[string]float64{"load.1": 2, "load.5": 2, "load.15": 2})
+	got := states(e.Alerts())
+	want := []string{"b/=firing", "a/=firing", "c/=pending"}
+	if len(got) != len(want) {
+		t.Fatalf("Alerts() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Alerts() = %v, want %v", got, want)
+			break
+		}
+	}
+}
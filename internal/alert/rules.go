@@ -0,0 +1,240 @@
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity ranks how urgent an alert is
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// Rule is one alert rule from a rules file, for example
+//
+//	rules:
+//	  - name: HighMemory
+//	    expr: memory.used_percent > 90 for 5m
+//	    clear: 85
+//	    severity: critical
+//	    labels:
+//	      team: ops
+//	    summary: 'Memory at {{ printf "%.1f" .Value }}%'
+//
+// expr compares a metric as named by /api/history with a threshold. Metrics
+// with instances take the instance in brackets, disk[/var].used_percent, or
+// [*] for every instance; without brackets the metric itself is used if it
+// exists and every instance otherwise, so disk.used_percent checks all mounts.
+type Rule struct {
+	Name string `yaml:"name"`
+	Expr string `yaml:"expr"`
+	// For is how long the condition must hold before the alert fires. It
+	// can also be given in expr as "... for 5m".
+	For time.Duration `yaml:"for"`
+	// Clear is the threshold a firing alert must cross back over to resolve,
+	// so a value hovering around the threshold doesn't flap. It defaults to
+	// the threshold itself.
+	Clear    *float64          `yaml:"clear"`
+	Severity Severity          `yaml:"severity"`
+	Labels   map[string]string `yaml:"labels"`
+	// Summary is a text/template rendered with the Alert
+	Summary string `yaml:"summary"`
+
+	metric    string
+	instance  string // "" when not given, "*" for every instance
+	op        string
+	threshold float64
+	summary   *template.Template
+}
+
+// exprPattern matches "section[instance].field op number [for duration]"
+var exprPattern = regexp.MustCompile(`^\s*([a-z][a-z0-9_]*)(?:\[([^\]]+)\])?\.([a-z0-9_]+)\s*(>=|<=|==|!=|>|<)\s*(\S+)(?:\s+for\s+(\S+))?\s*$`)
+
+// parse validates the rule and fills in its parsed expression and defaults
+func (r *Rule) parse() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule without a name")
+	}
+	m := exprPattern.FindStringSubmatch(r.Expr)
+	if m == nil {
+		return fmt.Errorf("rule %s: invalid expr %q, want e.g. \"memory.used_percent > 90\"", r.Name, r.Expr)
+	}
+	r.metric, r.instance, r.op = m[1]+"."+m[3], m[2], m[4]
+	threshold, err := strconv.ParseFloat(m[5], 64)
+	if err != nil {
+		return fmt.Errorf("rule %s: invalid threshold %q", r.Name, m[5])
+	}
+	r.threshold = threshold
+	if m[6] != "" {
+		if r.For != 0 {
+			return fmt.Errorf("rule %s: for is given both in expr and as a field", r.Name)
+		}
+		if r.For, err = time.ParseDuration(m[6]); err != nil {
+			return fmt.Errorf("rule %s: invalid duration %q", r.Name, m[6])
+		}
+	}
+	if r.For < 0 {
+		return fmt.Errorf("rule %s: negative for", r.Name)
+	}
+
+	if r.Clear != nil {
+		ok := false
+		switch r.op {
+		case ">", ">=":
+			ok = *r.Clear <= threshold
+		case "<", "<=":
+			ok = *r.Clear >= threshold
+		}
+		if !ok {
+			return fmt.Errorf("rule %s: clear %v must be on the other side of %s %v", r.Name, *r.Clear, r.op, threshold)
+		}
+	}
+
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityWarning
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("rule %s: unknown severity %q (want info, warning or critical)", r.Name, r.Severity)
+	}
+
+	if r.Summary != "" {
+		if r.summary, err = template.New(r.Name).Parse(r.Summary); err != nil {
+			return fmt.Errorf("rule %s: summary: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// Metric returns the metric name the rule checks, without instance
+func (r *Rule) Metric() string {
+	return r.metric
+}
+
+// HasData reports whether values, as produced by history.Samples, contain
+// anything the rule applies to
+func (r *Rule) HasData(values map[string]float64) bool {
+	return len(r.match(values)) > 0
+}
+
+// holds reports whether value meets the rule's condition. A firing alert is
+// checked against the clear threshold instead.
+func (r *Rule) holds(value float64, firing bool) bool {
+	threshold := r.threshold
+	if firing && r.Clear != nil {
+		threshold = *r.Clear
+	}
+	switch r.op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+// match returns the values the rule applies to, by instance
+func (r *Rule) match(values map[string]float64) map[string]float64 {
+	matched := make(map[string]float64)
+	switch r.instance {
+	case "":
+		if v, ok := values[r.metric]; ok {
+			matched[""] = v
+			return matched
+		}
+		fallthrough
+	case "*":
+		prefix := r.metric + ":"
+		for name, v := range values {
+			if instance, ok := strings.CutPrefix(name, prefix); ok {
+				matched[instance] = v
+			}
+		}
+	default:
+		if v, ok := values[r.metric+":"+r.instance]; ok {
+			matched[r.instance] = v
+		}
+	}
+	return matched
+}
+
+func (r *Rule) renderSummary(a *Alert) string {
+	if r.summary == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := r.summary.Execute(&buf, a); err != nil {
+		return r.Summary
+	}
+	return buf.String()
+}
+
+// rulesFile is the layout of a rules file
+type rulesFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// ParseRules parses rules from YAML
+func ParseRules(data []byte) ([]*Rule, error) {
+	var file rulesFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && err != io.EOF {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, r := range file.Rules {
+		if err := r.parse(); err != nil {
+			return nil, err
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule %s is defined twice", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return file.Rules, nil
+}
+
+// LoadRules reads a rules file
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+rules:
+  - name: HighMemory
+    expr: memory.used_percent > 90 for 5m
+    clear: 85
+    severity: critical
+    labels:
+      team: ops
+  - name: VarFull
+    expr: disk[/var].used_percent >= 85
+    for: 10m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules", len(rules))
+	}
+	mem := rules[0]
+	if mem.metric != "memory.used_percent" || mem.op != ">" || mem.threshold != 90 || mem.For != 5*time.Minute || *mem.Clear != 85 {
+		t.Errorf("HighMemory parsed as %+v", mem)
+	}
+	disk := rules[1]
+	if disk.metric != "disk.used_percent" || disk.instance != "/var" || disk.For != 10*time.Minute || disk.Severity != SeverityWarning {
+		t.Errorf("VarFull parsed as %+v", disk)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := map[string]string{
+		"bad expr":       "rules: [{name: a, expr: memory > 90}]",
+		"bad threshold":  "rules: [{name: a, expr: memory.used_percent > high}]",
+		"bad severity":   "rules: [{name: a, expr: load.1 > 4, severity: page}]",
+		"for twice":      "rules: [{name: a, expr: load.1 > 4 for 1m, for: 2m}]",
+		"clear wrong":    "rules: [{name: a, expr: load.1 > 4, clear: 5}]",
+		"duplicate":      "rules: [{name: a, expr: load.1 > 4}, {name: a, expr: load.5 > 4}]",
+		"unknown field":  "rules: [{name: a, expr: load.1 > 4, treshold: 3}]",
+		"bad template":   "rules: [{name: a, expr: load.1 > 4, summary: '{{ .Value '}]",
+		"missing name":   "rules: [{expr: load.1 > 4}]",
+		"negative for":   "rules: [{name: a, expr: load.1 > 4, for: -1m}]",
+		"clear with ==":  "rules: [{name: a, expr: load.1 == 4, clear: 4}]",
+		"bad for in exp": "rules: [{name: a, expr: load.1 > 4 for soon}]",
+	}
+	for name, data := range tests {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	values := map[string]float64{
+		"cpu.usage_percent":       50,
+		"cpu.usage_percent:0":     40,
+		"cpu.usage_percent:1":     60,
+		"disk.used_percent:/":     70,
+		"disk.used_percent:/var":  90,
+		"disk.used_bytes:/var":    1e9,
+		"memory.used_percent":     30,
+		"memory.used_percent_sum": 1,
+	}
+	tests := []struct {
+		expr string
+		want map[string]float64
+	}{
+		{"cpu.usage_percent > 0", map[string]float64{"": 50}},
+		{"cpu[*].usage_percent > 0", map[string]float64{"0": 40, "1": 60}},
+		{"disk.used_percent > 0", map[string]float64{"/": 70, "/var": 90}},
+		{"disk[/var].used_percent > 0", map[string]float64{"/var": 90}},
+		{"disk[/home].used_percent > 0", map[string]float64{}},
+		{"memory.used_percent > 0", map[string]float64{"": 30}},
+	}
+	for _, tt := range tests {
+		r := &Rule{Name: "r", Expr: tt.expr}
+		if err := r.parse(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.match(values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestRenderSummary(t *testing.T) {
+	rules, err := ParseRules([]byte(`rules: [{name: a, expr: disk.used_percent > 80, summary: '{{ .Instance }} at {{ printf "%.0f" .Value }}%'}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rules[0].renderSummary(&Alert{Instance: "/var", Value: 91.4})
+	if !strings.Contains(got, "/var at 91%") {
+		t.Errorf("summary = %q", got)
+	}
+}
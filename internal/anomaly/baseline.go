@@ -0,0 +1,124 @@
+package anomaly
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// madScale converts a median absolute deviation to the standard deviation
+// of normally distributed data
+const madScale = 1.4826
+
+// season is the expected value during one hour of the day, learned from
+// the means of that hour on previous days
+type season struct {
+	Mean float64 `json:"mean"`
+	Var  float64 `json:"var"`
+	Days int     `json:"days"`
+}
+
+// baseline is what a metric is expected to look like. It keeps an EWMA of
+// the value and its variance, a seasonal profile per hour of the day and a
+// window of recent values for the MAD score.
+type baseline struct {
+	N      int        `json:"n"`
+	Mean   float64    `json:"mean"`
+	Var    float64    `json:"var"`
+	Recent []float64  `json:"recent"`
+	Hours  [24]season `json:"hours"`
+
+	// The hour being accumulated, folded into Hours when it ends
+	Hour      int64   `json:"hour"` // unix hours
+	HourN     int     `json:"hour_n"`
+	HourSum   float64 `json:"hour_sum"`
+	HourSumSq float64 `json:"hour_sum_sq"`
+}
+
+// expected returns the expected value and spread at t, from the hour's
+// seasonal profile once it has been seen on minDays days and from the
+// EWMA otherwise
+func (b *baseline) expected(t time.Time, minDays int) (mean, stddev float64, seasonal bool) {
+	if s := b.Hours[t.Hour()]; minDays > 0 && s.Days >= minDays {
+		return s.Mean, math.Sqrt(s.Var), true
+	}
+	return b.Mean, math.Sqrt(b.Var), false
+}
+
+// median returns the median and median absolute deviation of the recent
+// values
+func (b *baseline) median() (median, mad float64) {
+	if len(b.Recent) == 0 {
+		return 0, 0
+	}
+	median = medianOf(append([]float64(nil), b.Recent...))
+	deviations := make([]float64, len(b.Recent))
+	for i, v := range b.Recent {
+		deviations[i] = math.Abs(v - median)
+	}
+	return median, medianOf(deviations)
+}
+
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// update adds a value sampled at t. Anomalous values are left out of the
+// EWMA so that a spike doesn't inflate the variance and hide itself; the
+// recent values still take them, so a lasting change of level stops
+// scoring once it fills half the window.
+func (b *baseline) update(t time.Time, v float64, anomalous bool, cfg *Config) {
+	switch {
+	case b.N == 0:
+		b.Mean = v
+	case !anomalous:
+		// Incremental EWMA of the mean and variance
+		diff := v - b.Mean
+		incr := cfg.Alpha * diff
+		b.Mean += incr
+		b.Var = (1 - cfg.Alpha) * (b.Var + diff*incr)
+	}
+	b.N++
+
+	b.Recent = append(b.Recent, v)
+	if len(b.Recent) > cfg.Window {
+		b.Recent = b.Recent[len(b.Recent)-cfg.Window:]
+	}
+
+	hour := t.Unix() / 3600
+	if hour != b.Hour {
+		b.foldHour()
+		b.Hour, b.HourN, b.HourSum, b.HourSumSq = hour, 0, 0, 0
+	}
+	b.HourN++
+	b.HourSum += v
+	b.HourSumSq += v * v
+}
+
+// foldHour adds the hour that just ended to its seasonal profile. Hours
+// with only a few samples, e.g. when sampling started late in the hour,
+// are dropped.
+func (b *baseline) foldHour() {
+	if b.HourN < 10 {
+		return
+	}
+	mean := b.HourSum / float64(b.HourN)
+	variance := math.Max(b.HourSumSq/float64(b.HourN)-mean*mean, 0)
+	s := &b.Hours[time.Unix(b.Hour*3600, 0).Hour()]
+	if s.Days == 0 {
+		s.Mean, s.Var = mean, variance
+	} else {
+		// Weigh the latest day by a third, and count how far the day's mean
+		// was off as spread too
+		const alpha = 1.0 / 3
+		diff := mean - s.Mean
+		s.Mean += alpha * diff
+		s.Var = (1-alpha)*s.Var + alpha*(variance+diff*diff)
+	}
+	s.Days++
+}
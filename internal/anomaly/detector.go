@@ -0,0 +1,276 @@
+// Package anomaly flags metric values that deviate from what is normal for
+// the host, for fleets where one static threshold doesn't fit every machine
+package anomaly
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults for a Config
+const (
+	DefaultThreshold = 3.5
+	DefaultAlpha     = 0.05
+	DefaultWarmup    = 40
+	DefaultWindow    = 60
+	DefaultMinDays   = 3
+)
+
+// EndedRetention is how long ended anomalies stay in Anomalies
+const EndedRetention = time.Hour
+
+// saveInterval is how often Observe writes the baselines to Config.StatePath
+const saveInterval = 5 * time.Minute
+
+// Metric is a metric to watch. MinStdDev is the smallest spread assumed
+// for it, so that small changes in a nearly constant metric don't score
+// high; a value has to be Threshold times MinStdDev off to be anomalous.
+type Metric struct {
+	Name      string
+	MinStdDev float64
+}
+
+// DefaultMetrics are the metrics watched when none are configured
+var DefaultMetrics = []Metric{
+	{Name: "cpu.usage_percent", MinStdDev: 5},
+	{Name: "load.1", MinStdDev: 0.25},
+	{Name: "net.recv_bytes_per_sec", MinStdDev: 64 << 10},
+	{Name: "net.sent_bytes_per_sec", MinStdDev: 64 << 10},
+	{Name: "diskio.read_bytes_per_sec", MinStdDev: 1 << 20},
+	{Name: "diskio.write_bytes_per_sec", MinStdDev: 1 << 20},
+	{Name: "processes.count", MinStdDev: 5},
+}
+
+// Config tunes a Detector. Zero fields take the defaults.
+type Config struct {
+	Metrics []Metric
+	// Threshold is the score a value needs to be anomalous. An anomaly
+	// ends once the score falls below half of it.
+	Threshold float64
+	// Alpha is the weight of each new value in the EWMA baseline
+	Alpha float64
+	// Warmup is the number of values seen before any is scored
+	Warmup int
+	// Window is the number of recent values the MAD score uses
+	Window int
+	// MinDays is how many days an hour of the day must have been seen on
+	// before its seasonal baseline replaces the EWMA
+	MinDays int
+	// StatePath, if set, is where Run keeps the baselines across restarts
+	StatePath string
+}
+
+// State is whether an anomaly is still going on
+type State string
+
+const (
+	StateActive State = "active"
+	StateEnded  State = "ended"
+)
+
+// Anomaly is a stretch of time in which a metric was off its baseline
+type Anomaly struct {
+	Metric string `json:"metric"`
+	State  State  `json:"state"`
+	// Value and Expected are the latest sample and its baseline
+	Value    float64 `json:"value"`
+	Expected float64 `json:"expected"`
+	// Score is the deviation in standard deviations, negative below
+	// Expected. It is the smaller of ZScore, against the baseline, and
+	// MADScore, against the median of the recent values.
+	Score     float64 `json:"score"`
+	ZScore    float64 `json:"zscore"`
+	MADScore  float64 `json:"mad_score"`
+	PeakScore float64 `json:"peak_score"`
+	// Seasonal is set when the baseline is the hour of day profile
+	Seasonal bool       `json:"seasonal"`
+	Since    time.Time  `json:"since"`
+	EndedAt  *time.Time `json:"ended_at,omitempty"`
+}
+
+// Detector scores samples against per-metric baselines and tracks the
+// resulting anomalies
+type Detector struct {
+	cfg Config
+
+	mu        sync.Mutex
+	baselines map[string]*baseline
+	active    map[string]*Anomaly
+	ended     []Anomaly
+	// lastSave is when Observe last wrote the baselines
+	lastSave time.Time
+}
+
+// NewDetector creates a detector, loading the baselines from
+// cfg.StatePath when it holds any
+func NewDetector(cfg Config) *Detector {
+	if len(cfg.Metrics) == 0 {
+		cfg.Metrics = DefaultMetrics
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultThreshold
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha >= 1 {
+		cfg.Alpha = DefaultAlpha
+	}
+	if cfg.Warmup <= 0 {
+		cfg.Warmup = DefaultWarmup
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.MinDays <= 0 {
+		cfg.MinDays = DefaultMinDays
+	}
+	d := &Detector{cfg: cfg, baselines: make(map[string]*baseline), active: make(map[string]*Anomaly)}
+	if cfg.StatePath != "" {
+		if data, err := os.ReadFile(cfg.StatePath); err == nil {
+			var baselines map[string]*baseline
+			if json.Unmarshal(data, &baselines) == nil && baselines != nil {
+				d.baselines = baselines
+			}
+		}
+	}
+	return d
+}
+
+// Observe scores a history sample like Evaluate and writes the baselines
+// to Config.StatePath every few minutes
+func (d *Detector) Observe(t time.Time, values map[string]float64) []Anomaly {
+	changed := d.Evaluate(t, values)
+	if d.cfg.StatePath != "" && t.Sub(d.lastSave) >= saveInterval {
+		d.Save()
+		d.lastSave = t
+	}
+	return changed
+}
+
+// Save writes the baselines to Config.StatePath
+func (d *Detector) Save() error {
+	d.mu.Lock()
+	data, err := json.Marshal(d.baselines)
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.cfg.StatePath), 0o755); err != nil {
+		return err
+	}
+	tmp := d.cfg.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.cfg.StatePath)
+}
+
+// Evaluate scores the values sampled at t, as produced by history.Samples,
+// adds them to the baselines and returns the anomalies that started or
+// ended
+func (d *Detector) Evaluate(t time.Time, values map[string]float64) []Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var changed []Anomaly
+	for _, m := range d.cfg.Metrics {
+		v, ok := values[m.Name]
+		if !ok {
+			continue
+		}
+		b := d.baselines[m.Name]
+		if b == nil {
+			b = &baseline{}
+			d.baselines[m.Name] = b
+		}
+
+		anomalous := false
+		if b.N >= d.cfg.Warmup {
+			a := d.score(b, m, t, v)
+			anomalous = math.Abs(a.Score) >= d.cfg.Threshold
+			cur := d.active[m.Name]
+			switch {
+			case cur == nil && math.Abs(a.Score) >= d.cfg.Threshold:
+				a.State, a.Since, a.PeakScore = StateActive, t, a.Score
+				d.active[m.Name] = &a
+				changed = append(changed, a)
+			case cur != nil && math.Abs(a.Score) < d.cfg.Threshold/2:
+				ended := t
+				cur.State, cur.EndedAt = StateEnded, &ended
+				cur.Value, cur.Expected, cur.Score, cur.ZScore, cur.MADScore = a.Value, a.Expected, a.Score, a.ZScore, a.MADScore
+				delete(d.active, m.Name)
+				d.ended = append(d.ended, *cur)
+				changed = append(changed, *cur)
+			case cur != nil:
+				cur.Value, cur.Expected, cur.Score, cur.ZScore, cur.MADScore, cur.Seasonal = a.Value, a.Expected, a.Score, a.ZScore, a.MADScore, a.Seasonal
+				if math.Abs(a.Score) > math.Abs(cur.PeakScore) {
+					cur.PeakScore = a.Score
+				}
+			}
+		}
+		b.update(t, v, anomalous, &d.cfg)
+	}
+
+	// Drop ended anomalies past their retention
+	keep := d.ended[:0]
+	for _, a := range d.ended {
+		if t.Sub(*a.EndedAt) < EndedRetention {
+			keep = append(keep, a)
+		}
+	}
+	d.ended = keep
+	return changed
+}
+
+// score rates v against the baseline of m
+func (d *Detector) score(b *baseline, m Metric, t time.Time, v float64) Anomaly {
+	mean, stddev, seasonal := b.expected(t, d.cfg.MinDays)
+	z := (v - mean) / math.Max(stddev, m.MinStdDev)
+	median, mad := b.median()
+	madScore := (v - median) / math.Max(mad*madScale, m.MinStdDev)
+
+	// Both have to agree on the direction, the weaker one is the score
+	score := 0.0
+	if z*madScore > 0 {
+		score = math.Copysign(math.Min(math.Abs(z), math.Abs(madScore)), z)
+	}
+	return Anomaly{Metric: m.Name, Value: v, Expected: mean, Score: score, ZScore: z, MADScore: madScore, Seasonal: seasonal}
+}
+
+// Anomalies returns the active and recently ended anomalies, active first
+// and then by the size of their score
+func (d *Detector) Anomalies() []Anomaly {
+	d.mu.Lock()
+	anomalies := make([]Anomaly, 0, len(d.active)+len(d.ended))
+	for _, a := range d.active {
+		anomalies = append(anomalies, *a)
+	}
+	anomalies = append(anomalies, d.ended...)
+	d.mu.Unlock()
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		a, b := anomalies[i], anomalies[j]
+		if a.State != b.State {
+			return a.State == StateActive
+		}
+		if a.State == StateEnded && !a.EndedAt.Equal(*b.EndedAt) {
+			return a.EndedAt.After(*b.EndedAt)
+		}
+		return math.Abs(a.PeakScore) > math.Abs(b.PeakScore)
+	})
+	return anomalies
+}
+
+// Active returns the active anomaly of each metric
+func (d *Detector) Active() map[string]Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	active := make(map[string]Anomaly, len(d.active))
+	for name, a := range d.active {
+		active[name] = *a
+	}
+	return active
+}
@@ -0,0 +1,153 @@
+package anomaly
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var base = time.Unix(1700000000, 0)
+
+// noise is a small deterministic wobble around zero
+func noise(i int) float64 {
+	return math.Sin(float64(i)*1.7) * 2
+}
+
+func TestDetectorSpike(t *testing.T) {
+	d := NewDetector(Config{Metrics: []Metric{{Name: "cpu.usage_percent", MinStdDev: 1}}})
+	step := func(i int, v float64) []Anomaly {
+		return d.Evaluate(base.Add(time.Duration(i)*15*time.Second), map[string]float64{"cpu.usage_percent": v})
+	}
+
+	// Nothing is scored during warmup, however far off
+	for i := 0; i < DefaultWarmup; i++ {
+		v := 20 + noise(i)
+		if i == 3 {
+			v = 100
+		}
+		if got := step(i, v); len(got) != 0 {
+			t.Fatalf("warmup sample %d: %+v", i, got)
+		}
+	}
+	for i := DefaultWarmup; i < 100; i++ {
+		if got := step(i, 20+noise(i)); len(got) != 0 {
+			t.Fatalf("normal sample %d: %+v", i, got)
+		}
+	}
+
+	got := step(100, 90)
+	if len(got) != 1 || got[0].State != StateActive || got[0].Score < DefaultThreshold || got[0].Expected < 18 || got[0].Expected > 22 {
+		t.Fatalf("spike: %+v", got)
+	}
+	// Still high, and left out of the baseline
+	if got := step(101, 95); len(got) != 0 {
+		t.Errorf("ongoing spike: %+v", got)
+	}
+	if a := d.Active()["cpu.usage_percent"]; a.Value != 95 || a.PeakScore < got[0].Score {
+		t.Errorf("active anomaly: %+v", a)
+	}
+
+	got = step(102, 20)
+	if len(got) != 1 || got[0].State != StateEnded || got[0].EndedAt == nil || !got[0].Since.Equal(base.Add(100*15*time.Second)) {
+		t.Fatalf("back to normal: %+v", got)
+	}
+	if all := d.Anomalies(); len(all) != 1 || all[0].State != StateEnded {
+		t.Errorf("Anomalies() = %+v", all)
+	}
+	// Ended anomalies are forgotten after EndedRetention
+	d.Evaluate(base.Add(102*15*time.Second+EndedRetention), map[string]float64{})
+	if all := d.Anomalies(); len(all) != 0 {
+		t.Errorf("Anomalies() after retention = %+v", all)
+	}
+}
+
+func TestDetectorMinStdDev(t *testing.T) {
+	d := NewDetector(Config{Metrics: []Metric{{Name: "processes.count", MinStdDev: 5}}})
+	for i := 0; i < 100; i++ {
+		d.Evaluate(base.Add(time.Duration(i)*time.Second), map[string]float64{"processes.count": 200})
+	}
+	// A constant metric has no spread of its own, so the floor decides
+	if got := d.Evaluate(base.Add(100*time.Second), map[string]float64{"processes.count": 210}); len(got) != 0 {
+		t.Errorf("within the floor: %+v", got)
+	}
+	got := d.Evaluate(base.Add(101*time.Second), map[string]float64{"processes.count": 160})
+	if len(got) != 1 || got[0].Score > -DefaultThreshold {
+		t.Errorf("drop: %+v, want a negative score", got)
+	}
+}
+
+func TestDetectorSeasonal(t *testing.T) {
+	d := NewDetector(Config{Metrics: []Metric{{Name: "load.1", MinStdDev: 0.1}}})
+	// A nightly job loads the host between 2 and 3 o'clock every day
+	load := func(t time.Time, i int) float64 {
+		if t.Hour() == 2 {
+			return 8 + noise(i)/10
+		}
+		return 1 + noise(i)/10
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	i := 0
+	var during []Anomaly
+	for ts := start; ts.Before(start.Add(5 * 24 * time.Hour)); ts = ts.Add(time.Minute) {
+		changed := d.Evaluate(ts, map[string]float64{"load.1": load(ts, i)})
+		if ts.Hour() == 2 && ts.Minute() == 0 {
+			during = changed
+		}
+		i++
+	}
+	// By the fifth day the job is expected
+	if len(during) != 0 {
+		t.Errorf("nightly job on day 5: %+v", during)
+	}
+	if s := d.baselines["load.1"].Hours[2]; s.Days < DefaultMinDays || math.Abs(s.Mean-8) > 0.5 {
+		t.Errorf("2 o'clock profile: %+v", s)
+	}
+}
+
+func TestDetectorSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "baselines.json")
+	d := NewDetector(Config{StatePath: path})
+	for i := 0; i < 50; i++ {
+		d.Evaluate(base.Add(time.Duration(i)*time.Second), map[string]float64{"cpu.usage_percent": 30 + noise(i)})
+	}
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The loaded baselines are already past warmup
+	e := NewDetector(Config{StatePath: path})
+	if b := e.baselines["cpu.usage_percent"]; b == nil || b.N != 50 || math.Abs(b.Mean-30) > 2 {
+		t.Fatalf("loaded baseline: %+v", b)
+	}
+	if got := e.Evaluate(base.Add(time.Minute), map[string]float64{"cpu.usage_percent": 95}); len(got) != 1 {
+		t.Errorf("spike after load: %+v", got)
+	}
+}
+
+func TestDetectorLevelShift(t *testing.T) {
+	d := NewDetector(Config{Metrics: []Metric{{Name: "cpu.usage_percent", MinStdDev: 1}}})
+	step := func(i int, v float64) []Anomaly {
+		return d.Evaluate(base.Add(time.Duration(i)*15*time.Second), map[string]float64{"cpu.usage_percent": v})
+	}
+	for i := 0; i < 100; i++ {
+		step(i, 20+noise(i))
+	}
+
+	// A sustained rise stays anomalous until it becomes the new normal
+	if got := step(100, 90); len(got) != 1 || got[0].State != StateActive {
+		t.Fatalf("rise: %+v", got)
+	}
+	ended := 0
+	for i := 101; i < 200 && ended == 0; i++ {
+		if got := step(i, 90+noise(i)); len(got) > 0 {
+			if got[0].State != StateEnded {
+				t.Fatalf("sample %d: %+v", i, got)
+			}
+			ended = i
+		}
+	}
+	if ended < 125 || ended > 135 {
+		t.Errorf("anomaly ended at sample %d, want once the new level fills half the window", ended)
+	}
+}
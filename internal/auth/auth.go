@@ -0,0 +1,224 @@
+// Package auth authenticates requests to the sysinfo server with static
+// bearer tokens, HTTP basic auth against bcrypt hashed passwords and login
+// sessions, and assigns each caller a role
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role decides what a caller may see
+type Role string
+
+const (
+	// RoleReadOnly sees system metrics, but not process command lines or
+	// who is logged in
+	RoleReadOnly Role = "readonly"
+	// RoleAdmin sees everything
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole parses a role name, empty meaning RoleReadOnly
+func ParseRole(s string) (Role, error) {
+	switch Role(s) {
+	case "", RoleReadOnly:
+		return RoleReadOnly, nil
+	case RoleAdmin:
+		return RoleAdmin, nil
+	}
+	return "", fmt.Errorf("unknown role %q (want %s or %s)", s, RoleReadOnly, RoleAdmin)
+}
+
+// Allows reports whether a caller with role r may do what needs role need
+func (r Role) Allows(need Role) bool {
+	return r == RoleAdmin || r == need
+}
+
+// Identity is an authenticated caller
+type Identity struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+}
+
+// Authenticator checks the credentials a request carries
+type Authenticator interface {
+	// Authenticate returns who sent r, ok is false when r carries no
+	// valid credentials this authenticator knows about
+	Authenticate(r *http.Request) (id Identity, ok bool)
+}
+
+// PasswordVerifier is an Authenticator that can also check a name and
+// password typed into the dashboard's login page
+type PasswordVerifier interface {
+	Verify(name, password string) (id Identity, ok bool)
+}
+
+// Tokens authenticates static bearer tokens
+type Tokens struct {
+	// Keyed by the token's hash so lookups take the same time however
+	// much of a guess matches a real token
+	tokens map[[sha256.Size]byte]Identity
+}
+
+// ParseTokens reads a tokens file: one "name:token[:role]" per line.
+// Blank lines and lines starting with # are skipped.
+func ParseTokens(r io.Reader) (*Tokens, error) {
+	t := &Tokens{tokens: make(map[[sha256.Size]byte]Identity)}
+	err := parseEntries(r, func(name, secret string, role Role) error {
+		key := sha256.Sum256([]byte(secret))
+		if _, ok := t.tokens[key]; ok {
+			return fmt.Errorf("token of %q is already used", name)
+		}
+		t.tokens[key] = Identity{Name: name, Role: role}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadTokens reads a tokens file, see ParseTokens
+func LoadTokens(path string) (*Tokens, error) {
+	return loadFile(path, ParseTokens)
+}
+
+// Authenticate accepts an "Authorization: Bearer <token>" header
+func (t *Tokens) Authenticate(r *http.Request) (Identity, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return Identity{}, false
+	}
+	id, ok := t.tokens[sha256.Sum256([]byte(token))]
+	return id, ok
+}
+
+// Verify accepts a token in place of the password, with the token's name
+// or no name at all
+func (t *Tokens) Verify(name, password string) (Identity, bool) {
+	id, ok := t.tokens[sha256.Sum256([]byte(password))]
+	if !ok || (name != "" && name != id.Name) {
+		return Identity{}, false
+	}
+	return id, true
+}
+
+// Users authenticates HTTP basic auth against bcrypt password hashes
+type Users struct {
+	users map[string]user
+	// dummy is checked against when the name is unknown, so unknown and
+	// known names take equally long to reject
+	dummy []byte
+}
+
+type user struct {
+	hash []byte
+	role Role
+}
+
+// ParseUsers reads a password file: one "name:bcrypt-hash[:role]" per
+// line, as written by "htpasswd -B" or "sysinfo passwd". Blank lines and
+// lines starting with # are skipped.
+func ParseUsers(r io.Reader) (*Users, error) {
+	u := &Users{users: make(map[string]user)}
+	err := parseEntries(r, func(name, hash string, role Role) error {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("user %q: not a bcrypt hash", name)
+		}
+		if _, ok := u.users[name]; ok {
+			return fmt.Errorf("duplicate user %q", name)
+		}
+		u.users[name] = user{hash: []byte(hash), role: role}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.dummy, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return u, nil
+}
+
+// LoadUsers reads a password file, see ParseUsers
+func LoadUsers(path string) (*Users, error) {
+	return loadFile(path, ParseUsers)
+}
+
+// Authenticate accepts an "Authorization: Basic" header
+func (u *Users) Authenticate(r *http.Request) (Identity, bool) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, false
+	}
+	return u.Verify(name, password)
+}
+
+// Verify checks a name and password
+func (u *Users) Verify(name, password string) (Identity, bool) {
+	usr, ok := u.users[name]
+	if !ok {
+		bcrypt.CompareHashAndPassword(u.dummy, []byte(password))
+		return Identity{}, false
+	}
+	if bcrypt.CompareHashAndPassword(usr.hash, []byte(password)) != nil {
+		return Identity{}, false
+	}
+	return Identity{Name: name, Role: usr.role}, true
+}
+
+// HashPassword returns a password file line for a user
+func HashPassword(name, password string, role Role) (string, error) {
+	if name == "" || strings.Contains(name, ":") {
+		return "", fmt.Errorf("invalid user name %q", name)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return name + ":" + string(hash) + ":" + string(role), nil
+}
+
+// parseEntries calls add for each "name:secret[:role]" line of r
+func parseEntries(r io.Reader, add func(name, secret string, role Role) error) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ":")
+		if len(fields) < 2 || len(fields) > 3 || fields[0] == "" || fields[1] == "" {
+			return fmt.Errorf("line %d: want name:secret[:role]", line)
+		}
+		role, err := ParseRole(strings.Join(fields[2:], ""))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := add(fields[0], fields[1], role); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func loadFile[T any](path string, parse func(io.Reader) (T, error)) (T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer f.Close()
+	v, err := parse(f)
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	tokens, err := ParseTokens(strings.NewReader(`
+# scrapers
+prometheus:t0k3n
+ops:adm1n:admin
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := func(header string) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", header)
+		return r
+	}
+	if id, ok := tokens.Authenticate(req("Bearer t0k3n")); !ok || id != (Identity{"prometheus", RoleReadOnly}) {
+		t.Errorf("readonly token: %+v, %v", id, ok)
+	}
+	if id, ok := tokens.Authenticate(req("Bearer adm1n")); !ok || id.Role != RoleAdmin {
+		t.Errorf("admin token: %+v, %v", id, ok)
+	}
+	for _, header := range []string{"", "Bearer wrong", "Basic t0k3n"} {
+		if _, ok := tokens.Authenticate(req(header)); ok {
+			t.Errorf("%q accepted", header)
+		}
+	}
+	if _, ok := tokens.Verify("", "adm1n"); !ok {
+		t.Error("token without a name rejected")
+	}
+	if _, ok := tokens.Verify("prometheus", "adm1n"); ok {
+		t.Error("token accepted for another name")
+	}
+
+	for _, bad := range []string{"name", "name:", "a:x\nb:x", "a:x:root", "a:b:c:d"} {
+		if _, err := ParseTokens(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseTokens(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestUsers(t *testing.T) {
+	alice, err := HashPassword("alice", "wonderland", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _ := HashPassword("bob", "builder", RoleReadOnly)
+	users, err := ParseUsers(strings.NewReader(alice + "\n" + bob + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("alice", "wonderland")
+	if id, ok := users.Authenticate(r); !ok || id != (Identity{"alice", RoleAdmin}) {
+		t.Errorf("alice: %+v, %v", id, ok)
+	}
+	if id, ok := users.Verify("bob", "builder"); !ok || id.Role != RoleReadOnly {
+		t.Errorf("bob: %+v, %v", id, ok)
+	}
+	for _, creds := range [][2]string{{"bob", "wonderland"}, {"carol", "builder"}, {"", ""}} {
+		if _, ok := users.Verify(creds[0], creds[1]); ok {
+			t.Errorf("%v accepted", creds)
+		}
+	}
+
+	if _, err := ParseUsers(strings.NewReader("alice:plaintext")); err == nil {
+		t.Error("password that isn't a bcrypt hash accepted")
+	}
+	if _, err := HashPassword("a:b", "x", RoleAdmin); err == nil {
+		t.Error("user name with a colon accepted")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	now := time.Now()
+	s := NewSessions(time.Hour)
+	token, expires, err := s.Create(Identity{"alice", RoleAdmin}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("expires = %s", expires)
+	}
+	if id, ok := s.Lookup(token, now.Add(59*time.Minute)); !ok || id.Name != "alice" {
+		t.Errorf("lookup: %+v, %v", id, ok)
+	}
+	if _, ok := s.Lookup(token, now.Add(time.Hour)); ok {
+		t.Error("expired session accepted")
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookie, Value: token})
+	if _, ok := s.Authenticate(r); !ok {
+		t.Error("session cookie rejected")
+	}
+	s.Delete(token)
+	if _, ok := s.Authenticate(r); ok {
+		t.Error("deleted session accepted")
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionCookie holds the session of a user signed in through the login page
+const SessionCookie = "sysinfo_session"
+
+// DefaultSessionTTL is how long a login lasts
+const DefaultSessionTTL = 12 * time.Hour
+
+// Sessions keeps the logins made through the dashboard's login page. They
+// live in memory, so restarting the server signs everyone out.
+type Sessions struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+type session struct {
+	id      Identity
+	expires time.Time
+}
+
+// NewSessions returns an empty session store whose logins last ttl,
+// DefaultSessionTTL when zero
+func NewSessions(ttl time.Duration) *Sessions {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &Sessions{ttl: ttl, sessions: make(map[string]session)}
+}
+
+// Create starts a session for id and returns its token and expiry
+func (s *Sessions) Create(id Identity, now time.Time) (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	expires := now.Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Forget expired sessions as new ones come in, so abandoned logins
+	// don't pile up
+	for t, sess := range s.sessions {
+		if !now.Before(sess.expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = session{id: id, expires: expires}
+	return token, expires, nil
+}
+
+// Lookup returns who a session token belongs to
+func (s *Sessions) Lookup(token string, now time.Time) (Identity, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok || !now.Before(sess.expires) {
+		return Identity{}, false
+	}
+	return sess.id, true
+}
+
+// Delete ends a session
+func (s *Sessions) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// Authenticate accepts the session cookie set by the login page
+func (s *Sessions) Authenticate(r *http.Request) (Identity, bool) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return Identity{}, false
+	}
+	return s.Lookup(cookie.Value, time.Now())
+}
@@ -0,0 +1,264 @@
+// Package check implements the Nagios plugin conventions: threshold ranges,
+// OK/WARNING/CRITICAL/UNKNOWN states and performance data
+package check
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Status is a plugin state, its value is the process exit code
+type Status int
+
+const (
+	OK Status = iota
+	Warning
+	Critical
+	Unknown
+)
+
+var statusNames = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+func (s Status) String() string {
+	if s < OK || s > Unknown {
+		return statusNames[Unknown]
+	}
+	return statusNames[s]
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// severity orders states from best to worst: OK, UNKNOWN, WARNING, CRITICAL
+func (s Status) severity() int {
+	switch s {
+	case OK:
+		return 0
+	case Unknown:
+		return 1
+	case Warning:
+		return 2
+	}
+	return 3
+}
+
+// Worst returns the most severe of the given states
+func Worst(states ...Status) Status {
+	worst := OK
+	for _, s := range states {
+		if s.severity() > worst.severity() {
+			worst = s
+		}
+	}
+	return worst
+}
+
+// Range is a threshold range. A value outside [Start, End] raises an alert,
+// or one inside it when Inside is set.
+type Range struct {
+	Start, End float64
+	Inside     bool
+	text       string
+}
+
+// ParseRange parses the Nagios range syntax:
+//
+//	10      alert outside 0..10
+//	10:     alert below 10
+//	~:10    alert above 10
+//	10:20   alert outside 10..20
+//	@10:20  alert inside 10..20
+func ParseRange(s string) (*Range, error) {
+	r := &Range{Start: 0, End: math.Inf(1), text: s}
+	spec := strings.TrimSpace(s)
+	if strings.HasPrefix(spec, "@") {
+		r.Inside = true
+		spec = spec[1:]
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("invalid range %q: empty", s)
+	}
+
+	end := spec
+	if start, rest, ok := strings.Cut(spec, ":"); ok {
+		switch start {
+		case "~":
+			r.Start = math.Inf(-1)
+		case "":
+		default:
+			v, err := strconv.ParseFloat(start, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q: bad start %q", s, start)
+			}
+			r.Start = v
+		}
+		end = rest
+	}
+	if end != "" {
+		v, err := strconv.ParseFloat(end, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: bad end %q", s, end)
+		}
+		r.End = v
+	}
+	if r.Start > r.End {
+		return nil, fmt.Errorf("invalid range %q: start is greater than end", s)
+	}
+	return r, nil
+}
+
+// ParseRanges parses a comma separated list of n ranges. A single range is
+// used for all n values, and empty entries mean no threshold.
+func ParseRanges(s string, n int) ([]*Range, error) {
+	ranges := make([]*Range, n)
+	if s == "" {
+		return ranges, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 1 && len(parts) != n {
+		return nil, fmt.Errorf("invalid ranges %q: want 1 or %d values", s, n)
+	}
+	for i := range ranges {
+		part := parts[0]
+		if len(parts) == n {
+			part = parts[i]
+		}
+		if part == "" {
+			continue
+		}
+		r, err := ParseRange(part)
+		if err != nil {
+			return nil, err
+		}
+		ranges[i] = r
+	}
+	return ranges, nil
+}
+
+// Alert reports whether v raises an alert
+func (r *Range) Alert(v float64) bool {
+	inside := v >= r.Start && v <= r.End
+	return inside == r.Inside
+}
+
+// String returns the range as it was written
+func (r *Range) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.text
+}
+
+// Thresholds holds the warning and critical ranges of a value, either may
+// be nil
+type Thresholds struct {
+	Warning  *Range
+	Critical *Range
+}
+
+// ParseThresholds parses a warning and a critical range, empty for none
+func ParseThresholds(warning, critical string) (Thresholds, error) {
+	var t Thresholds
+	var err error
+	if warning != "" {
+		if t.Warning, err = ParseRange(warning); err != nil {
+			return t, err
+		}
+	}
+	if critical != "" {
+		if t.Critical, err = ParseRange(critical); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// Status returns the state of v against the thresholds
+func (t Thresholds) Status(v float64) Status {
+	if t.Critical != nil && t.Critical.Alert(v) {
+		return Critical
+	}
+	if t.Warning != nil && t.Warning.Alert(v) {
+		return Warning
+	}
+	return OK
+}
+
+// Perf is one performance data value
+type Perf struct {
+	Label string   `json:"label"`
+	Value float64  `json:"value"`
+	Unit  string   `json:"unit,omitempty"`
+	Warn  string   `json:"warning,omitempty"`
+	Crit  string   `json:"critical,omitempty"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+// NewPerf builds a performance value with the thresholds it is checked
+// against
+func NewPerf(label string, value float64, unit string, t Thresholds) Perf {
+	return Perf{Label: label, Value: value, Unit: unit, Warn: t.Warning.String(), Crit: t.Critical.String()}
+}
+
+// WithRange sets the minimum and maximum possible values
+func (p Perf) WithRange(min, max float64) Perf {
+	p.Min, p.Max = &min, &max
+	return p
+}
+
+// WithMin sets the minimum possible value
+func (p Perf) WithMin(min float64) Perf {
+	p.Min = &min
+	return p
+}
+
+// String formats the value as 'label'=value[unit];[warn];[crit];[min];[max]
+func (p Perf) String() string {
+	label := p.Label
+	if strings.ContainsAny(label, " '=") {
+		label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	fields := []string{formatNumber(p.Value) + p.Unit, p.Warn, p.Crit, "", ""}
+	if p.Min != nil {
+		fields[3] = formatNumber(*p.Min)
+	}
+	if p.Max != nil {
+		fields[4] = formatNumber(*p.Max)
+	}
+	return label + "=" + strings.TrimRight(strings.Join(fields, ";"), ";")
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	Message  string `json:"message"`
+	Perfdata []Perf `json:"perfdata,omitempty"`
+}
+
+// Unknownf returns an UNKNOWN result, used when the check itself fails
+func Unknownf(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Unknown, Message: fmt.Sprintf(format, args...)}
+}
+
+// String formats the result as a plugin output line:
+// NAME STATUS - message | perfdata
+func (r Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s - %s", strings.ToUpper(r.Name), r.Status, r.Message)
+	if len(r.Perfdata) > 0 {
+		b.WriteString(" |")
+		for _, p := range r.Perfdata {
+			b.WriteString(" " + p.String())
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package check
+
+import "testing"
+
+func TestRangeAlert(t *testing.T) {
+	tests := []struct {
+		spec   string
+		alerts []float64
+		ok     []float64
+	}{
+		{"10", []float64{-1, 10.5, 20}, []float64{0, 5, 10}},
+		{"10:", []float64{-5, 9.9}, []float64{10, 1e9}},
+		{"~:10", []float64{10.1, 50}, []float64{-1e9, 0, 10}},
+		{"10:20", []float64{9, 21}, []float64{10, 15, 20}},
+		{"@10:20", []float64{10, 15, 20}, []float64{9, 21}},
+		{"1:", []float64{0}, []float64{1, 3}},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		for _, v := range tt.alerts {
+			if !r.Alert(v) {
+				t.Errorf("%s: %v should alert", tt.spec, v)
+			}
+		}
+		for _, v := range tt.ok {
+			if r.Alert(v) {
+				t.Errorf("%s: %v should not alert", tt.spec, v)
+			}
+		}
+	}
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for _, spec := range []string{"", "@", "abc", "20:10", "1:x", "~"} {
+		if _, err := ParseRange(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	ranges, err := ParseRanges("5,4,", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranges[0].String() != "5" || ranges[1].String() != "4" || ranges[2] != nil {
+		t.Errorf("got %v", ranges)
+	}
+	ranges, _ = ParseRanges("8", 3)
+	if ranges[2].String() != "8" {
+		t.Errorf("single range not repeated: %v", ranges)
+	}
+	if _, err := ParseRanges("1,2", 3); err == nil {
+		t.Error("expected an error for 2 of 3 ranges")
+	}
+}
+
+func TestThresholdsStatus(t *testing.T) {
+	th, err := ParseThresholds("80", "90")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v, want := range map[float64]Status{50: OK, 85: Warning, 95: Critical} {
+		if got := th.Status(v); got != want {
+			t.Errorf("Status(%v) = %s, want %s", v, got, want)
+		}
+	}
+	if got := (Thresholds{}).Status(1e9); got != OK {
+		t.Errorf("no thresholds: %s", got)
+	}
+}
+
+func TestWorst(t *testing.T) {
+	if got := Worst(OK, Unknown, Warning); got != Warning {
+		t.Errorf("got %s", got)
+	}
+	if got := Worst(Critical, Warning); got != Critical {
+		t.Errorf("got %s", got)
+	}
+	if got := Worst(); got != OK {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	th, _ := ParseThresholds("80", "90")
+	r := Result{
+		Name:    "disk",
+		Status:  Warning,
+		Message: "/var 85.25% used",
+		Perfdata: []Perf{
+			NewPerf("/var", 85.254, "%", th).WithRange(0, 100),
+			NewPerf("my disk", 3, "", Thresholds{}).WithMin(0),
+			NewPerf("load1", 0.5, "", Thresholds{}),
+		},
+	}
+	want := "DISK WARNING - /var 85.25% used | /var=85.25%;80;90;0;100 'my disk'=3;;;0 load1=0.5"
+	if got := r.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
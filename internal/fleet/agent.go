@@ -0,0 +1,227 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// Defaults for an Agent
+const (
+	DefaultAgentInterval = 15 * time.Second
+	DefaultAgentBuffer   = 240
+)
+
+// Bounds on pushes
+const (
+	// maxPushBatch is how many buffered snapshots go in one push
+	maxPushBatch = 20
+	// maxPushBackoff caps the wait between attempts while the server is
+	// unreachable
+	maxPushBackoff = 5 * time.Minute
+	pushTimeout    = 30 * time.Second
+)
+
+// Agent collects snapshots of this host and pushes them to a central
+// server. Snapshots taken while the server is unreachable are buffered and
+// delivered in order once it is back.
+type Agent struct {
+	// Server is the base URL of the central sysinfo server
+	Server string
+	// Token is sent as a bearer token to register with the server
+	Token  string
+	Node   string
+	Labels map[string]string
+	// Interval between snapshots, DefaultAgentInterval when zero
+	Interval time.Duration
+	// Buffer is how many snapshots are kept while the server is
+	// unreachable, the oldest are dropped first; DefaultAgentBuffer when zero
+	Buffer  int
+	Version string
+	// Collect takes a snapshot, CollectSnapshot with default options when nil
+	Collect func(ctx context.Context) (Snapshot, error)
+	Client  *http.Client
+	// Logf reports delivery problems, log.Printf when nil
+	Logf func(format string, args ...interface{})
+
+	queue       []Snapshot
+	dropped     int
+	failures    int
+	nextAttempt time.Time
+}
+
+// CollectSnapshot collects the system information and listening ports of
+// this host
+func CollectSnapshot(ctx context.Context, opts sysinfo.Options) (Snapshot, error) {
+	info, err := sysinfo.GetSystemInfoContext(ctx, opts)
+	if info == nil {
+		return Snapshot{}, err
+	}
+	ports, err := sysinfo.GetOpenPorts()
+	if err != nil {
+		// The rest of the snapshot is still worth having
+		info.Warnings = append(info.Warnings, sysinfo.SectionError{Section: "ports", Message: err.Error()})
+	}
+	return Snapshot{CollectedAt: time.Now(), Info: info, Ports: ports}, nil
+}
+
+// Run takes a snapshot every Interval and pushes it until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) {
+	interval := a.interval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a.tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick takes a snapshot and pushes the buffer, unless backing off after
+// failed pushes
+func (a *Agent) tick(ctx context.Context, now time.Time) {
+	collect := a.Collect
+	if collect == nil {
+		collect = func(ctx context.Context) (Snapshot, error) {
+			return CollectSnapshot(ctx, sysinfo.Options{})
+		}
+	}
+	snap, err := collect(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			a.logf("collect: %v", err)
+		}
+	} else {
+		a.enqueue(snap)
+	}
+
+	if len(a.queue) == 0 || now.Before(a.nextAttempt) {
+		return
+	}
+	sent, err := a.flush(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		a.failures++
+		a.nextAttempt = now.Add(a.backoff())
+		a.logf("push to %s failed, %d snapshots buffered, retrying in %s: %v",
+			a.Server, len(a.queue), a.backoff(), err)
+		return
+	}
+	if a.failures > 0 {
+		a.logf("push to %s recovered, delivered %d buffered snapshots", a.Server, sent)
+		if a.dropped > 0 {
+			a.logf("%d snapshots were dropped while the buffer was full", a.dropped)
+		}
+	}
+	a.failures, a.dropped, a.nextAttempt = 0, 0, time.Time{}
+}
+
+// enqueue buffers a snapshot, dropping the oldest when the buffer is full
+func (a *Agent) enqueue(s Snapshot) {
+	a.queue = append(a.queue, s)
+	if limit := a.buffer(); len(a.queue) > limit {
+		a.dropped += len(a.queue) - limit
+		a.queue = append(a.queue[:0], a.queue[len(a.queue)-limit:]...)
+	}
+}
+
+// flush pushes the buffered snapshots oldest first, in batches, and
+// returns how many were delivered
+func (a *Agent) flush(ctx context.Context) (int, error) {
+	sent := 0
+	for len(a.queue) > 0 {
+		n := min(len(a.queue), maxPushBatch)
+		if err := a.push(ctx, a.queue[:n]); err != nil {
+			return sent, err
+		}
+		a.queue = a.queue[n:]
+		sent += n
+	}
+	a.queue = nil
+	return sent, nil
+}
+
+// push sends one batch of snapshots. A push the server refuses as
+// malformed is dropped rather than retried forever.
+func (a *Agent) push(ctx context.Context, snapshots []Snapshot) error {
+	var body bytes.Buffer
+	p := &Push{Node: a.Node, Labels: a.Labels, Interval: a.interval().Seconds(), Version: a.Version, Snapshots: snapshots}
+	if err := EncodePush(&body, p); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(a.Server, "/")+PushPath, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge:
+		a.logf("server rejected %d snapshots: %s %s", len(snapshots), resp.Status, bytes.TrimSpace(msg))
+		return nil
+	}
+	return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+// backoff is the wait after the latest run of failures, doubling from the
+// interval up to maxPushBackoff
+func (a *Agent) backoff() time.Duration {
+	d := a.interval()
+	for i := 1; i < a.failures && d < maxPushBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxPushBackoff)
+}
+
+func (a *Agent) interval() time.Duration {
+	if a.Interval > 0 {
+		return a.Interval
+	}
+	return DefaultAgentInterval
+}
+
+func (a *Agent) buffer() int {
+	if a.Buffer > 0 {
+		return a.Buffer
+	}
+	return DefaultAgentBuffer
+}
+
+func (a *Agent) logf(format string, args ...interface{}) {
+	if a.Logf != nil {
+		a.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
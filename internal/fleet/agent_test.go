@@ -0,0 +1,137 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// fakeCentral decodes pushes into a registry, failing while down is set
+type fakeCentral struct {
+	registry *Registry
+
+	mu     sync.Mutex
+	down   bool
+	pushes []*Push
+	tokens []string
+}
+
+func (f *fakeCentral) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = append(f.tokens, r.Header.Get("Authorization"))
+	if f.down {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	p, err := DecodePush(r.Body, r.Header.Get("Content-Encoding") == "gzip")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.pushes = append(f.pushes, p)
+	f.registry.Record(p, r.RemoteAddr, time.Now())
+}
+
+func (f *fakeCentral) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+// testAgent returns an agent whose snapshots are numbered by their
+// process count
+func testAgent(server string) *Agent {
+	n := 0
+	return &Agent{
+		Server:   server,
+		Token:    "secret",
+		Node:     "web1",
+		Interval: time.Minute,
+		Buffer:   5,
+		Logf:     func(string, ...interface{}) {},
+		Collect: func(context.Context) (Snapshot, error) {
+			n++
+			info := &sysinfo.SystemInfo{Hostname: "web1", ProcessCount: uint64(n), Collectors: []string{sysinfo.SectionHost}}
+			return Snapshot{CollectedAt: base.Add(time.Duration(n) * time.Minute), Info: info}, nil
+		},
+	}
+}
+
+func TestAgentPushes(t *testing.T) {
+	central := &fakeCentral{registry: NewRegistry(RegistryConfig{})}
+	srv := httptest.NewServer(central)
+	defer srv.Close()
+
+	a := testAgent(srv.URL + "/")
+	a.tick(context.Background(), base)
+	if len(central.pushes) != 1 || central.tokens[0] != "Bearer secret" {
+		t.Fatalf("pushes %d with tokens %q", len(central.pushes), central.tokens)
+	}
+	p := central.pushes[0]
+	if p.Node != "web1" || p.Interval != 60 || len(p.Snapshots) != 1 || p.Snapshots[0].Info.ProcessCount != 1 {
+		t.Errorf("push = %+v", p)
+	}
+	if len(a.queue) != 0 {
+		t.Errorf("%d snapshots left in the queue", len(a.queue))
+	}
+}
+
+func TestAgentBuffersWhileDown(t *testing.T) {
+	central := &fakeCentral{registry: NewRegistry(RegistryConfig{}), down: true}
+	srv := httptest.NewServer(central)
+	defer srv.Close()
+
+	a := testAgent(srv.URL)
+	now := base
+	for i := 0; i < 8; i++ {
+		a.tick(context.Background(), now)
+		now = now.Add(time.Minute)
+	}
+	// The buffer keeps the newest 5, and retries back off: attempts at
+	// minutes 0, 1, 3 and 7
+	if len(a.queue) != 5 || a.queue[0].Info.ProcessCount != 4 || a.dropped != 3 {
+		t.Errorf("queue of %d from #%d, %d dropped", len(a.queue), a.queue[0].Info.ProcessCount, a.dropped)
+	}
+	if attempts := len(central.tokens); attempts != 4 {
+		t.Errorf("%d push attempts while down, want 4", attempts)
+	}
+
+	central.setDown(false)
+	a.tick(context.Background(), now.Add(10*time.Minute))
+	if len(a.queue) != 0 || a.failures != 0 {
+		t.Fatalf("after recovery: %d queued, %d failures", len(a.queue), a.failures)
+	}
+	// Delivered oldest first, the new snapshot pushing #4 out of the buffer
+	var got []uint64
+	for _, p := range central.pushes {
+		for _, s := range p.Snapshots {
+			got = append(got, s.Info.ProcessCount)
+		}
+	}
+	if fmt.Sprint(got) != "[5 6 7 8 9]" {
+		t.Errorf("delivered %v", got)
+	}
+	if status, _, _ := central.registry.Node("web1"); status.Snapshots != 5 {
+		t.Errorf("registry has %d snapshots", status.Snapshots)
+	}
+}
+
+func TestAgentDropsRejectedPush(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad push", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	a := testAgent(srv.URL)
+	a.tick(context.Background(), base)
+	if len(a.queue) != 0 || a.failures != 0 {
+		t.Errorf("rejected push kept: %d queued, %d failures", len(a.queue), a.failures)
+	}
+}
@@ -0,0 +1,167 @@
+package fleet
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// filterOps are the comparisons a Filter supports, two character operators
+// first so they win over their one character prefixes
+var filterOps = []string{"!=", ">=", "<=", "=", ">", "<", "~"}
+
+// Filter selects rows whose field compares to a value, e.g. port=5432 or
+// mount_percent>90. Fields are named by their json tags. Numbers compare
+// numerically, other values as case-insensitive text; ~ matches a substring.
+type Filter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// ParseFilter parses a filter written as field, operator and value
+func ParseFilter(s string) (Filter, error) {
+	at := strings.IndexAny(s, "!=<>~")
+	if at <= 0 {
+		return Filter{}, fmt.Errorf("invalid filter %q, want field<op>value with op one of %s", s, strings.Join(filterOps, " "))
+	}
+	f := Filter{Field: strings.TrimSpace(s[:at])}
+	for _, op := range filterOps {
+		if strings.HasPrefix(s[at:], op) {
+			f.Op = op
+			break
+		}
+	}
+	if f.Op == "" {
+		return Filter{}, fmt.Errorf("invalid operator in filter %q", s)
+	}
+	f.Value = strings.TrimSpace(s[at+len(f.Op):])
+	return f, nil
+}
+
+func (f Filter) String() string { return f.Field + f.Op + f.Value }
+
+// Check reports an error when rows like row have no field named f.Field
+func (f Filter) Check(row interface{}) error {
+	if _, ok := fieldByTag(reflect.ValueOf(row), f.Field); !ok {
+		return fmt.Errorf("filter %s: unknown field %q (available: %s)", f, f.Field, strings.Join(fieldTags(reflect.TypeOf(row)), ", "))
+	}
+	return nil
+}
+
+// Match reports whether row passes the filter, rows without the field
+// never do
+func (f Filter) Match(row interface{}) bool {
+	v, ok := fieldByTag(reflect.ValueOf(row), f.Field)
+	if !ok {
+		return false
+	}
+	var have string
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		have = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		have = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		have = strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		have = strconv.FormatBool(v.Bool())
+	default:
+		have = fmt.Sprint(v.Interface())
+	}
+	if f.Op == "~" {
+		return strings.Contains(strings.ToLower(have), strings.ToLower(f.Value))
+	}
+
+	var cmp int
+	x, xerr := strconv.ParseFloat(have, 64)
+	y, yerr := strconv.ParseFloat(f.Value, 64)
+	if xerr == nil && yerr == nil {
+		switch {
+		case x < y:
+			cmp = -1
+		case x > y:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(strings.ToLower(have), strings.ToLower(f.Value))
+	}
+	switch f.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+// MatchAll reports whether row passes every filter
+func MatchAll(filters []Filter, row interface{}) bool {
+	for _, f := range filters {
+		if !f.Match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByTag finds the field of struct v whose json tag is name, looking
+// into embedded structs the way encoding/json flattens them
+func fieldByTag(v reflect.Value, name string) (reflect.Value, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if fv, ok := fieldByTag(v.Field(i), name); ok {
+				return fv, true
+			}
+			continue
+		}
+		if field.IsExported() && jsonName(field) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// fieldTags lists the json names of the fields of struct type t
+func fieldTags(t reflect.Type) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			names = append(names, fieldTags(field.Type)...)
+		} else if name := jsonName(field); field.IsExported() && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name
+}
@@ -0,0 +1,84 @@
+package fleet
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/junler/sysinfo/internal/remote"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// Defaults for a Poller
+const (
+	DefaultPollInterval = 30 * time.Second
+	DefaultPollTimeout  = 10 * time.Second
+)
+
+// Poller fetches snapshots from sysinfo servers into a Registry, for hosts
+// that run "sysinfo serve" rather than an agent
+type Poller struct {
+	Registry *Registry
+	Targets  []Target
+	// Interval between polls, DefaultPollInterval when zero
+	Interval time.Duration
+	// Timeout for polling one node, DefaultPollTimeout when zero
+	Timeout time.Duration
+	// Token is sent to the servers as a bearer token when set
+	Token  string
+	Client *http.Client
+}
+
+// Run polls every target each Interval until ctx is cancelled
+func (p *Poller) Run(ctx context.Context) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for _, t := range p.Targets {
+		p.Registry.Register(t, interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.PollAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollAll polls every target concurrently and records the results
+func (p *Poller) PollAll(ctx context.Context) {
+	results := Query(ctx, p.Targets, DefaultQueryWorkers, p.poll)
+	if ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+	for _, r := range results {
+		p.Registry.RecordPoll(r.Target.Name, r.Value, r.Err, now)
+	}
+}
+
+// poll fetches the system information and ports of one target
+func (p *Poller) poll(ctx context.Context, t Target) (Snapshot, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultPollTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := remote.NewClientWith(t.URL, p.Token, p.Client)
+	info, collectedAt, err := client.Info(ctx, nil)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	ports, err := client.Ports(ctx)
+	if err != nil {
+		info.Warnings = append(info.Warnings, sysinfo.SectionError{Section: "ports", Message: err.Error()})
+	}
+	return Snapshot{CollectedAt: collectedAt, Info: info, Ports: ports}, nil
+}
@@ -0,0 +1,90 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets(strings.NewReader(`
+# web tier
+http://web1:8080/
+db-primary   https://10.0.0.5:8443
+10.0.0.6:8080
+http://10.0.0.6:8081
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{
+		{Name: "web1:8080", URL: "http://web1:8080"},
+		{Name: "db-primary", URL: "https://10.0.0.5:8443"},
+		{Name: "10.0.0.6:8080", URL: "http://10.0.0.6:8080"},
+		{Name: "10.0.0.6:8081", URL: "http://10.0.0.6:8081"},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("targets = %+v", targets)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d = %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"a b c", "ftp://x", "web1 http://a\nweb1 http://b"} {
+		if _, err := ParseTargets(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseTargets(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestPoller(t *testing.T) {
+	collectedAt := time.Now().Add(-5 * time.Second).UTC()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Collected-At", collectedAt.Format(time.RFC3339Nano))
+		switch r.URL.Path {
+		case "/api/info":
+			json.NewEncoder(w).Encode(sysinfo.SystemInfo{
+				Hostname: "web1",
+				CPU:      sysinfo.CPUInfo{Usage: []float64{10, 30}},
+				Memory:   sysinfo.MemoryInfo{UsedPercent: 55},
+				Disk:     []sysinfo.DiskInfo{{Mountpoint: "/", UsedPercent: 40}, {Mountpoint: "/var", UsedPercent: 91}},
+			})
+		case "/api/ports":
+			json.NewEncoder(w).Encode([]sysinfo.PortInfo{{Port: "22"}, {Port: "443"}})
+		}
+	}))
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	r := NewRegistry(RegistryConfig{})
+	p := &Poller{Registry: r, Targets: []Target{{Name: "web1", URL: srv.URL}, {Name: "gone", URL: down.URL}}}
+	for _, target := range p.Targets {
+		r.Register(target, time.Minute)
+	}
+	if nodes := r.Nodes(); nodes[0].Status != StatusPending {
+		t.Errorf("before polling: %+v", nodes[0])
+	}
+	p.PollAll(context.Background())
+
+	nodes := r.Nodes()
+	if gone := nodes[0]; gone.Status != StatusUnreachable || gone.Error == "" || gone.Source != SourcePoll {
+		t.Errorf("unreachable node: %+v", gone)
+	}
+	web := nodes[1]
+	if web.Status != StatusOnline || web.URL != srv.URL || !web.CollectedAt.Equal(collectedAt) {
+		t.Fatalf("polled node: %+v", web)
+	}
+	sum := web.Summary
+	if sum == nil || sum.CPUPercent != 20 || sum.MemoryPercent != 55 || sum.WorstMount != "/var" || sum.WorstMountPercent != 91 || sum.OpenPorts != 2 {
+		t.Errorf("summary = %+v", sum)
+	}
+}
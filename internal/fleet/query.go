@@ -0,0 +1,47 @@
+package fleet
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultQueryWorkers bounds how many nodes Query contacts at once
+const DefaultQueryWorkers = 16
+
+// Result is the outcome of querying one node
+type Result[T any] struct {
+	Target Target
+	Value  T
+	Err    error
+}
+
+// Query calls fn for every target, with at most workers calls running at
+// once, and returns the results in the order of targets
+func Query[T any](ctx context.Context, targets []Target, workers int, fn func(context.Context, Target) (T, error)) []Result[T] {
+	if workers <= 0 {
+		workers = DefaultQueryWorkers
+	}
+	results := make([]Result[T], len(targets))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(targets)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i].Target = targets[i]
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Value, results[i].Err = fn(ctx, targets[i])
+			}
+		}()
+	}
+	for i := range targets {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,95 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func TestQuery(t *testing.T) {
+	var targets []Target
+	for i := 0; i < 20; i++ {
+		targets = append(targets, Target{Name: fmt.Sprintf("n%d", i)})
+	}
+	var running, peak atomic.Int32
+	results := Query(context.Background(), targets, 4, func(ctx context.Context, target Target) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if target.Name == "n7" {
+			return "", errors.New("down")
+		}
+		return "hello " + target.Name, nil
+	})
+
+	if p := peak.Load(); p > 4 {
+		t.Errorf("%d queries ran at once, want at most 4", p)
+	}
+	if len(results) != len(targets) {
+		t.Fatalf("%d results", len(results))
+	}
+	for i, r := range results {
+		if r.Target != targets[i] {
+			t.Errorf("result %d is for %s", i, r.Target.Name)
+		}
+		if (r.Err != nil) != (r.Target.Name == "n7") || (r.Err == nil && r.Value != "hello "+r.Target.Name) {
+			t.Errorf("result %d = %+v", i, r)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	type portRow struct {
+		Host string `json:"host"`
+		sysinfo.PortInfo
+	}
+	row := portRow{Host: "DB1", PortInfo: sysinfo.PortInfo{Port: "5432", Protocol: "TCP", PID: 812, Process: "postgres"}}
+
+	for _, tc := range []struct {
+		filter string
+		want   bool
+	}{
+		{"port=5432", true},
+		{"port = 5432", true},
+		{"port!=5432", false},
+		{"port>1024", true},
+		{"port<=80", false},
+		{"pid>=812", true},
+		{"host=db1", true},
+		{"protocol=udp", false},
+		{"process~gres", true},
+		{"process~mysql", false},
+	} {
+		f, err := ParseFilter(tc.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", tc.filter, err)
+		}
+		if err := f.Check(row); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Match(row); got != tc.want {
+			t.Errorf("%s matched %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"port", "=5432", "port!5432"} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded", bad)
+		}
+	}
+	f, _ := ParseFilter("nope=1")
+	if err := f.Check(row); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
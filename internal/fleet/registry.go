@@ -0,0 +1,334 @@
+package fleet
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/junler/sysinfo/internal/history"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// minStaleAfter is the least time without pushes before a node is stale,
+// however often it pushes
+const minStaleAfter = 30 * time.Second
+
+// defaultAgentInterval is assumed for agents that don't send theirs
+const defaultAgentInterval = 15 * time.Second
+
+// MinPushInterval is the shortest collection interval a push may claim
+const MinPushInterval = time.Second
+
+// Defaults for the pushed nodes a Registry keeps
+const (
+	DefaultMaxPushedNodes = 500
+	DefaultPushedNodeTTL  = 24 * time.Hour
+)
+
+// ErrTooManyNodes is returned by Record for a new node when the registry
+// already holds RegistryConfig.MaxPushedNodes pushed nodes
+var ErrTooManyNodes = errors.New("too many pushing nodes")
+
+// maxClockSkew is how far ahead of the server's clock a snapshot may be
+// dated before it is taken to be collected now
+const maxClockSkew = time.Minute
+
+// Status is whether a node is reporting
+type Status string
+
+const (
+	StatusOnline Status = "online"
+	// StatusStale nodes missed three of their pushes or polls in a row
+	StatusStale Status = "stale"
+	// StatusUnreachable nodes failed their latest poll
+	StatusUnreachable Status = "unreachable"
+	// StatusPending nodes were configured but not polled yet
+	StatusPending Status = "pending"
+)
+
+// How a node's snapshots reach the registry
+const (
+	SourcePush = "push"
+	SourcePoll = "poll"
+)
+
+// NodeStatus describes a known node and when it was last heard from
+type NodeStatus struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Status Status            `json:"status"`
+	Source string            `json:"source"`
+	// URL is the node's own sysinfo server, for polled nodes
+	URL     string `json:"url,omitempty"`
+	Address string `json:"address,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Error is why the latest poll failed
+	Error string `json:"error,omitempty"`
+	// LastSeen is when the node last pushed or was polled, CollectedAt
+	// when its newest snapshot was taken
+	LastSeen    time.Time `json:"last_seen"`
+	CollectedAt time.Time `json:"collected_at"`
+	Snapshots   uint64    `json:"snapshots"`
+	// Summary of the newest snapshot, nil before the first one
+	Summary *Summary `json:"summary,omitempty"`
+}
+
+// Summary is the gist of a snapshot for the fleet overview
+type Summary struct {
+	Hostname      string                  `json:"hostname"`
+	OS            string                  `json:"os"`
+	Uptime        string                  `json:"uptime"`
+	CPUPercent    float64                 `json:"cpu_percent"`
+	MemoryPercent float64                 `json:"memory_percent"`
+	LoadAverage   sysinfo.LoadAverageInfo `json:"load_average"`
+	// WorstMount is the fullest mount
+	WorstMount        string  `json:"worst_mount,omitempty"`
+	WorstMountPercent float64 `json:"worst_mount_percent"`
+	ProcessCount      uint64  `json:"process_count"`
+	OpenPorts         int     `json:"open_ports"`
+	Problems          int     `json:"problems"`
+}
+
+// Summarize condenses a snapshot into the figures shown in fleet overviews
+func Summarize(s Snapshot) *Summary {
+	info := s.Info
+	sum := &Summary{
+		Hostname:      info.Hostname,
+		OS:            info.OS,
+		Uptime:        info.Uptime,
+		MemoryPercent: info.Memory.UsedPercent,
+		LoadAverage:   info.LoadAverage,
+		ProcessCount:  info.ProcessCount,
+		OpenPorts:     len(s.Ports),
+		Problems:      len(info.Errors) + len(info.Warnings),
+	}
+	if len(info.CPU.Usage) > 0 {
+		for _, usage := range info.CPU.Usage {
+			sum.CPUPercent += usage
+		}
+		sum.CPUPercent /= float64(len(info.CPU.Usage))
+	}
+	for _, d := range info.Disk {
+		if sum.WorstMount == "" || d.UsedPercent > sum.WorstMountPercent {
+			sum.WorstMount, sum.WorstMountPercent = d.Mountpoint, d.UsedPercent
+		}
+	}
+	return sum
+}
+
+// RegistryConfig tunes a Registry
+type RegistryConfig struct {
+	// HistoryRetention is how long each node's metrics are kept for
+	// History; 0 keeps no history
+	HistoryRetention time.Duration
+	// HistoryResolution is the interval each node's history is kept at,
+	// history.DefaultResolution when zero
+	HistoryResolution time.Duration
+	// MaxPushedNodes caps the nodes created by pushes, each with its own
+	// history, DefaultMaxPushedNodes when zero. Polled nodes don't count.
+	MaxPushedNodes int
+	// PushedNodeTTL is how long a pushed node is kept after its last
+	// push, DefaultPushedNodeTTL when zero
+	PushedNodeTTL time.Duration
+}
+
+// Registry keeps the latest snapshot of every node that pushed one or is
+// polled
+type Registry struct {
+	cfg RegistryConfig
+
+	mu    sync.RWMutex
+	nodes map[string]*node
+}
+
+type node struct {
+	status   NodeStatus
+	interval time.Duration
+	latest   Snapshot
+	history  *history.MemoryStore
+}
+
+// nodeLocked returns the named node, adding it if new
+func (r *Registry) nodeLocked(name, source string, interval time.Duration) *node {
+	n := r.nodes[name]
+	if n == nil {
+		n = &node{status: NodeStatus{Name: name}}
+		r.nodes[name] = n
+	}
+	n.status.Source = source
+	n.interval = interval
+	if n.interval <= 0 {
+		n.interval = defaultAgentInterval
+	}
+	n.interval = max(n.interval, MinPushInterval)
+	if n.history == nil && r.cfg.HistoryRetention > 0 {
+		// Sized by the server, not by whatever interval a node claims
+		resolution := r.cfg.HistoryResolution
+		if resolution <= 0 {
+			resolution = history.DefaultResolution
+		}
+		n.history = history.NewMemoryStore(r.cfg.HistoryRetention, resolution)
+	}
+	return n
+}
+
+// add records a snapshot if it is newer than the latest. Snapshots dated
+// well after now, from a node with a skewed clock, count as taken now so
+// they can't hold back the ones that follow.
+func (r *Registry) add(n *node, s Snapshot, now time.Time) bool {
+	if s.CollectedAt.After(now.Add(maxClockSkew)) {
+		s.CollectedAt = now
+	}
+	if !s.CollectedAt.After(n.latest.CollectedAt) {
+		return false
+	}
+	if n.history != nil {
+		var rates *sysinfo.Rates
+		if n.latest.Info != nil {
+			rt := sysinfo.NewRates(n.latest.Info, s.Info, s.CollectedAt.Sub(n.latest.CollectedAt))
+			rates = &rt
+		}
+		n.history.Append(s.CollectedAt, history.Samples(s.Info, rates))
+	}
+	n.latest = s
+	n.status.CollectedAt = s.CollectedAt
+	n.status.Snapshots++
+	n.status.Summary = Summarize(s)
+	return true
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(cfg RegistryConfig) *Registry {
+	if cfg.MaxPushedNodes <= 0 {
+		cfg.MaxPushedNodes = DefaultMaxPushedNodes
+	}
+	if cfg.PushedNodeTTL <= 0 {
+		cfg.PushedNodeTTL = DefaultPushedNodeTTL
+	}
+	return &Registry{cfg: cfg, nodes: make(map[string]*node)}
+}
+
+// Record stores the snapshots of a push received from addr at now and
+// returns how many were newer than what the node had pushed before. Older
+// ones, e.g. a retried push that had already arrived, are skipped. A new
+// node is refused with ErrTooManyNodes once the registry is full.
+func (r *Registry) Record(p *Push, addr string, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pushed := r.expireLocked(now)
+	if _, ok := r.nodes[p.Node]; !ok && pushed >= r.cfg.MaxPushedNodes {
+		return 0, ErrTooManyNodes
+	}
+	n := r.nodeLocked(p.Node, SourcePush, time.Duration(p.Interval*float64(time.Second)))
+	n.status.Labels = p.Labels
+	n.status.Address = addr
+	n.status.Version = p.Version
+	n.status.LastSeen = now
+
+	recorded := 0
+	for _, s := range p.Snapshots {
+		if r.add(n, s, now) {
+			recorded++
+		}
+	}
+	return recorded, nil
+}
+
+// expireLocked forgets the pushed nodes that stopped pushing more than
+// PushedNodeTTL ago and returns how many pushed nodes are left
+func (r *Registry) expireLocked(now time.Time) int {
+	count := 0
+	for name, n := range r.nodes {
+		if n.status.Source != SourcePush {
+			continue
+		}
+		if now.Sub(n.status.LastSeen) > r.cfg.PushedNodeTTL {
+			delete(r.nodes, name)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// Register adds a node to be polled every interval, pending until its
+// first poll
+func (r *Registry) Register(t Target, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.nodeLocked(t.Name, SourcePoll, interval)
+	n.status.URL = t.URL
+}
+
+// RecordPoll stores the result of polling a registered node at now. A
+// failed poll marks the node unreachable until the next one succeeds.
+func (r *Registry) RecordPoll(name string, s Snapshot, err error, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.nodes[name]
+	if !ok {
+		return
+	}
+	if err != nil {
+		n.status.Error = err.Error()
+		return
+	}
+	n.status.Error = ""
+	n.status.LastSeen = now
+	r.add(n, s, now)
+}
+
+// statusAt returns the node's status as of now
+func (n *node) statusAt(now time.Time) NodeStatus {
+	status := n.status
+	switch {
+	case status.Error != "":
+		status.Status = StatusUnreachable
+	case status.LastSeen.IsZero():
+		status.Status = StatusPending
+	case now.Sub(status.LastSeen) > max(3*n.interval, minStaleAfter):
+		status.Status = StatusStale
+	default:
+		status.Status = StatusOnline
+	}
+	return status
+}
+
+// Nodes returns the status of every node, by name
+func (r *Registry) Nodes() []NodeStatus {
+	now := time.Now()
+	r.mu.RLock()
+	nodes := make([]NodeStatus, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		nodes = append(nodes, n.statusAt(now))
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+// Node returns the status and latest snapshot of a node. The snapshot is
+// shared and must not be modified.
+func (r *Registry) Node(name string) (NodeStatus, Snapshot, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n, ok := r.nodes[name]
+	if !ok {
+		return NodeStatus{}, Snapshot{}, false
+	}
+	return n.statusAt(time.Now()), n.latest, true
+}
+
+// History returns the metrics recorded from a node's snapshots, nil when
+// the node is unknown or history is disabled
+func (r *Registry) History(name string) history.Store {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if n, ok := r.nodes[name]; ok && n.history != nil {
+		return n.history
+	}
+	return nil
+}
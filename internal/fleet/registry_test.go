@@ -0,0 +1,135 @@
+package fleet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/junler/sysinfo/internal/history"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+var base = time.Unix(1700000000, 0)
+
+func netSnapshot(at time.Time, recv uint64) Snapshot {
+	info := &sysinfo.SystemInfo{
+		Collectors: []string{sysinfo.SectionNetwork, sysinfo.SectionLoad},
+		Network:    sysinfo.NetworkInfo{BytesRecv: recv},
+	}
+	return Snapshot{CollectedAt: at, Info: info}
+}
+
+func TestRegistryRecord(t *testing.T) {
+	r := NewRegistry(RegistryConfig{HistoryRetention: time.Hour})
+	push := &Push{Node: "db1", Interval: 10, Labels: map[string]string{"env": "prod"}, Snapshots: []Snapshot{
+		netSnapshot(base, 1000),
+		netSnapshot(base.Add(10*time.Second), 11000),
+	}}
+	if n, _ := r.Record(push, "10.0.0.5", time.Now()); n != 2 {
+		t.Fatalf("recorded %d snapshots, want 2", n)
+	}
+	// A retried push that already arrived changes nothing
+	if n, _ := r.Record(push, "10.0.0.5", time.Now()); n != 0 {
+		t.Errorf("recorded %d snapshots again", n)
+	}
+
+	status, snap, ok := r.Node("db1")
+	if !ok || status.Status != StatusOnline || status.Snapshots != 2 || status.Labels["env"] != "prod" || status.Address != "10.0.0.5" {
+		t.Fatalf("status = %+v", status)
+	}
+	if snap.Info.Network.BytesRecv != 11000 || !status.CollectedAt.Equal(base.Add(10*time.Second)) {
+		t.Errorf("latest snapshot = %+v", snap)
+	}
+
+	// Rates come from consecutive snapshots of the node
+	series, err := r.History("db1").Query(history.Query{
+		Metrics: []string{"net.recv_bytes_per_sec"}, Since: base, Until: base.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || len(series[0].Points) != 1 || series[0].Points[0].Value != 1000 {
+		t.Errorf("history = %+v", series)
+	}
+	if r.History("nope") != nil {
+		t.Error("history for an unknown node")
+	}
+}
+
+func TestRegistryClockSkew(t *testing.T) {
+	r := NewRegistry(RegistryConfig{})
+	now := time.Now()
+	r.Record(&Push{Node: "db1", Snapshots: []Snapshot{netSnapshot(now.Add(24*time.Hour), 1)}}, "", now)
+	_, snap, _ := r.Node("db1")
+	if !snap.CollectedAt.Equal(now) {
+		t.Errorf("future snapshot recorded at %s, want %s", snap.CollectedAt, now)
+	}
+	// The node's next snapshot isn't held back by the skewed one
+	if n, _ := r.Record(&Push{Node: "db1", Snapshots: []Snapshot{netSnapshot(now.Add(time.Second), 2)}}, "", now.Add(time.Second)); n != 1 {
+		t.Errorf("recorded %d snapshots after a skewed one", n)
+	}
+}
+
+func TestRegistryPushedNodeLimits(t *testing.T) {
+	r := NewRegistry(RegistryConfig{MaxPushedNodes: 2, PushedNodeTTL: time.Hour})
+	r.Register(Target{Name: "polled", URL: "http://polled:8080"}, time.Minute)
+	r.Record(&Push{Node: "a"}, "", base)
+	r.Record(&Push{Node: "b"}, "", base.Add(30*time.Minute))
+	if _, err := r.Record(&Push{Node: "c"}, "", base.Add(45*time.Minute)); !errors.Is(err, ErrTooManyNodes) {
+		t.Errorf("third pushed node: %v, want ErrTooManyNodes", err)
+	}
+	// Known nodes keep pushing when the registry is full
+	if _, err := r.Record(&Push{Node: "b"}, "", base.Add(50*time.Minute)); err != nil {
+		t.Errorf("known node refused: %v", err)
+	}
+
+	// Nodes that stopped pushing make room, polled ones stay
+	if _, err := r.Record(&Push{Node: "c"}, "", base.Add(90*time.Minute)); err != nil {
+		t.Fatalf("pushed node refused after another expired: %v", err)
+	}
+	got := ""
+	for _, n := range r.Nodes() {
+		got += n.Name + " "
+	}
+	if got != "b c polled " {
+		t.Errorf("nodes = %q, want b c polled", got)
+	}
+}
+
+func TestRegistryStale(t *testing.T) {
+	r := NewRegistry(RegistryConfig{})
+	r.Record(&Push{Node: "b", Interval: 60}, "", time.Now().Add(-2*time.Minute))
+	r.Record(&Push{Node: "a", Interval: 60}, "", time.Now().Add(-4*time.Minute))
+	r.Record(&Push{Node: "c", Interval: 1}, "", time.Now().Add(-20*time.Second))
+
+	nodes := r.Nodes()
+	got := ""
+	for _, n := range nodes {
+		got += n.Name + "=" + string(n.Status) + " "
+	}
+	// Three missed pushes, but at least 30 seconds
+	if want := "a=stale b=online c=online "; got != want {
+		t.Errorf("nodes: %s, want %s", got, want)
+	}
+}
+
+func TestPushRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	p := &Push{Node: "web1", Snapshots: []Snapshot{netSnapshot(base, 5)}}
+	if err := EncodePush(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodePush(&buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Node != "web1" || len(got.Snapshots) != 1 || got.Snapshots[0].Info.Network.BytesRecv != 5 {
+		t.Errorf("decoded %+v", got)
+	}
+
+	for _, body := range []string{`{"snapshots": []}`, `{"node": "x", "snapshots": [{}]}`, `{"node": "x", "interval": 0.001}`, `{"node": "x", "interval": -5}`, `not json`} {
+		if _, err := DecodePush(bytes.NewBufferString(body), false); err == nil {
+			t.Errorf("DecodePush(%s) succeeded", body)
+		}
+	}
+}
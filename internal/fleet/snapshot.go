@@ -0,0 +1,79 @@
+// Package fleet gathers the snapshots of many hosts on a central sysinfo
+// server: agents push them and the server keeps the latest of each node
+package fleet
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// PushPath is where a central server accepts pushes
+const PushPath = "/api/agent/push"
+
+// MaxPushSize bounds the decompressed size of one push
+const MaxPushSize = 64 << 20
+
+// Snapshot is the state of a node at one point in time
+type Snapshot struct {
+	CollectedAt time.Time           `json:"collected_at"`
+	Info        *sysinfo.SystemInfo `json:"info"`
+	Ports       []sysinfo.PortInfo  `json:"ports"`
+}
+
+// Push is the body an agent posts to PushPath, gzip compressed. Snapshots
+// that were buffered while the server was unreachable come oldest first.
+type Push struct {
+	Node   string            `json:"node"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Interval is how often the agent collects, used to tell when the
+	// node has gone quiet
+	Interval  float64    `json:"interval"`
+	Version   string     `json:"version,omitempty"`
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// EncodePush writes p as gzip compressed JSON
+func EncodePush(w io.Writer, p *Push) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(p); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// DecodePush reads a push, gunzipping it when compressed is set
+func DecodePush(r io.Reader, compressed bool) (*Push, error) {
+	if compressed {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var p Push
+	dec := json.NewDecoder(io.LimitReader(r, MaxPushSize))
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Node == "" {
+		return nil, fmt.Errorf("push has no node name")
+	}
+	// Zero means the agent didn't say
+	if math.IsNaN(p.Interval) || math.IsInf(p.Interval, 0) || p.Interval < 0 ||
+		(p.Interval > 0 && p.Interval < MinPushInterval.Seconds()) {
+		return nil, fmt.Errorf("invalid push interval %v, must be at least %s", p.Interval, MinPushInterval)
+	}
+	for i, s := range p.Snapshots {
+		if s.Info == nil {
+			return nil, fmt.Errorf("snapshot %d has no info", i)
+		}
+	}
+	return &p, nil
+}
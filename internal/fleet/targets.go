@@ -0,0 +1,72 @@
+package fleet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Target is a sysinfo server to query
+type Target struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// ParseTargets reads a nodes file: one node per line, either a URL or a
+// name and a URL separated by whitespace. Blank lines and lines starting
+// with # are skipped. Without a name the URL's host and port name the node.
+func ParseTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		var t Target
+		switch len(fields) {
+		case 1:
+			t.URL = fields[0]
+		case 2:
+			t.Name, t.URL = fields[0], fields[1]
+		default:
+			return nil, fmt.Errorf("line %d: want [name] URL, got %q", line, text)
+		}
+		if !strings.Contains(t.URL, "://") {
+			t.URL = "http://" + t.URL
+		}
+		u, err := url.Parse(t.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("line %d: invalid URL %q", line, fields[len(fields)-1])
+		}
+		t.URL = strings.TrimRight(t.URL, "/")
+		if t.Name == "" {
+			t.Name = u.Host
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("line %d: duplicate node %q", line, t.Name)
+		}
+		seen[t.Name] = true
+		targets = append(targets, t)
+	}
+	return targets, scanner.Err()
+}
+
+// LoadTargets reads a nodes file, see ParseTargets
+func LoadTargets(path string) ([]Target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	targets, err := ParseTargets(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return targets, nil
+}
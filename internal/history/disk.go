@@ -0,0 +1,430 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default retention of the rollup tiers of a DiskStore
+const (
+	DefaultRetention1m = 30 * 24 * time.Hour
+	DefaultRetention1h = 365 * 24 * time.Hour
+)
+
+// sealedCacheTTL is how long a decoded sealed segment is kept after the
+// last query that read it, longer than the dashboard's refresh interval
+const sealedCacheTTL = 2 * time.Minute
+
+// DiskOptions sets how long each tier of a DiskStore keeps data
+type DiskOptions struct {
+	// Retention applies to raw samples
+	Retention   time.Duration
+	Retention1m time.Duration
+	Retention1h time.Duration
+}
+
+// tier is one resolution of a DiskStore, stored as a directory of segments
+// that each cover one window of time
+type tier struct {
+	name       string
+	resolution time.Duration // rollup bucket width, 0 for raw samples
+	window     time.Duration
+	retention  time.Duration
+	dir        string
+	active     *segment
+	// sealed caches decoded segments older than active by window start.
+	// They no longer change, so only compaction invalidates them.
+	sealed map[int64]*cachedSegment
+
+	// The rollup in progress for the bucket starting at bucket, written
+	// once a sample from a later bucket arrives
+	bucket  int64
+	pending map[string]*aggPoint
+	// last is the start of the newest rollup already written
+	last int64
+}
+
+func (t *tier) rollup() bool {
+	return t.resolution > 0
+}
+
+type cachedSegment struct {
+	*segment
+	used time.Time
+}
+
+// DiskStore is a Store in a data directory. Raw samples are appended to
+// segment files alongside 1 minute and 1 hour rollups (avg, min and max),
+// and each tier is compacted by deleting whole segments once they fall
+// out of its retention. Queries are answered from the finest tier that
+// still covers the requested range.
+type DiskStore struct {
+	mu    sync.Mutex
+	tiers []*tier // finest first
+	// known holds the time of the newest sample of each metric
+	known map[string]int64
+	now   func() time.Time
+}
+
+// OpenDiskStore opens or creates a store in dir. Rollups that were in
+// progress when the store was last closed, or crashed, are rebuilt from
+// the raw samples.
+func OpenDiskStore(dir string, opts DiskOptions) (*DiskStore, error) {
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.Retention1m <= 0 {
+		opts.Retention1m = DefaultRetention1m
+	}
+	if opts.Retention1h <= 0 {
+		opts.Retention1h = DefaultRetention1h
+	}
+
+	s := &DiskStore{
+		tiers: []*tier{
+			{name: "raw", window: time.Hour, retention: opts.Retention},
+			{name: "1m", resolution: time.Minute, window: 24 * time.Hour, retention: opts.Retention1m},
+			{name: "1h", resolution: time.Hour, window: 30 * 24 * time.Hour, retention: opts.Retention1h},
+		},
+		known: make(map[string]int64),
+		now:   time.Now,
+	}
+	for _, t := range s.tiers {
+		t.dir = filepath.Join(dir, t.name)
+		t.last = math.MinInt64
+		t.sealed = make(map[int64]*cachedSegment)
+		if err := s.openTier(t); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("history: open %s: %w", t.dir, err)
+		}
+	}
+	if err := s.recoverRollups(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("history: rebuild rollups: %w", err)
+	}
+	s.compact(s.now().UnixMilli())
+	return s, nil
+}
+
+// openTier reopens the newest segment of a tier for appending
+func (s *DiskStore) openTier(t *tier) error {
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+	starts, err := listSegments(t.dir)
+	if err != nil || len(starts) == 0 {
+		return err
+	}
+	if t.active, err = openSegment(t.dir, starts[len(starts)-1], t.rollup()); err != nil {
+		return err
+	}
+	for name, points := range t.active.points {
+		for _, p := range points {
+			if p.Time > t.last {
+				t.last = p.Time
+			}
+			if p.Time > s.known[name] {
+				s.known[name] = p.Time
+			}
+		}
+	}
+	return nil
+}
+
+// recoverRollups replays the raw samples newer than each tier's last rollup
+func (s *DiskStore) recoverRollups() error {
+	from := int64(math.MaxInt64)
+	for _, t := range s.tiers[1:] {
+		if t.last == math.MinInt64 {
+			from = math.MinInt64
+		} else if next := t.last + t.resolution.Milliseconds(); next < from {
+			from = next
+		}
+	}
+
+	raw, err := s.read(s.tiers[0], from, math.MaxInt64, nil)
+	if err != nil {
+		return err
+	}
+	byTime := make(map[int64]map[string]float64)
+	for name, points := range raw {
+		for _, p := range points {
+			if byTime[p.Time] == nil {
+				byTime[p.Time] = make(map[string]float64)
+			}
+			byTime[p.Time][name] = p.Avg
+		}
+	}
+	times := make([]int64, 0, len(byTime))
+	for ts := range byTime {
+		times = append(times, ts)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	for _, ts := range times {
+		for _, t := range s.tiers[1:] {
+			if err := s.accumulate(t, ts, byTime[ts]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *DiskStore) Append(t time.Time, values map[string]float64) error {
+	if len(values) == 0 {
+		return nil
+	}
+	ts := t.UnixMilli()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	points := make(map[string]aggPoint, len(values))
+	for name, v := range values {
+		points[name] = sample(ts, v)
+		s.known[name] = ts
+	}
+	if err := s.write(s.tiers[0], ts, points); err != nil {
+		return err
+	}
+	for _, tier := range s.tiers[1:] {
+		if err := s.accumulate(tier, ts, values); err != nil {
+			return err
+		}
+	}
+
+	// Forget metrics that stopped reporting once every tier has dropped
+	// their data, such as an unmounted disk
+	var retention time.Duration
+	for _, tier := range s.tiers {
+		retention = max(retention, tier.retention)
+	}
+	cutoff := ts - retention.Milliseconds()
+	for name, last := range s.known {
+		if last < cutoff {
+			delete(s.known, name)
+		}
+	}
+	s.evictSealed()
+	return nil
+}
+
+// accumulate adds a raw sample to a rollup tier, writing out the previous
+// bucket once the sample starts a new one
+func (s *DiskStore) accumulate(t *tier, ts int64, values map[string]float64) error {
+	width := t.resolution.Milliseconds()
+	bucket := ts - ts%width
+	if bucket <= t.last {
+		// Already rolled up, e.g. after the clock went backwards
+		return nil
+	}
+
+	if t.pending != nil && bucket != t.bucket {
+		points := make(map[string]aggPoint, len(t.pending))
+		for name, p := range t.pending {
+			points[name] = *p
+		}
+		if err := s.write(t, t.bucket, points); err != nil {
+			return err
+		}
+		t.last, t.pending = t.bucket, nil
+	}
+	if t.pending == nil {
+		t.bucket, t.pending = bucket, make(map[string]*aggPoint)
+	}
+	for name, v := range values {
+		p, ok := t.pending[name]
+		if !ok {
+			p = &aggPoint{Time: bucket}
+			t.pending[name] = p
+		}
+		p.merge(sample(ts, v))
+	}
+	return nil
+}
+
+// write appends to the tier's current segment, starting a new one and
+// compacting the tier when ts is past the current window
+func (s *DiskStore) write(t *tier, ts int64, points map[string]aggPoint) error {
+	width := t.window.Milliseconds()
+	start := ts - ts%width
+	if t.active == nil || start > t.active.start {
+		if t.active != nil {
+			t.active.close()
+		}
+		seg, err := createSegment(t.dir, start, t.rollup())
+		if err != nil {
+			t.active = nil
+			return err
+		}
+		t.active = seg
+		s.compactTier(t, ts)
+	}
+	return t.active.append(ts, points)
+}
+
+// compact deletes the segments of every tier that are past retention
+func (s *DiskStore) compact(now int64) {
+	for _, t := range s.tiers {
+		s.compactTier(t, now)
+	}
+}
+
+func (s *DiskStore) compactTier(t *tier, now int64) {
+	starts, err := listSegments(t.dir)
+	if err != nil {
+		return
+	}
+	cutoff := now - t.retention.Milliseconds()
+	removed := false
+	for _, start := range starts {
+		if t.active != nil && start == t.active.start {
+			continue
+		}
+		if start+t.window.Milliseconds() <= cutoff {
+			delete(t.sealed, start)
+			if os.Remove(segmentPath(t.dir, start)) == nil {
+				removed = true
+			}
+		}
+	}
+	if removed {
+		syncDir(t.dir)
+	}
+}
+
+// read returns the points of the matching metrics within [from, to]
+func (s *DiskStore) read(t *tier, from, to int64, match func(string) bool) (map[string][]aggPoint, error) {
+	starts, err := listSegments(t.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]aggPoint)
+	width := t.window.Milliseconds()
+	for _, start := range starts {
+		if start > to || start+width <= from {
+			continue
+		}
+		seg := t.active
+		if seg == nil || seg.start != start {
+			if seg, err = s.readSealed(t, start); err != nil {
+				return nil, err
+			}
+		}
+		for name, points := range seg.points {
+			if match != nil && !match(name) {
+				continue
+			}
+			for _, p := range points {
+				if p.Time >= from && p.Time <= to {
+					result[name] = append(result[name], p)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// readSealed returns a decoded segment other than the active one, from the
+// cache when a recent query already read it
+func (s *DiskStore) readSealed(t *tier, start int64) (*segment, error) {
+	c, ok := t.sealed[start]
+	if !ok {
+		seg, _, err := readSegment(t.dir, start, t.rollup())
+		if err != nil {
+			return nil, err
+		}
+		c = &cachedSegment{segment: seg}
+		t.sealed[start] = c
+	}
+	c.used = s.now()
+	return c.segment, nil
+}
+
+// evictSealed drops cached segments no query read for sealedCacheTTL
+func (s *DiskStore) evictSealed() {
+	cutoff := s.now().Add(-sealedCacheTTL)
+	for _, t := range s.tiers {
+		for start, c := range t.sealed {
+			if c.used.Before(cutoff) {
+				delete(t.sealed, start)
+			}
+		}
+	}
+}
+
+// pickTier chooses the finest tier whose retention covers the start of the
+// query, or a coarser one when the step is at least its resolution
+func (s *DiskStore) pickTier(q Query) *tier {
+	now := s.now()
+	i := 0
+	for i < len(s.tiers)-1 && q.Since.Before(now.Add(-s.tiers[i].retention)) {
+		i++
+	}
+	for i < len(s.tiers)-1 && q.Step >= s.tiers[i+1].resolution {
+		i++
+	}
+	return s.tiers[i]
+}
+
+func (s *DiskStore) Query(q Query) ([]Series, error) {
+	from, to := q.Since.UnixMilli(), q.Until.UnixMilli()
+	match := func(name string) bool { return matchMetric(q.Metrics, name) }
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.pickTier(q)
+	points, err := s.read(t, from, to, match)
+	if err != nil {
+		return nil, err
+	}
+	// Include the bucket still in progress so rollups reach up to now
+	if t.pending != nil && t.bucket >= from && t.bucket <= to {
+		for name, p := range t.pending {
+			if match(name) {
+				points[name] = append(points[name], *p)
+			}
+		}
+	}
+
+	result := make([]Series, 0, len(points))
+	for name, pts := range points {
+		sort.Slice(pts, func(i, j int) bool { return pts[i].Time < pts[j].Time })
+		result = append(result, Series{Metric: name, Points: downsample(pts, q.Step, q.Aggregate)})
+	}
+	sortSeries(result)
+	return result, nil
+}
+
+func (s *DiskStore) Metrics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.known))
+	for name := range s.known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes the open segments. Rollups still in progress are not
+// written; they are rebuilt from the raw samples when the store is reopened.
+func (s *DiskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for _, t := range s.tiers {
+		if t.active != nil {
+			if err := t.active.close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,201 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// diskBase is aligned to a day so every tier's windows start on it
+var diskBase = time.Unix(1700006400, 0)
+
+// longRetention keeps fixture data from being compacted against the wall clock
+var longRetention = DiskOptions{Retention: 1e6 * time.Hour, Retention1m: 1e6 * time.Hour, Retention1h: 1e6 * time.Hour}
+
+func openTestStore(t *testing.T, dir string, opts DiskOptions) *DiskStore {
+	t.Helper()
+	s, err := OpenDiskStore(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func queryValues(t *testing.T, s Store, q Query) []float64 {
+	t.Helper()
+	series, err := s.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("got %d series, want 1: %+v", len(series), series)
+	}
+	var values []float64
+	for _, p := range series[0].Points {
+		values = append(values, p.Value)
+	}
+	return values
+}
+
+func TestDiskStorePersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, longRetention)
+	for i := 0; i < 5; i++ {
+		if err := s.Append(diskBase.Add(time.Duration(i)*10*time.Second), map[string]float64{"load.1": float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	s = openTestStore(t, dir, longRetention)
+	s.now = func() time.Time { return diskBase.Add(time.Minute) }
+	q := Query{Metrics: []string{"load.*"}, Since: diskBase, Until: diskBase.Add(time.Minute)}
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{0, 1, 2, 3, 4}) {
+		t.Errorf("after reopen got %v", got)
+	}
+	if got := s.Metrics(); !reflect.DeepEqual(got, []string{"load.1"}) {
+		t.Errorf("Metrics() = %v", got)
+	}
+}
+
+func TestDiskStoreRollups(t *testing.T) {
+	s := openTestStore(t, t.TempDir(), longRetention)
+	// Two minutes of samples every 20s, then one to close the second minute
+	values := []float64{1, 5, 3, 10, 20, 30, 0}
+	for i, v := range values {
+		s.Append(diskBase.Add(time.Duration(i)*20*time.Second), map[string]float64{"cpu.usage_percent": v})
+	}
+	s.now = func() time.Time { return diskBase.Add(3 * time.Minute) }
+
+	q := Query{Metrics: []string{"cpu.usage_percent"}, Since: diskBase, Until: diskBase.Add(time.Hour), Step: time.Minute}
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{3, 20, 0}) {
+		t.Errorf("1m avg = %v, want [3 20 0]", got)
+	}
+	q.Aggregate = AggregateMax
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{5, 30, 0}) {
+		t.Errorf("1m max = %v, want [5 30 0]", got)
+	}
+	q.Aggregate, q.Step = AggregateMin, time.Hour
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{0}) {
+		t.Errorf("1h min = %v, want [0]", got)
+	}
+}
+
+func TestDiskStoreRecoversTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, longRetention)
+	s.Append(diskBase, map[string]float64{"load.1": 1})
+	s.Append(diskBase.Add(10*time.Second), map[string]float64{"load.1": 2})
+	s.Close()
+
+	// Simulate a crash halfway through writing a record
+	path := segmentPath(filepath.Join(dir, "raw"), diskBase.UnixMilli())
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{40, 0, 0, 0, 1, 2, 3, 4, 2, 9})
+	f.Close()
+
+	s = openTestStore(t, dir, longRetention)
+	s.now = func() time.Time { return diskBase.Add(time.Minute) }
+	if err := s.Append(diskBase.Add(20*time.Second), map[string]float64{"load.1": 3}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openTestStore(t, dir, longRetention)
+	s.now = func() time.Time { return diskBase.Add(time.Minute) }
+	q := Query{Metrics: []string{"load.1"}, Since: diskBase, Until: diskBase.Add(time.Minute)}
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{1, 2, 3}) {
+		t.Errorf("got %v, want the torn record dropped and later appends kept", got)
+	}
+}
+
+func TestDiskStoreRebuildsRollupsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, longRetention)
+	s.Append(diskBase, map[string]float64{"load.1": 2})
+	s.Append(diskBase.Add(30*time.Second), map[string]float64{"load.1": 4})
+	// Restart in the middle of the minute, before its rollup is written
+	s.Close()
+
+	s = openTestStore(t, dir, longRetention)
+	s.Append(diskBase.Add(50*time.Second), map[string]float64{"load.1": 6})
+	s.Append(diskBase.Add(70*time.Second), map[string]float64{"load.1": 0})
+	s.now = func() time.Time { return diskBase.Add(2 * time.Minute) }
+
+	q := Query{Metrics: []string{"load.1"}, Since: diskBase, Until: diskBase.Add(time.Hour), Step: time.Minute}
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{4, 0}) {
+		t.Errorf("1m rollups = %v, want [4 0] including samples from before the restart", got)
+	}
+}
+
+func TestDiskStoreCompactsExpiredSegments(t *testing.T) {
+	dir := t.TempDir()
+	opts := longRetention
+	opts.Retention = time.Hour
+	s := openTestStore(t, dir, opts)
+	s.Append(diskBase, map[string]float64{"load.1": 1})
+	s.Append(diskBase.Add(3*time.Hour), map[string]float64{"load.1": 2})
+
+	starts, err := listSegments(filepath.Join(dir, "raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{diskBase.Add(3 * time.Hour).UnixMilli()}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("raw segments = %v, want only %v", starts, want)
+	}
+
+	// The expired raw data is still available as a rollup
+	s.now = func() time.Time { return diskBase.Add(3 * time.Hour) }
+	q := Query{Metrics: []string{"load.1"}, Since: diskBase, Until: diskBase.Add(4 * time.Hour)}
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{1, 2}) {
+		t.Errorf("query past raw retention = %v, want the 1m rollups [1 2]", got)
+	}
+}
+
+func TestDiskStoreCachesSealedSegments(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, longRetention)
+	s.Append(diskBase, map[string]float64{"load.1": 1})
+	s.Append(diskBase.Add(time.Hour), map[string]float64{"load.1": 2})
+	s.now = func() time.Time { return diskBase.Add(time.Hour) }
+	q := Query{Metrics: []string{"load.1"}, Since: diskBase, Until: diskBase.Add(time.Hour)}
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{1, 2}) {
+		t.Fatalf("got %v", got)
+	}
+
+	// Later queries reuse the decoded sealed segment instead of the file
+	path := segmentPath(filepath.Join(dir, "raw"), diskBase.UnixMilli())
+	if err := os.WriteFile(path, []byte(segmentMagic), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{1, 2}) {
+		t.Errorf("cached query got %v", got)
+	}
+
+	// until it goes unread for sealedCacheTTL
+	s.now = func() time.Time { return diskBase.Add(time.Hour + sealedCacheTTL + time.Second) }
+	s.Append(diskBase.Add(time.Hour+10*time.Second), map[string]float64{"load.1": 3})
+	if got := queryValues(t, s, q); !reflect.DeepEqual(got, []float64{2}) {
+		t.Errorf("after eviction got %v, want the rewritten file read again", got)
+	}
+}
+
+func TestDiskStoreForgetsExpiredMetrics(t *testing.T) {
+	opts := DiskOptions{Retention: time.Hour, Retention1m: time.Hour, Retention1h: 2 * time.Hour}
+	s := openTestStore(t, t.TempDir(), opts)
+	s.Append(diskBase, map[string]float64{"disk.used_percent:/mnt": 10, "load.1": 1})
+	s.Append(diskBase.Add(time.Hour), map[string]float64{"load.1": 2})
+	if got := s.Metrics(); !reflect.DeepEqual(got, []string{"disk.used_percent:/mnt", "load.1"}) {
+		t.Errorf("Metrics() within retention = %v", got)
+	}
+	s.Append(diskBase.Add(3*time.Hour), map[string]float64{"load.1": 3})
+	if got := s.Metrics(); !reflect.DeepEqual(got, []string{"load.1"}) {
+		t.Errorf("Metrics() = %v, want the expired disk forgotten", got)
+	}
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func TestMemoryStoreWrapsAround(t *testing.T) {
+	store := NewMemoryStore(5*time.Second, time.Second)
+	base := time.Unix(1000, 0)
+	for i := 0; i < 8; i++ {
+		store.Append(base.Add(time.Duration(i)*time.Second), map[string]float64{"load.1": float64(i)})
+	}
+
+	series, err := store.Query(Query{Metrics: []string{"load.1"}, Since: base, Until: base.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("got %d series, want 1", len(series))
+	}
+	var values []float64
+	for _, p := range series[0].Points {
+		values = append(values, p.Value)
+	}
+	if want := []float64{3, 4, 5, 6, 7}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %v, want the newest %v", values, want)
+	}
+}
+
+func TestMemoryStoreQuery(t *testing.T) {
+	store := NewMemoryStore(time.Hour, time.Second)
+	base := time.Unix(1200, 0)
+	for i := 0; i < 6; i++ {
+		store.Append(base.Add(time.Duration(i)*10*time.Second), map[string]float64{
+			"cpu.usage_percent":   float64(i),
+			"cpu.usage_percent:0": float64(i * 2),
+			"memory.used_percent": 50,
+		})
+	}
+
+	series, err := store.Query(Query{
+		Metrics: []string{"cpu.usage_percent:*"},
+		Since:   base.Add(10 * time.Second),
+		Until:   base.Add(40 * time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Metric != "cpu.usage_percent:0" {
+		t.Fatalf("prefix query returned %+v", series)
+	}
+	if n := len(series[0].Points); n != 4 {
+		t.Errorf("got %d points in range, want 4", n)
+	}
+
+	series, _ = store.Query(Query{
+		Metrics: []string{"cpu.usage_percent"},
+		Since:   base,
+		Until:   base.Add(time.Minute),
+		Step:    30 * time.Second,
+	})
+	want := []Point{{Time: 1200000, Value: 1}, {Time: 1230000, Value: 4}}
+	if len(series) != 1 || !reflect.DeepEqual(series[0].Points, want) {
+		t.Errorf("downsampled to %+v, want %+v", series, want)
+	}
+
+	if got := store.Metrics(); !reflect.DeepEqual(got, []string{"cpu.usage_percent", "cpu.usage_percent:0", "memory.used_percent"}) {
+		t.Errorf("Metrics() = %v", got)
+	}
+}
+
+func TestMemoryStoreForgetsStaleSeries(t *testing.T) {
+	store := NewMemoryStore(time.Minute, time.Second)
+	base := time.Unix(0, 0)
+	store.Append(base, map[string]float64{"disk.used_percent:/mnt": 10})
+	store.Append(base.Add(2*time.Minute), map[string]float64{"load.1": 1})
+
+	if got := store.Metrics(); !reflect.DeepEqual(got, []string{"load.1"}) {
+		t.Errorf("Metrics() = %v, want the unmounted disk dropped", got)
+	}
+}
+
+func TestPointJSON(t *testing.T) {
+	data, err := json.Marshal(Point{Time: 1500, Value: 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[1500,2.5]" {
+		t.Errorf("encoded as %s", data)
+	}
+	var p Point
+	if err := json.Unmarshal(data, &p); err != nil || p != (Point{Time: 1500, Value: 2.5}) {
+		t.Errorf("decoded %+v, %v", p, err)
+	}
+}
+
+func TestSamples(t *testing.T) {
+	info := &sysinfo.SystemInfo{
+		Collectors:   []string{sysinfo.SectionHost, sysinfo.SectionCPU, sysinfo.SectionDisk, sysinfo.SectionMemory},
+		ProcessCount: 123,
+		CPU:          sysinfo.CPUInfo{Usage: []float64{10, 30}},
+		Disk:         []sysinfo.DiskInfo{{Mountpoint: "/", UsedPercent: 42}},
+		Errors:       []sysinfo.SectionError{{Section: sysinfo.SectionMemory, Message: "boom"}},
+	}
+	rates := &sysinfo.Rates{Network: true, NetRecv: 100}
+
+	values := Samples(info, rates)
+	want := map[string]float64{
+		"processes.count":        123,
+		"cpu.usage_percent":      20,
+		"cpu.usage_percent:0":    10,
+		"cpu.usage_percent:1":    30,
+		"disk.used_percent:/":    42,
+		"disk.used_bytes:/":      0,
+		"net.recv_bytes_per_sec": 100,
+		"net.sent_bytes_per_sec": 0,
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Samples() = %v, want %v", values, want)
+	}
+}
+
+func TestListenerInterval(t *testing.T) {
+	// Sampled every 10s for the store, a 15s listener takes the first
+	// sample at or after each multiple of 15s
+	l := &listener{interval: 15 * time.Second}
+	var got []int
+	for sec := 0; sec <= 60; sec += 10 {
+		if l.due(time.Unix(int64(1699999980+sec), 1000)) {
+			got = append(got, sec)
+		}
+	}
+	if want := []int{0, 20, 30, 50, 60}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listener took samples at %v, want %v", got, want)
+	}
+}
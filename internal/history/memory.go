@@ -0,0 +1,119 @@
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ring is a fixed-size buffer of points that overwrites the oldest
+type ring struct {
+	points []Point
+	start  int
+	size   int
+}
+
+func (r *ring) add(p Point) {
+	if r.size < len(r.points) {
+		r.points[(r.start+r.size)%len(r.points)] = p
+		r.size++
+		return
+	}
+	r.points[r.start] = p
+	r.start = (r.start + 1) % len(r.points)
+}
+
+func (r *ring) at(i int) Point {
+	return r.points[(r.start+i)%len(r.points)]
+}
+
+func (r *ring) newest() Point {
+	return r.at(r.size - 1)
+}
+
+// between returns the points in [from, to] in time order
+func (r *ring) between(from, to int64) []aggPoint {
+	// Points are appended in time order, so the range is contiguous
+	first := sort.Search(r.size, func(i int) bool { return r.at(i).Time >= from })
+	var out []aggPoint
+	for i := first; i < r.size && r.at(i).Time <= to; i++ {
+		p := r.at(i)
+		out = append(out, sample(p.Time, p.Value))
+	}
+	return out
+}
+
+// MemoryStore keeps each metric in a bounded ring buffer sized to hold
+// retention worth of samples taken every resolution
+type MemoryStore struct {
+	retention time.Duration
+	capacity  int
+
+	mu     sync.RWMutex
+	series map[string]*ring
+}
+
+func NewMemoryStore(retention, resolution time.Duration) *MemoryStore {
+	capacity := 1
+	if resolution > 0 && retention > resolution {
+		capacity = int(retention / resolution)
+	}
+	return &MemoryStore{
+		retention: retention,
+		capacity:  capacity,
+		series:    make(map[string]*ring),
+	}
+}
+
+func (s *MemoryStore) Append(t time.Time, values map[string]float64) error {
+	ms := t.UnixMilli()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, v := range values {
+		r, ok := s.series[name]
+		if !ok {
+			r = &ring{points: make([]Point, s.capacity)}
+			s.series[name] = r
+		}
+		r.add(Point{Time: ms, Value: v})
+	}
+
+	// Forget series that stopped reporting, such as an unmounted disk
+	cutoff := t.Add(-s.retention).UnixMilli()
+	for name, r := range s.series {
+		if r.newest().Time < cutoff {
+			delete(s.series, name)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Query(q Query) ([]Series, error) {
+	from, to := q.Since.UnixMilli(), q.Until.UnixMilli()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []Series
+	for name, r := range s.series {
+		if !matchMetric(q.Metrics, name) {
+			continue
+		}
+		if points := r.between(from, to); len(points) > 0 {
+			result = append(result, Series{Metric: name, Points: downsample(points, q.Step, q.Aggregate)})
+		}
+	}
+	sortSeries(result)
+	return result, nil
+}
+
+func (s *MemoryStore) Metrics() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,180 @@
+package history
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// DefaultResolution and DefaultRetention size the history kept by serve
+const (
+	DefaultResolution = 10 * time.Second
+	DefaultRetention  = 24 * time.Hour
+)
+
+// RecordedCollectors are the collectors a Recorder runs
+var RecordedCollectors = []string{
+	sysinfo.SectionHost, sysinfo.SectionCPU, sysinfo.SectionMemory, sysinfo.SectionSwap,
+	sysinfo.SectionLoad, sysinfo.SectionDisk, sysinfo.SectionNetwork, sysinfo.SectionIO,
+}
+
+// Listener receives a sample taken by a Recorder: the snapshot and its
+// Samples
+type Listener func(t time.Time, info *sysinfo.SystemInfo, values map[string]float64)
+
+// Recorder collects system information and appends it to Store every
+// Interval. Per-instance metrics carry the instance after a colon, for
+// example "cpu.usage_percent:3" or "disk.used_percent:/var". Alert rules,
+// anomaly detection and the like get the same samples through Listen
+// instead of collecting their own.
+type Recorder struct {
+	// Store keeps the samples; nil only feeds the listeners
+	Store    Store
+	Interval time.Duration
+	// Timeout bounds each collector, see sysinfo.Options
+	Timeout time.Duration
+	// Collectors run besides RecordedCollectors, for listeners that need
+	// other sections
+	Collectors []string
+
+	listeners []*listener
+}
+
+type listener struct {
+	interval time.Duration
+	next     time.Time
+	fn       Listener
+}
+
+// due reports whether the listener takes the sample at now. Listeners keep
+// to their own interval when samples are taken more often.
+func (l *listener) due(now time.Time) bool {
+	if now.Before(l.next) {
+		return false
+	}
+	l.next = now.Truncate(l.interval).Add(l.interval)
+	return true
+}
+
+// Listen has fn receive a sample every interval, DefaultResolution when
+// not positive. It must be called before Run.
+func (r *Recorder) Listen(interval time.Duration, fn Listener) {
+	if interval <= 0 {
+		interval = DefaultResolution
+	}
+	r.listeners = append(r.listeners, &listener{interval: interval, fn: fn})
+}
+
+// Enabled reports whether the recorder has a store or listeners to sample for
+func (r *Recorder) Enabled() bool {
+	return (r.Store != nil && r.Interval > 0) || len(r.listeners) > 0
+}
+
+// Run samples until ctx is cancelled, as often as the store or the most
+// frequent listener needs. Collections that fail are skipped.
+func (r *Recorder) Run(ctx context.Context) {
+	if !r.Enabled() {
+		return
+	}
+	store := &listener{interval: r.Interval, fn: func(t time.Time, _ *sysinfo.SystemInfo, values map[string]float64) {
+		if err := r.Store.Append(t, values); err != nil {
+			log.Printf("history: %v", err)
+		}
+	}}
+	listeners := r.listeners
+	if r.Store != nil && r.Interval > 0 {
+		listeners = append([]*listener{store}, listeners...)
+	}
+	interval := listeners[0].interval
+	for _, l := range listeners {
+		interval = min(interval, l.interval)
+	}
+	collectors := append(slices.Clone(RecordedCollectors), r.Collectors...)
+
+	var (
+		prev   *sysinfo.SystemInfo
+		prevAt time.Time
+	)
+	for {
+		info, err := sysinfo.GetSystemInfoContext(ctx, sysinfo.Options{Timeout: r.Timeout, Collectors: collectors})
+		now := time.Now()
+		if err == nil {
+			var rates *sysinfo.Rates
+			if prev != nil {
+				rr := sysinfo.NewRates(prev, info, now.Sub(prevAt))
+				rates = &rr
+			}
+			values := Samples(info, rates)
+			for _, l := range listeners {
+				if l.due(now) {
+					l.fn(now, info, values)
+				}
+			}
+			prev, prevAt = info, now
+		}
+
+		// Sample on multiples of the interval rather than on a ticker, so a
+		// slow collection doesn't cause a burst of catch-up samples
+		next := time.Now().Truncate(interval).Add(interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// Samples flattens the recorded sections of info into metric values. rates
+// are the I/O rates since the previous sample, if there was one.
+func Samples(info *sysinfo.SystemInfo, rates *sysinfo.Rates) map[string]float64 {
+	values := make(map[string]float64)
+	collected := func(section string) bool {
+		return info.Collected(section) && !info.SectionFailed(section)
+	}
+
+	if collected(sysinfo.SectionHost) {
+		values["processes.count"] = float64(info.ProcessCount)
+	}
+	if collected(sysinfo.SectionCPU) && len(info.CPU.Usage) > 0 {
+		var total float64
+		for i, usage := range info.CPU.Usage {
+			values["cpu.usage_percent:"+strconv.Itoa(i)] = usage
+			total += usage
+		}
+		values["cpu.usage_percent"] = total / float64(len(info.CPU.Usage))
+	}
+	if collected(sysinfo.SectionMemory) {
+		values["memory.used_percent"] = info.Memory.UsedPercent
+		values["memory.used_bytes"] = float64(info.Memory.Used)
+	}
+	if collected(sysinfo.SectionSwap) {
+		values["swap.used_percent"] = info.Swap.UsedPercent
+		values["swap.used_bytes"] = float64(info.Swap.Used)
+	}
+	if collected(sysinfo.SectionLoad) {
+		values["load.1"] = info.LoadAverage.Load1
+		values["load.5"] = info.LoadAverage.Load5
+		values["load.15"] = info.LoadAverage.Load15
+	}
+	if collected(sysinfo.SectionDisk) {
+		for _, disk := range info.Disk {
+			values["disk.used_percent:"+disk.Mountpoint] = disk.UsedPercent
+			values["disk.used_bytes:"+disk.Mountpoint] = float64(disk.Used)
+		}
+	}
+	if rates != nil && rates.Network {
+		values["net.recv_bytes_per_sec"] = rates.NetRecv
+		values["net.sent_bytes_per_sec"] = rates.NetSent
+	}
+	if rates != nil && rates.IO {
+		values["diskio.read_bytes_per_sec"] = rates.DiskRead
+		values["diskio.write_bytes_per_sec"] = rates.DiskWrite
+		values["diskio.read_iops"] = rates.DiskReadIOPS
+		values["diskio.write_iops"] = rates.DiskWriteIOPS
+	}
+	return values
+}
@@ -0,0 +1,369 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A segment file holds the samples of one tier for one time window. It
+// starts with segmentMagic followed by records, each framed as
+//
+//	uint32 payload length | uint32 CRC-32C of payload | payload
+//
+// Every append writes whole records and syncs, so after a crash a segment
+// can only end in one torn record, which is detected by the length or
+// checksum and cut off when the segment is reopened.
+const (
+	segmentMagic = "SYSTSDB1"
+	segmentExt   = ".seg"
+)
+
+// Record kinds, the first payload byte
+const (
+	// recordName assigns a segment-local id to a metric name
+	recordName = 1
+	// recordSample holds the values of several metrics at one time
+	recordSample = 2
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorrupt = errors.New("corrupt record")
+
+// segment is the decoded content of a segment file. The newest segment of
+// each tier stays open for appending.
+type segment struct {
+	path   string
+	start  int64 // window start, Unix milliseconds
+	rollup bool  // whether samples carry min, max and count
+
+	f      *os.File
+	size   int64 // length of the intact data in f
+	ids    map[string]uint64
+	points map[string][]aggPoint
+}
+
+// segmentPath names a segment after the start of its window in Unix seconds
+func segmentPath(dir string, start int64) string {
+	return filepath.Join(dir, strconv.FormatInt(start/1000, 10)+segmentExt)
+}
+
+// listSegments returns the window starts of the segments in dir, oldest first
+func listSegments(dir string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var starts []int64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		secs, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, secs*1000)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	return starts, nil
+}
+
+// createSegment starts a new, empty segment file
+func createSegment(dir string, start int64, rollup bool) (*segment, error) {
+	path := segmentPath(dir, start)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write([]byte(segmentMagic)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Make the new directory entry durable too
+	if err := syncDir(dir); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &segment{
+		path:   path,
+		start:  start,
+		rollup: rollup,
+		f:      f,
+		size:   int64(len(segmentMagic)),
+		ids:    make(map[string]uint64),
+		points: make(map[string][]aggPoint),
+	}, nil
+}
+
+// readSegment decodes a segment file. It stops at the first torn or corrupt
+// record and returns the length of the intact prefix.
+func readSegment(dir string, start int64, rollup bool) (*segment, int64, error) {
+	path := segmentPath(dir, start)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	seg := &segment{
+		path:   path,
+		start:  start,
+		rollup: rollup,
+		ids:    make(map[string]uint64),
+		points: make(map[string][]aggPoint),
+	}
+	if !bytes.HasPrefix(data, []byte(segmentMagic)) {
+		if len(data) < len(segmentMagic) && bytes.HasPrefix([]byte(segmentMagic), data) {
+			// Crashed while writing the header
+			return seg, 0, nil
+		}
+		return nil, 0, fmt.Errorf("%s: not a segment file", path)
+	}
+
+	names := make(map[uint64]string)
+	valid := int64(len(segmentMagic))
+	for rest := data[valid:]; len(rest) > 0; {
+		if len(rest) < 8 {
+			break
+		}
+		size := binary.LittleEndian.Uint32(rest)
+		sum := binary.LittleEndian.Uint32(rest[4:])
+		if uint64(len(rest)-8) < uint64(size) {
+			break
+		}
+		payload := rest[8 : 8+size]
+		if crc32.Checksum(payload, crcTable) != sum {
+			break
+		}
+		if err := seg.decode(payload, names); err != nil {
+			break
+		}
+		rest = rest[8+size:]
+		valid += int64(8 + size)
+	}
+	return seg, valid, nil
+}
+
+// openSegment reopens the newest segment of a tier for appending, cutting
+// off a torn record left by a crash
+func openSegment(dir string, start int64, rollup bool) (*segment, error) {
+	seg, valid, err := readSegment(dir, start, rollup)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if valid < int64(len(segmentMagic)) {
+		if _, err := f.WriteAt([]byte(segmentMagic), 0); err != nil {
+			f.Close()
+			return nil, err
+		}
+		valid = int64(len(segmentMagic))
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	seg.f, seg.size = f, valid
+	return seg, nil
+}
+
+func (s *segment) decode(payload []byte, names map[uint64]string) error {
+	r := bytes.NewReader(payload)
+	kind, err := r.ReadByte()
+	if err != nil {
+		return errCorrupt
+	}
+
+	switch kind {
+	case recordName:
+		id, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errCorrupt
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return errCorrupt
+		}
+		name := make([]byte, n)
+		io.ReadFull(r, name)
+		names[id] = string(name)
+		s.ids[string(name)] = id
+
+	case recordSample:
+		t, err := binary.ReadVarint(r)
+		if err != nil {
+			return errCorrupt
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errCorrupt
+		}
+		for i := uint64(0); i < n; i++ {
+			id, err := binary.ReadUvarint(r)
+			if err != nil {
+				return errCorrupt
+			}
+			name, ok := names[id]
+			if !ok {
+				return errCorrupt
+			}
+			p := aggPoint{Time: t, Count: 1}
+			if p.Avg, err = readFloat(r); err != nil {
+				return errCorrupt
+			}
+			p.Min, p.Max = p.Avg, p.Avg
+			if s.rollup {
+				if p.Min, err = readFloat(r); err != nil {
+					return errCorrupt
+				}
+				if p.Max, err = readFloat(r); err != nil {
+					return errCorrupt
+				}
+				if p.Count, err = binary.ReadUvarint(r); err != nil {
+					return errCorrupt
+				}
+			}
+			s.points[name] = append(s.points[name], p)
+		}
+
+	default:
+		return errCorrupt
+	}
+	return nil
+}
+
+// append writes the points sampled at t as one synced write
+func (s *segment) append(t int64, points map[string]aggPoint) error {
+	names := make([]string, 0, len(points))
+	for name := range points {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Names new to this segment are defined in the same write as the sample
+	var buf []byte
+	added := make(map[string]uint64)
+	id := func(name string) uint64 {
+		if id, ok := s.ids[name]; ok {
+			return id
+		}
+		return added[name]
+	}
+	for _, name := range names {
+		if _, ok := s.ids[name]; ok {
+			continue
+		}
+		added[name] = uint64(len(s.ids) + len(added))
+		payload := []byte{recordName}
+		payload = binary.AppendUvarint(payload, added[name])
+		payload = binary.AppendUvarint(payload, uint64(len(name)))
+		payload = append(payload, name...)
+		buf = appendRecord(buf, payload)
+	}
+
+	payload := []byte{recordSample}
+	payload = binary.AppendVarint(payload, t)
+	payload = binary.AppendUvarint(payload, uint64(len(names)))
+	for _, name := range names {
+		p := points[name]
+		payload = binary.AppendUvarint(payload, id(name))
+		payload = appendFloat(payload, p.Avg)
+		if s.rollup {
+			payload = appendFloat(payload, p.Min)
+			payload = appendFloat(payload, p.Max)
+			payload = binary.AppendUvarint(payload, p.Count)
+		}
+	}
+	buf = appendRecord(buf, payload)
+
+	if _, err := s.f.Write(buf); err != nil {
+		// Drop a partial write so later records don't land behind garbage
+		s.rewind()
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		// The records aren't in the in-memory view either, and later ones
+		// must not land behind them
+		s.rewind()
+		return err
+	}
+	s.size += int64(len(buf))
+
+	// Only update the in-memory view once the data is durable
+	for name, id := range added {
+		s.ids[name] = id
+	}
+	for _, name := range names {
+		p := points[name]
+		p.Time = t
+		s.points[name] = append(s.points[name], p)
+	}
+	return nil
+}
+
+// rewind cuts the file back to its intact data
+func (s *segment) rewind() {
+	s.f.Truncate(s.size)
+	s.f.Seek(s.size, io.SeekStart)
+}
+
+func (s *segment) close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func appendRecord(buf, payload []byte) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(payload, crcTable))
+	return append(buf, payload...)
+}
+
+func appendFloat(buf []byte, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+}
+
+func readFloat(r *bytes.Reader) (float64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+}
+
+// syncDir flushes a directory so created or removed entries survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
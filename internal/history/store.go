@@ -0,0 +1,168 @@
+// Package history records system metrics over time and answers range
+// queries for the web dashboard and the /api/history endpoint.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Point is one sample of a series. It encodes as [unix_ms, value] to keep
+// long ranges compact.
+type Point struct {
+	Time  int64 // Unix milliseconds
+	Value float64
+}
+
+func (p Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]interface{}{p.Time, p.Value})
+}
+
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var pair [2]float64
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	p.Time, p.Value = int64(pair[0]), pair[1]
+	return nil
+}
+
+// Series is the points of one metric in time order
+type Series struct {
+	Metric string  `json:"metric"`
+	Points []Point `json:"points"`
+}
+
+// Aggregates a query can ask for when points are combined
+const (
+	AggregateAvg = "avg"
+	AggregateMin = "min"
+	AggregateMax = "max"
+)
+
+// Query selects a time range of one or more metrics
+type Query struct {
+	// Metrics are exact names, or prefixes ending in "*" such as "cpu.usage_percent:*"
+	Metrics []string
+	Since   time.Time
+	Until   time.Time
+	// Step combines points into buckets of this width; zero returns them
+	// at the resolution they are stored at
+	Step time.Duration
+	// Aggregate picks how points are combined, AggregateAvg when empty
+	Aggregate string
+}
+
+// Validate checks the parts of a query that come from user input
+func (q Query) Validate() error {
+	switch q.Aggregate {
+	case "", AggregateAvg, AggregateMin, AggregateMax:
+	default:
+		return fmt.Errorf("unknown aggregate %q (supported: avg, min, max)", q.Aggregate)
+	}
+	if q.Step < 0 {
+		return fmt.Errorf("step must not be negative")
+	}
+	return nil
+}
+
+// Store keeps samples of named metrics
+type Store interface {
+	// Append records the values of every metric sampled at t
+	Append(t time.Time, values map[string]float64) error
+	// Query returns the matching series that have points in the range,
+	// sorted by metric name
+	Query(q Query) ([]Series, error)
+	// Metrics lists the names of every stored metric
+	Metrics() []string
+}
+
+// matchMetric reports whether name is selected by any of the patterns
+func matchMetric(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// aggPoint is a single sample (Count 1) or the rollup of several samples
+// in the bucket starting at Time
+type aggPoint struct {
+	Time  int64
+	Avg   float64
+	Min   float64
+	Max   float64
+	Count uint64
+}
+
+func sample(t int64, v float64) aggPoint {
+	return aggPoint{Time: t, Avg: v, Min: v, Max: v, Count: 1}
+}
+
+// merge folds b into a, weighting the averages by their sample counts
+func (a *aggPoint) merge(b aggPoint) {
+	if a.Count == 0 {
+		t := a.Time
+		*a = b
+		a.Time = t
+		return
+	}
+	total := a.Count + b.Count
+	a.Avg = (a.Avg*float64(a.Count) + b.Avg*float64(b.Count)) / float64(total)
+	a.Min = math.Min(a.Min, b.Min)
+	a.Max = math.Max(a.Max, b.Max)
+	a.Count = total
+}
+
+func (a aggPoint) value(aggregate string) float64 {
+	switch aggregate {
+	case AggregateMin:
+		return a.Min
+	case AggregateMax:
+		return a.Max
+	}
+	return a.Avg
+}
+
+// downsample combines time-ordered points into step-aligned buckets, each
+// reported at the start of its bucket
+func downsample(points []aggPoint, step time.Duration, aggregate string) []Point {
+	width := step.Milliseconds()
+	if width <= 0 {
+		out := make([]Point, len(points))
+		for i, p := range points {
+			out[i] = Point{Time: p.Time, Value: p.value(aggregate)}
+		}
+		return out
+	}
+
+	var out []Point
+	var bucket aggPoint
+	for _, p := range points {
+		start := p.Time - p.Time%width
+		if bucket.Count > 0 && start != bucket.Time {
+			out = append(out, Point{Time: bucket.Time, Value: bucket.value(aggregate)})
+			bucket = aggPoint{}
+		}
+		bucket.Time = start
+		bucket.merge(p)
+	}
+	if bucket.Count > 0 {
+		out = append(out, Point{Time: bucket.Time, Value: bucket.value(aggregate)})
+	}
+	return out
+}
+
+func sortSeries(series []Series) {
+	sort.Slice(series, func(i, j int) bool { return series[i].Metric < series[j].Metric })
+}
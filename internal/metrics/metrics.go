@@ -0,0 +1,300 @@
+// Package metrics renders SystemInfo snapshots in the Prometheus text
+// exposition format and its OpenMetrics successor.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// Content types for the two supported exposition formats
+const (
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+const namespace = "sysinfo_"
+
+type metricType string
+
+const (
+	gauge   metricType = "gauge"
+	counter metricType = "counter"
+	// info is a constant 1 carrying metadata labels. The text format has no
+	// such type and exposes it as a gauge.
+	infoType metricType = "info"
+)
+
+type label struct {
+	name, value string
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+// family is one metric with its metadata and samples. Counter and info
+// names are stored without their _total/_info suffix, which is added when
+// samples are written.
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	samples []sample
+}
+
+// registry collects families in the order they are first defined
+type registry struct {
+	families []*family
+	index    map[string]*family
+}
+
+func newRegistry() *registry {
+	return &registry{index: make(map[string]*family)}
+}
+
+func (r *registry) add(name, help string, typ metricType, value float64, labels ...label) {
+	f, ok := r.index[name]
+	if !ok {
+		f = &family{name: namespace + name, help: help, typ: typ}
+		r.index[name] = f
+		r.families = append(r.families, f)
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+func l(name, value string) label {
+	return label{name, value}
+}
+
+// Write renders info and ports in the Prometheus text format, or in
+// OpenMetrics when openMetrics is set. Either argument may be nil.
+func Write(w io.Writer, info *sysinfo.SystemInfo, ports []sysinfo.PortInfo, openMetrics bool) error {
+	r := newRegistry()
+	if info != nil {
+		addSystemInfo(r, info)
+	}
+	if ports != nil {
+		addPorts(r, ports)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, f := range r.families {
+		writeFamily(bw, f, openMetrics)
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+func addSystemInfo(r *registry, info *sysinfo.SystemInfo) {
+	if info.Collected(sysinfo.SectionHost) && !info.SectionFailed(sysinfo.SectionHost) {
+		r.add("host", "Host metadata.", infoType, 1,
+			l("hostname", info.Hostname), l("os", info.OS),
+			l("kernel", info.KernelVersion), l("arch", info.Architecture))
+		r.add("processes", "Number of processes.", gauge, float64(info.ProcessCount))
+	}
+
+	if info.Collected(sysinfo.SectionCPU) && !info.SectionFailed(sysinfo.SectionCPU) {
+		for i, usage := range info.CPU.Usage {
+			r.add("cpu_usage_percent", "CPU usage per logical core in percent.", gauge, usage, l("cpu", strconv.Itoa(i)))
+		}
+		r.add("cpu_logical_cores", "Number of logical CPU cores.", gauge, float64(info.CPU.LogicalCores))
+		r.add("cpu_physical_cores", "Number of physical CPU cores.", gauge, float64(info.CPU.Cores))
+		r.add("cpu_frequency_mhz", "CPU frequency in MHz.", gauge, info.CPU.Frequency)
+	}
+
+	if info.Collected(sysinfo.SectionMemory) && !info.SectionFailed(sysinfo.SectionMemory) {
+		m := info.Memory
+		r.add("memory_total_bytes", "Total physical memory in bytes.", gauge, float64(m.Total))
+		r.add("memory_used_bytes", "Used physical memory in bytes.", gauge, float64(m.Used))
+		r.add("memory_available_bytes", "Memory available for new allocations in bytes.", gauge, float64(m.Available))
+		r.add("memory_free_bytes", "Free physical memory in bytes.", gauge, float64(m.Free))
+		r.add("memory_cached_bytes", "Page cache memory in bytes.", gauge, float64(m.Cached))
+		r.add("memory_buffers_bytes", "Buffer memory in bytes.", gauge, float64(m.Buffers))
+		r.add("memory_shared_bytes", "Shared memory in bytes.", gauge, float64(m.Shared))
+		r.add("memory_active_bytes", "Active memory in bytes.", gauge, float64(m.Active))
+		r.add("memory_inactive_bytes", "Inactive memory in bytes.", gauge, float64(m.Inactive))
+		r.add("memory_used_percent", "Used physical memory in percent.", gauge, m.UsedPercent)
+	}
+
+	if info.Collected(sysinfo.SectionSwap) && !info.SectionFailed(sysinfo.SectionSwap) {
+		r.add("swap_total_bytes", "Total swap space in bytes.", gauge, float64(info.Swap.Total))
+		r.add("swap_used_bytes", "Used swap space in bytes.", gauge, float64(info.Swap.Used))
+		r.add("swap_free_bytes", "Free swap space in bytes.", gauge, float64(info.Swap.Free))
+		r.add("swap_used_percent", "Used swap space in percent.", gauge, info.Swap.UsedPercent)
+	}
+
+	if info.Collected(sysinfo.SectionLoad) && !info.SectionFailed(sysinfo.SectionLoad) {
+		r.add("load1", "1 minute load average.", gauge, info.LoadAverage.Load1)
+		r.add("load5", "5 minute load average.", gauge, info.LoadAverage.Load5)
+		r.add("load15", "15 minute load average.", gauge, info.LoadAverage.Load15)
+	}
+
+	for _, d := range info.Disk {
+		labels := []label{l("device", d.Device), l("mountpoint", d.Mountpoint), l("fstype", d.Fstype)}
+		r.add("disk_total_bytes", "Filesystem size in bytes.", gauge, float64(d.Total), labels...)
+		r.add("disk_used_bytes", "Filesystem space used in bytes.", gauge, float64(d.Used), labels...)
+		r.add("disk_free_bytes", "Filesystem space free in bytes.", gauge, float64(d.Free), labels...)
+		r.add("disk_used_percent", "Filesystem space used in percent.", gauge, d.UsedPercent, labels...)
+		if d.InodesTotal > 0 {
+			r.add("disk_inodes", "Filesystem inode count.", gauge, float64(d.InodesTotal), labels...)
+			r.add("disk_inodes_used", "Filesystem inodes in use.", gauge, float64(d.InodesUsed), labels...)
+			r.add("disk_inodes_free", "Filesystem inodes free.", gauge, float64(d.InodesFree), labels...)
+		}
+	}
+
+	if info.Collected(sysinfo.SectionNetwork) && !info.SectionFailed(sysinfo.SectionNetwork) {
+		n := info.Network
+		r.add("network_bytes", "Network bytes transferred across all interfaces.", counter, float64(n.BytesSent), l("direction", "sent"))
+		r.add("network_bytes", "", counter, float64(n.BytesRecv), l("direction", "received"))
+		r.add("network_packets", "Network packets transferred across all interfaces.", counter, float64(n.PacketsSent), l("direction", "sent"))
+		r.add("network_packets", "", counter, float64(n.PacketsRecv), l("direction", "received"))
+		r.add("network_errors", "Network errors across all interfaces.", counter, float64(n.ErrorsIn), l("direction", "in"))
+		r.add("network_errors", "", counter, float64(n.ErrorsOut), l("direction", "out"))
+		r.add("network_drops", "Dropped network packets across all interfaces.", counter, float64(n.DropsIn), l("direction", "in"))
+		r.add("network_drops", "", counter, float64(n.DropsOut), l("direction", "out"))
+	}
+
+	if info.Collected(sysinfo.SectionIO) && !info.SectionFailed(sysinfo.SectionIO) {
+		stats := info.IOStats
+		r.add("disk_io_bytes", "Bytes transferred by block devices.", counter, float64(stats.DiskReadBytes), l("op", "read"))
+		r.add("disk_io_bytes", "", counter, float64(stats.DiskWriteBytes), l("op", "write"))
+		r.add("disk_io_operations", "Operations completed by block devices.", counter, float64(stats.DiskReadCount), l("op", "read"))
+		r.add("disk_io_operations", "", counter, float64(stats.DiskWriteCount), l("op", "write"))
+		r.add("disk_io_time_seconds", "Time spent on block device operations in seconds.", counter, float64(stats.DiskReadTime)/1000, l("op", "read"))
+		r.add("disk_io_time_seconds", "", counter, float64(stats.DiskWriteTime)/1000, l("op", "write"))
+	}
+
+	if info.Collected(sysinfo.SectionTemperature) {
+		if info.Temperature.CPUTemp > 0 {
+			r.add("cpu_temperature_celsius", "CPU package temperature in degrees Celsius.", gauge, info.Temperature.CPUTemp)
+		}
+		for _, s := range info.Temperature.Sensors {
+			r.add("sensor_temperature_celsius", "Hardware sensor temperature in degrees Celsius.", gauge, s.Temperature, l("device", s.Device), l("sensor", s.Name))
+			if s.High > 0 {
+				r.add("sensor_high_celsius", "Hardware sensor high threshold in degrees Celsius.", gauge, s.High, l("device", s.Device), l("sensor", s.Name))
+			}
+			if s.Critical > 0 {
+				r.add("sensor_critical_celsius", "Hardware sensor critical threshold in degrees Celsius.", gauge, s.Critical, l("device", s.Device), l("sensor", s.Name))
+			}
+		}
+		zones := make([]string, 0, len(info.Temperature.ThermalZone))
+		for zone := range info.Temperature.ThermalZone {
+			zones = append(zones, zone)
+		}
+		sort.Strings(zones)
+		for _, zone := range zones {
+			r.add("thermal_zone_celsius", "Thermal zone temperature in degrees Celsius.", gauge, info.Temperature.ThermalZone[zone], l("zone", zone))
+		}
+	}
+
+	if info.Collected(sysinfo.SectionUsers) && !info.SectionFailed(sysinfo.SectionUsers) {
+		r.add("users", "Number of logged in user sessions.", gauge, float64(len(info.Users)))
+	}
+
+	for _, name := range info.Collectors {
+		success, timedOut := 1.0, 0.0
+		for _, e := range info.Errors {
+			if e.Section == name {
+				success = 0
+				if e.TimedOut {
+					timedOut = 1
+				}
+			}
+		}
+		r.add("collector_success", "Whether the collector succeeded.", gauge, success, l("collector", name))
+		r.add("collector_timed_out", "Whether the collector was abandoned after its deadline.", gauge, timedOut, l("collector", name))
+	}
+}
+
+func addPorts(r *registry, ports []sysinfo.PortInfo) {
+	counts := make(map[string]int)
+	// SO_REUSEPORT sockets, or listeners whose process isn't visible, can
+	// share all labels; Prometheus rejects a scrape with duplicate series
+	seen := make(map[[5]string]bool)
+	for _, p := range ports {
+		counts[p.Protocol]++
+		key := [5]string{p.Port, p.Protocol, p.Address, p.Process, strconv.Itoa(int(p.PID))}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		r.add("listening_port", "Listening socket, always 1.", gauge, 1,
+			l("port", key[0]), l("protocol", key[1]), l("address", key[2]),
+			l("process", key[3]), l("pid", key[4]))
+	}
+
+	protocols := make([]string, 0, len(counts))
+	for protocol := range counts {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	for _, protocol := range protocols {
+		r.add("listening_ports", "Number of listening sockets per protocol.", gauge, float64(counts[protocol]), l("protocol", protocol))
+	}
+}
+
+func writeFamily(w *bufio.Writer, f *family, openMetrics bool) {
+	sampleName, typeName, typ := f.name, f.name, f.typ
+	switch f.typ {
+	case counter:
+		sampleName += "_total"
+	case infoType:
+		sampleName += "_info"
+	}
+	if !openMetrics {
+		// The text format names every family by its sample name
+		typeName = sampleName
+		if typ == infoType {
+			typ = gauge
+		}
+	}
+
+	w.WriteString("# HELP " + typeName + " " + escapeHelp(f.help) + "\n")
+	w.WriteString("# TYPE " + typeName + " " + string(typ) + "\n")
+	for _, s := range f.samples {
+		w.WriteString(sampleName)
+		if len(s.labels) > 0 {
+			w.WriteByte('{')
+			for i, lb := range s.labels {
+				if i > 0 {
+					w.WriteByte(',')
+				}
+				w.WriteString(lb.name + `="` + escapeLabel(lb.value) + `"`)
+			}
+			w.WriteByte('}')
+		}
+		w.WriteByte(' ')
+		w.WriteString(formatValue(s.value))
+		w.WriteByte('\n')
+	}
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
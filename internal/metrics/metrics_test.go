@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func testInfo() *sysinfo.SystemInfo {
+	return &sysinfo.SystemInfo{
+		Hostname:   "web-1",
+		CPU:        sysinfo.CPUInfo{Usage: []float64{12.5, 50}, LogicalCores: 2},
+		Disk:       []sysinfo.DiskInfo{{Device: "/dev/sda1", Mountpoint: `/data "x"`, Fstype: "ext4", Total: 100, Used: 25, UsedPercent: 25, InodesTotal: 10}},
+		Network:    sysinfo.NetworkInfo{BytesSent: 1024, BytesRecv: 2048},
+		Collectors: []string{sysinfo.SectionHost, sysinfo.SectionCPU, sysinfo.SectionDisk, sysinfo.SectionNetwork},
+		Errors:     []sysinfo.SectionError{{Section: sysinfo.SectionDisk, Message: "slow", TimedOut: true}},
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	ports := []sysinfo.PortInfo{{Port: "22", Protocol: "TCP", Address: "0.0.0.0", Process: "sshd", PID: 1}}
+	if err := Write(&buf, testInfo(), ports, false); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE sysinfo_host_info gauge\n",
+		`sysinfo_host_info{hostname="web-1",os="",kernel="",arch=""} 1` + "\n",
+		`sysinfo_cpu_usage_percent{cpu="1"} 50` + "\n",
+		"# TYPE sysinfo_network_bytes_total counter\n",
+		`sysinfo_network_bytes_total{direction="received"} 2048` + "\n",
+		`sysinfo_disk_used_bytes{device="/dev/sda1",mountpoint="/data \"x\"",fstype="ext4"} 25` + "\n",
+		`sysinfo_collector_timed_out{collector="disk"} 1` + "\n",
+		`sysinfo_collector_success{collector="cpu"} 1` + "\n",
+		`sysinfo_listening_ports{protocol="TCP"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if strings.Contains(out, "# EOF") {
+		t.Error("text format must not end with # EOF")
+	}
+	if strings.Contains(out, "sysinfo_memory_total_bytes") {
+		t.Error("memory metrics emitted although the memory collector did not run")
+	}
+	if n := strings.Count(out, "# TYPE sysinfo_network_bytes_total"); n != 1 {
+		t.Errorf("network_bytes TYPE line appears %d times, want 1", n)
+	}
+}
+
+func TestWriteDuplicatePorts(t *testing.T) {
+	var buf bytes.Buffer
+	unknown := sysinfo.PortInfo{Port: "53", Protocol: "UDP", Address: "0.0.0.0", Process: "unknown"}
+	ports := []sysinfo.PortInfo{unknown, unknown, {Port: "53", Protocol: "UDP", Address: "::", Process: "unknown"}}
+	if err := Write(&buf, nil, ports, false); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, `sysinfo_listening_port{port="53",protocol="UDP",address="0.0.0.0",process="unknown",pid="0"} 1`); n != 1 {
+		t.Errorf("duplicate port written %d times, want 1", n)
+	}
+	if n := strings.Count(out, "sysinfo_listening_port{"); n != 2 {
+		t.Errorf("%d listening_port series, want 2", n)
+	}
+	// The count is still of sockets
+	if !strings.Contains(out, `sysinfo_listening_ports{protocol="UDP"} 3`) {
+		t.Errorf("output missing the socket count:\n%s", out)
+	}
+}
+
+func TestWriteSameNameSensors(t *testing.T) {
+	var buf bytes.Buffer
+	info := &sysinfo.SystemInfo{
+		Temperature: sysinfo.TemperatureInfo{Sensors: []sysinfo.SensorInfo{
+			{Name: "nvme Composite", Device: "hwmon1", Temperature: 38, Critical: 84},
+			{Name: "nvme Composite", Device: "hwmon2", Temperature: 41, Critical: 84},
+		}},
+		Collectors: []string{sysinfo.SectionTemperature},
+	}
+	if err := Write(&buf, info, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`sysinfo_sensor_temperature_celsius{device="hwmon1",sensor="nvme Composite"} 38` + "\n",
+		`sysinfo_sensor_temperature_celsius{device="hwmon2",sensor="nvme Composite"} 41` + "\n",
+		`sysinfo_sensor_critical_celsius{device="hwmon2",sensor="nvme Composite"} 84` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testInfo(), nil, true); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE sysinfo_host info\n",
+		"# TYPE sysinfo_network_bytes counter\n",
+		`sysinfo_network_bytes_total{direction="sent"} 1024` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Error("OpenMetrics output must end with # EOF")
+	}
+}
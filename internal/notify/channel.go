@@ -0,0 +1,216 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Channel delivers a group of events somewhere. Send makes one attempt;
+// retries are up to the Notifier.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, p Payload) error
+}
+
+// newChannel builds the channel described by cfg, which has been validated
+func newChannel(cfg ChannelConfig) Channel {
+	switch cfg.Type {
+	case "slack", "mattermost":
+		return &slackChannel{cfg}
+	case "smtp":
+		return &smtpChannel{cfg}
+	case "exec":
+		return &execChannel{cfg}
+	}
+	return &webhookChannel{cfg}
+}
+
+// webhookChannel posts the Payload as JSON
+type webhookChannel struct {
+	cfg ChannelConfig
+}
+
+func (c *webhookChannel) Name() string { return c.cfg.Name }
+
+func (c *webhookChannel) Send(ctx context.Context, p Payload) error {
+	return postJSON(ctx, c.cfg, p)
+}
+
+// slackChannel posts a message to a Slack or Mattermost incoming webhook,
+// which accept the same format
+type slackChannel struct {
+	cfg ChannelConfig
+}
+
+func (c *slackChannel) Name() string { return c.cfg.Name }
+
+func (c *slackChannel) Send(ctx context.Context, p Payload) error {
+	msg := map[string]string{"text": "*" + title(p.Events) + "*\n" + text(p.Events)}
+	if c.cfg.Channel != "" {
+		msg["channel"] = c.cfg.Channel
+	}
+	if c.cfg.Username != "" {
+		msg["username"] = c.cfg.Username
+	}
+	return postJSON(ctx, c.cfg, msg)
+}
+
+func postJSON(ctx context.Context, cfg ChannelConfig, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", cfg.URL, resp.Status)
+	}
+	return nil
+}
+
+// smtpChannel sends a plain text email, upgrading to TLS with STARTTLS
+// when the server offers it
+type smtpChannel struct {
+	cfg ChannelConfig
+}
+
+func (c *smtpChannel) Name() string { return c.cfg.Name }
+
+func (c *smtpChannel) Send(ctx context.Context, p Payload) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	addr := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: c.cfg.Host}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if c.cfg.TLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, c.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !c.cfg.TLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.cfg.User != "" {
+		password := c.cfg.Password
+		if c.cfg.PasswordEnv != "" {
+			password = os.Getenv(c.cfg.PasswordEnv)
+		}
+		if err := client.Auth(smtp.PlainAuth("", c.cfg.User, password, c.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range c.cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(c.message(p)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (c *smtpChannel) message(p Payload) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(title(p.Events)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(text(p.Events), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// headerValue makes text safe for a mail header. Summaries carry process
+// names and rendered templates, so line breaks that would start a header of
+// their own become spaces, and non-ASCII text is RFC 2047 encoded.
+func headerValue(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, s)
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// execChannel runs a local command with the Payload as JSON on stdin. A
+// non-zero exit status counts as a failed delivery.
+type execChannel struct {
+	cfg ChannelConfig
+}
+
+func (c *execChannel) Name() string { return c.cfg.Name }
+
+func (c *execChannel) Send(ctx context.Context, p Payload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.cfg.Command, c.cfg.Args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", c.cfg.Command, err, msg)
+		}
+		return fmt.Errorf("%s: %w", c.cfg.Command, err)
+	}
+	return nil
+}
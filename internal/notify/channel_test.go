@@ -0,0 +1,194 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPayload() Payload {
+	e := Event{Kind: KindDiskUsage, Severity: SeverityCritical, Host: "web1", Time: time.Unix(1700000000, 0).UTC(), Summary: "/var is 96.0% full, above 95%"}
+	return Payload{Host: "web1", Group: KindDiskUsage, Events: []Event{e}}
+}
+
+func TestWebhookChannel(t *testing.T) {
+	var got Payload
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	ch := newChannel(ChannelConfig{Name: "hook", Type: "webhook", URL: srv.URL, Timeout: time.Second, Headers: map[string]string{"Authorization": "Bearer s3cret"}})
+	if err := ch.Send(context.Background(), testPayload()); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(got.Events) != 1 || got.Events[0].Summary != testPayload().Events[0].Summary || got.Group != KindDiskUsage {
+		t.Errorf("received %+v", got)
+	}
+}
+
+func TestWebhookChannelError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ch := newChannel(ChannelConfig{Name: "hook", Type: "webhook", URL: srv.URL, Timeout: time.Second})
+	if err := ch.Send(context.Background(), testPayload()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v, want the 503 status", err)
+	}
+}
+
+func TestSlackChannel(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	ch := newChannel(ChannelConfig{Name: "chat", Type: "mattermost", URL: srv.URL, Timeout: time.Second, Channel: "ops", Username: "sysinfo"})
+	if err := ch.Send(context.Background(), testPayload()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got["text"], "*[CRITICAL] web1: /var is 96.0% full") || got["channel"] != "ops" || got["username"] != "sysinfo" {
+		t.Errorf("received %q", got)
+	}
+}
+
+// smtpServer is a minimal SMTP server that records one message
+type smtpServer struct {
+	addr string
+	rcpt []string
+	data chan string
+}
+
+func startSMTPServer(t *testing.T) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &smtpServer{addr: ln.Addr().String(), data: make(chan string, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				s.rcpt = append(s.rcpt, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				s.data <- msg.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return s
+}
+
+func TestSMTPChannel(t *testing.T) {
+	srv := startSMTPServer(t)
+	host, port, _ := net.SplitHostPort(srv.addr)
+	portNum, _ := strconv.Atoi(port)
+
+	ch := newChannel(ChannelConfig{Name: "mail", Type: "smtp", Host: host, Port: portNum, Timeout: 5 * time.Second,
+		From: "sysinfo@example.com", To: []string{"ops@example.com", "oncall@example.com"}})
+	if err := ch.Send(context.Background(), testPayload()); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-srv.data
+	if !strings.Contains(msg, "Subject: [CRITICAL] web1: /var is 96.0% full") || !strings.Contains(msg, "To: ops@example.com, oncall@example.com") {
+		t.Errorf("message:\n%s", msg)
+	}
+	if len(srv.rcpt) != 2 || srv.rcpt[1] != "oncall@example.com" {
+		t.Errorf("recipients = %v", srv.rcpt)
+	}
+}
+
+func TestSMTPHeaderInjection(t *testing.T) {
+	ch := &smtpChannel{cfg: ChannelConfig{From: "sysinfo@example.com", To: []string{"ops@example.com"}}}
+	p := testPayload()
+	p.Events[0].Summary = "New listening port 80/TCP by evil\r\nBcc: victim@example.com\nX: y (pid 1) – ünicode"
+	msg := string(ch.message(p))
+	header, _, _ := strings.Cut(msg, "\r\n\r\n")
+	lines := strings.Split(header, "\r\n")
+	if len(lines) != 6 {
+		t.Fatalf("header has %d lines, want 6:\n%s", len(lines), header)
+	}
+	for _, line := range lines {
+		if strings.ContainsAny(line, "\r\n") || strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("injected header line %q", line)
+		}
+	}
+	subject := strings.TrimPrefix(lines[2], "Subject: ")
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err != nil || !strings.Contains(decoded, "evil  Bcc: victim@example.com X: y (pid 1) – ünicode") {
+		t.Errorf("subject %q decodes to %q, %v", subject, decoded, err)
+	}
+}
+
+func TestExecChannel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	out := filepath.Join(t.TempDir(), "event.json")
+	ch := newChannel(ChannelConfig{Name: "script", Type: "exec", Command: "sh", Args: []string{"-c", "cat > " + out}, Timeout: 5 * time.Second})
+	if err := ch.Send(context.Background(), testPayload()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Payload
+	if err := json.Unmarshal(data, &got); err != nil || len(got.Events) != 1 || got.Events[0].Kind != KindDiskUsage {
+		t.Errorf("stdin was %s (%v)", data, err)
+	}
+
+	failing := newChannel(ChannelConfig{Name: "script", Type: "exec", Command: "sh", Args: []string{"-c", "echo broken >&2; exit 3"}, Timeout: 5 * time.Second})
+	if err := failing.Send(context.Background(), testPayload()); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("err = %v, want the script's stderr", err)
+	}
+}
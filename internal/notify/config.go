@@ -0,0 +1,245 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for omitted settings
+const (
+	DefaultAttempts       = 3
+	DefaultBackoff        = 2 * time.Second
+	DefaultMaxBackoff     = time.Minute
+	DefaultChannelTimeout = 10 * time.Second
+	DefaultEventInterval  = 30 * time.Second
+)
+
+// Config is the layout of a notification config file
+//
+//	channels:
+//	  - {name: ops, type: webhook, url: "https://example.com/hook"}
+//	  - {name: chat, type: slack, url: "https://hooks.slack.com/services/..."}
+//	routes:
+//	  - {kinds: [disk_usage], min_severity: warning, channels: [ops, chat]}
+//	group: {wait: 30s, by: [kind]}
+//	retry: {attempts: 5, backoff: 1s, max_backoff: 1m}
+//	events: {disk_levels: [80, 90, 95], ports: true, users: true}
+type Config struct {
+	Channels []ChannelConfig `yaml:"channels"`
+	// Routes pick the channels for each event; every matching route
+	// delivers. Without routes every event goes to every channel.
+	Routes []Route      `yaml:"routes"`
+	Group  GroupConfig  `yaml:"group"`
+	Retry  RetryConfig  `yaml:"retry"`
+	Events EventsConfig `yaml:"events"`
+}
+
+// ChannelConfig configures one destination. Which fields apply depends on
+// Type: webhook, slack or mattermost, smtp, or exec.
+type ChannelConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// Timeout bounds one delivery attempt
+	Timeout time.Duration `yaml:"timeout"`
+
+	// webhook, slack and mattermost
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Channel and Username override the defaults of a Slack or Mattermost
+	// incoming webhook
+	Channel  string `yaml:"channel"`
+	Username string `yaml:"username"`
+
+	// smtp
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// PasswordEnv names an environment variable holding the password
+	PasswordEnv string   `yaml:"password_env"`
+	From        string   `yaml:"from"`
+	To          []string `yaml:"to"`
+	// TLS connects with implicit TLS (port 465) instead of STARTTLS
+	TLS bool `yaml:"tls"`
+
+	// exec
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+// Route sends the events it matches to Channels
+type Route struct {
+	// Kinds limits the route to these event kinds; empty matches all
+	Kinds []string `yaml:"kinds"`
+	// MinSeverity limits the route to events at least this severe
+	MinSeverity string   `yaml:"min_severity"`
+	Channels    []string `yaml:"channels"`
+}
+
+func (r Route) matches(e Event) bool {
+	if r.MinSeverity != "" && severityRank(e.Severity) < severityRank(r.MinSeverity) {
+		return false
+	}
+	if len(r.Kinds) == 0 {
+		return true
+	}
+	for _, kind := range r.Kinds {
+		if kind == e.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupConfig batches events per channel. Events with the same group key
+// that arrive within Wait of the first are delivered together; with no
+// Wait every event is delivered on its own right away.
+type GroupConfig struct {
+	Wait time.Duration `yaml:"wait"`
+	// By lists what makes up the group key: kind, severity, host or a label
+	// name. Defaults to kind.
+	By []string `yaml:"by"`
+}
+
+// RetryConfig retries failed deliveries with exponential backoff
+type RetryConfig struct {
+	Attempts   int           `yaml:"attempts"`
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// EventsConfig selects which host events a Watcher reports
+type EventsConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	// DiskLevels are usage percentages; a mountpoint rising past one is
+	// reported, the highest level as critical
+	DiskLevels []float64 `yaml:"disk_levels"`
+	// Ports reports listening ports that appear
+	Ports bool `yaml:"ports"`
+	// Users reports new login sessions
+	Users bool `yaml:"users"`
+}
+
+// ParseConfig parses a notification config from YAML and fills in defaults
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadConfig reads a notification config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	names := make(map[string]bool)
+	for i := range cfg.Channels {
+		ch := &cfg.Channels[i]
+		if ch.Name == "" {
+			return fmt.Errorf("channel %d has no name", i+1)
+		}
+		if names[ch.Name] {
+			return fmt.Errorf("channel %s is defined twice", ch.Name)
+		}
+		names[ch.Name] = true
+		if ch.Timeout <= 0 {
+			ch.Timeout = DefaultChannelTimeout
+		}
+
+		var missing string
+		switch ch.Type {
+		case "webhook", "slack", "mattermost":
+			if ch.URL == "" {
+				missing = "url"
+			}
+		case "smtp":
+			switch {
+			case ch.Host == "":
+				missing = "host"
+			case ch.From == "":
+				missing = "from"
+			case len(ch.To) == 0:
+				missing = "to"
+			}
+			if ch.Port == 0 {
+				ch.Port = 25
+				if ch.TLS {
+					ch.Port = 465
+				}
+			}
+		case "exec":
+			if ch.Command == "" {
+				missing = "command"
+			}
+		default:
+			return fmt.Errorf("channel %s: unknown type %q (want webhook, slack, mattermost, smtp or exec)", ch.Name, ch.Type)
+		}
+		if missing != "" {
+			return fmt.Errorf("channel %s: %s is required for type %s", ch.Name, missing, ch.Type)
+		}
+	}
+
+	for i, r := range cfg.Routes {
+		if len(r.Channels) == 0 {
+			return fmt.Errorf("route %d has no channels", i+1)
+		}
+		for _, name := range r.Channels {
+			if !names[name] {
+				return fmt.Errorf("route %d: unknown channel %q", i+1, name)
+			}
+		}
+		switch r.MinSeverity {
+		case "", SeverityInfo, SeverityWarning, SeverityCritical:
+		default:
+			return fmt.Errorf("route %d: unknown severity %q", i+1, r.MinSeverity)
+		}
+	}
+
+	if cfg.Group.Wait < 0 {
+		return fmt.Errorf("group wait must not be negative")
+	}
+	if len(cfg.Group.By) == 0 {
+		cfg.Group.By = []string{"kind"}
+	}
+	if cfg.Retry.Attempts <= 0 {
+		cfg.Retry.Attempts = DefaultAttempts
+	}
+	if cfg.Retry.Backoff <= 0 {
+		cfg.Retry.Backoff = DefaultBackoff
+	}
+	if cfg.Retry.MaxBackoff <= 0 {
+		cfg.Retry.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Events.Interval <= 0 {
+		cfg.Events.Interval = DefaultEventInterval
+	}
+	for _, level := range cfg.Events.DiskLevels {
+		if level <= 0 || level > 100 {
+			return fmt.Errorf("disk level %v is not a percentage", level)
+		}
+	}
+	sort.Float64s(cfg.Events.DiskLevels)
+	return nil
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Event kinds
+const (
+	KindDiskUsage  = "disk_usage"
+	KindPortOpened = "port_opened"
+	KindUserLogin  = "user_login"
+	KindAlert      = "alert"
+	KindAnomaly    = "anomaly"
+	KindTest       = "test"
+)
+
+// Event severities, least urgent first
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+func severityRank(s string) int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// Event is something that happened on the host worth telling someone about
+type Event struct {
+	Kind     string            `json:"kind"`
+	Severity string            `json:"severity"`
+	Host     string            `json:"host"`
+	Time     time.Time         `json:"time"`
+	Summary  string            `json:"summary"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// NewEvent returns an event for this host at the current time
+func NewEvent(kind, severity, summary string, labels map[string]string) Event {
+	return Event{Kind: kind, Severity: severity, Host: hostname(), Time: time.Now(), Summary: summary, Labels: labels}
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
+// Payload is the JSON document webhook and exec channels receive: the
+// events of one group, delivered together
+type Payload struct {
+	Host   string  `json:"host"`
+	Group  string  `json:"group"`
+	Events []Event `json:"events"`
+}
+
+// title summarizes a group of events in one line
+func title(events []Event) string {
+	if len(events) == 1 {
+		return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(events[0].Severity), events[0].Host, events[0].Summary)
+	}
+	top := events[0]
+	for _, e := range events[1:] {
+		if severityRank(e.Severity) > severityRank(top.Severity) {
+			top = e
+		}
+	}
+	return fmt.Sprintf("[%s] %s: %d events", strings.ToUpper(top.Severity), top.Host, len(events))
+}
+
+// text renders a group of events as plain text, one line per event
+func text(events []Event) string {
+	var b strings.Builder
+	for _, e := range events {
+		fmt.Fprintf(&b, "%s [%s] %s %s\n", e.Time.Format(time.RFC3339), e.Severity, e.Kind, e.Summary)
+	}
+	return b.String()
+}
@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier routes events to channels, batches them into groups and
+// delivers each group with retries
+type Notifier struct {
+	cfg      *Config
+	channels map[string]Channel
+	order    []string // channel names in config order
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	groups map[string]*group
+	closed bool
+}
+
+// group collects the events for one channel and group key until it is sent
+type group struct {
+	channel string
+	key     string
+	events  []Event
+	timer   *time.Timer
+}
+
+// New builds a Notifier from a validated config
+func New(cfg *Config) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		cfg:      cfg,
+		channels: make(map[string]Channel),
+		ctx:      ctx,
+		cancel:   cancel,
+		groups:   make(map[string]*group),
+	}
+	for _, chCfg := range cfg.Channels {
+		n.channels[chCfg.Name] = newChannel(chCfg)
+		n.order = append(n.order, chCfg.Name)
+	}
+	return n
+}
+
+// Channels returns the channel names in config order
+func (n *Notifier) Channels() []string {
+	return n.order
+}
+
+// route returns the channels an event goes to
+func (n *Notifier) route(e Event) []string {
+	if len(n.cfg.Routes) == 0 {
+		return n.order
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range n.cfg.Routes {
+		if !r.matches(e) {
+			continue
+		}
+		for _, name := range r.Channels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// groupKey joins the Group.By fields of an event
+func (n *Notifier) groupKey(e Event) string {
+	parts := make([]string, len(n.cfg.Group.By))
+	for i, field := range n.cfg.Group.By {
+		switch field {
+		case "kind":
+			parts[i] = e.Kind
+		case "severity":
+			parts[i] = e.Severity
+		case "host":
+			parts[i] = e.Host
+		default:
+			parts[i] = e.Labels[field]
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Notify queues events for delivery. It doesn't block on the channels.
+func (n *Notifier) Notify(events ...Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	for _, e := range events {
+		key := n.groupKey(e)
+		for _, name := range n.route(e) {
+			id := name + "\x00" + key
+			g, ok := n.groups[id]
+			if !ok {
+				g = &group{channel: name, key: key}
+				n.groups[id] = g
+				if n.cfg.Group.Wait > 0 {
+					g.timer = time.AfterFunc(n.cfg.Group.Wait, func() { n.flush(id) })
+				}
+			}
+			g.events = append(g.events, e)
+			if g.timer == nil {
+				n.sendLocked(id)
+			}
+		}
+	}
+}
+
+// flush sends a group whose wait is over
+func (n *Notifier) flush(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.groups[id]; ok {
+		n.sendLocked(id)
+	}
+}
+
+// sendLocked removes a group and delivers it in the background
+func (n *Notifier) sendLocked(id string) {
+	g := n.groups[id]
+	delete(n.groups, id)
+	p := Payload{Host: g.events[0].Host, Group: g.key, Events: g.events}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.deliver(n.ctx, n.channels[g.channel], p); err != nil {
+			log.Printf("notify: %s: giving up after %d attempts: %v", g.channel, n.cfg.Retry.Attempts, err)
+		}
+	}()
+}
+
+// deliver sends p, retrying with exponential backoff
+func (n *Notifier) deliver(ctx context.Context, ch Channel, p Payload) error {
+	backoff := n.cfg.Retry.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = ch.Send(ctx, p); err == nil || attempt >= n.cfg.Retry.Attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, n.cfg.Retry.MaxBackoff)
+	}
+}
+
+// Test sends a test event straight to one channel, or to every channel when
+// name is empty, bypassing routes and grouping. It returns the outcome per
+// channel.
+func (n *Notifier) Test(ctx context.Context, name string) (map[string]error, error) {
+	names := n.order
+	if name != "" {
+		if _, ok := n.channels[name]; !ok {
+			return nil, fmt.Errorf("unknown channel %q (configured: %s)", name, strings.Join(n.order, ", "))
+		}
+		names = []string{name}
+	}
+
+	e := NewEvent(KindTest, SeverityInfo, "Test notification from sysinfo", nil)
+	p := Payload{Host: e.Host, Group: KindTest, Events: []Event{e}}
+	results := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			err := n.deliver(ctx, n.channels[name], p)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// Close sends the groups still waiting and waits for deliveries in flight,
+// giving up on retries once ctx is done
+func (n *Notifier) Close(ctx context.Context) {
+	n.mu.Lock()
+	n.closed = true
+	for id, g := range n.groups {
+		g.timer.Stop()
+		n.sendLocked(id)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		n.cancel()
+		<-done
+	}
+}
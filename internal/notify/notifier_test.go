@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChannel records deliveries and fails the first failures attempts
+type fakeChannel struct {
+	name     string
+	mu       sync.Mutex
+	failures int
+	attempts int
+	sent     []Payload
+}
+
+func (c *fakeChannel) Name() string { return c.name }
+
+func (c *fakeChannel) Send(ctx context.Context, p Payload) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.attempts <= c.failures {
+		return errors.New("unavailable")
+	}
+	c.sent = append(c.sent, p)
+	return nil
+}
+
+func (c *fakeChannel) deliveries() []Payload {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Payload(nil), c.sent...)
+}
+
+func newTestNotifier(t *testing.T, config string, names ...string) (*Notifier, map[string]*fakeChannel) {
+	t.Helper()
+	cfg, err := ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := New(cfg)
+	fakes := make(map[string]*fakeChannel)
+	for _, name := range names {
+		fakes[name] = &fakeChannel{name: name}
+		n.channels[name] = fakes[name]
+	}
+	return n, fakes
+}
+
+const twoChannels = `
+channels:
+  - {name: hook, type: webhook, url: "http://127.0.0.1:1"}
+  - {name: mail, type: smtp, host: localhost, from: a@example.com, to: [b@example.com]}
+`
+
+func TestNotifierRoutes(t *testing.T) {
+	n, fakes := newTestNotifier(t, twoChannels+`
+routes:
+  - {kinds: [disk_usage], channels: [hook]}
+  - {min_severity: critical, channels: [mail]}
+`, "hook", "mail")
+
+	n.Notify(
+		Event{Kind: KindDiskUsage, Severity: SeverityWarning},
+		Event{Kind: KindPortOpened, Severity: SeverityWarning},
+		Event{Kind: KindAlert, Severity: SeverityCritical},
+	)
+	n.Close(context.Background())
+
+	if got := fakes["hook"].deliveries(); len(got) != 1 || got[0].Events[0].Kind != KindDiskUsage {
+		t.Errorf("hook got %+v, want only the disk event", got)
+	}
+	if got := fakes["mail"].deliveries(); len(got) != 1 || got[0].Events[0].Kind != KindAlert {
+		t.Errorf("mail got %+v, want only the critical event", got)
+	}
+}
+
+func TestNotifierGroups(t *testing.T) {
+	n, fakes := newTestNotifier(t, twoChannels+`
+group: {wait: 50ms, by: [kind]}
+`, "hook", "mail")
+
+	n.Notify(Event{Kind: KindDiskUsage, Summary: "/"}, Event{Kind: KindPortOpened})
+	n.Notify(Event{Kind: KindDiskUsage, Summary: "/var"})
+	time.Sleep(200 * time.Millisecond)
+
+	got := fakes["hook"].deliveries()
+	if len(got) != 2 {
+		t.Fatalf("hook got %d deliveries, want one per kind", len(got))
+	}
+	for _, p := range got {
+		if p.Group == KindDiskUsage && len(p.Events) != 2 {
+			t.Errorf("disk group has %d events, want 2", len(p.Events))
+		}
+	}
+
+	// Close sends groups that are still waiting
+	n.Notify(Event{Kind: KindUserLogin})
+	n.Close(context.Background())
+	if got := fakes["mail"].deliveries(); len(got) != 3 {
+		t.Errorf("mail got %d deliveries after Close, want 3", len(got))
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	n, fakes := newTestNotifier(t, twoChannels+`
+retry: {attempts: 3, backoff: 10ms}
+`, "hook", "mail")
+	fakes["hook"].failures = 2
+	fakes["mail"].failures = 5
+
+	results, err := n.Test(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results["hook"] != nil || fakes["hook"].attempts != 3 {
+		t.Errorf("hook: %v after %d attempts, want success on the third", results["hook"], fakes["hook"].attempts)
+	}
+	if results["mail"] == nil || fakes["mail"].attempts != 3 {
+		t.Errorf("mail: %v after %d attempts, want failure after 3", results["mail"], fakes["mail"].attempts)
+	}
+	if got := fakes["hook"].deliveries(); len(got) != 1 || got[0].Events[0].Kind != KindTest {
+		t.Errorf("hook got %+v", got)
+	}
+
+	if _, err := n.Test(context.Background(), "pager"); err == nil {
+		t.Error("expected an error for an unknown channel")
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown type":    "channels: [{name: a, type: fax}]",
+		"missing url":     "channels: [{name: a, type: webhook}]",
+		"missing to":      "channels: [{name: a, type: smtp, host: h, from: f}]",
+		"duplicate":       "channels: [{name: a, type: exec, command: x}, {name: a, type: exec, command: y}]",
+		"unknown channel": "channels: [{name: a, type: exec, command: x}]\nroutes: [{channels: [b]}]",
+		"bad severity":    "channels: [{name: a, type: exec, command: x}]\nroutes: [{channels: [a], min_severity: high}]",
+		"bad level":       "events: {disk_levels: [150]}",
+		"unknown field":   "channels: [{name: a, type: exec, command: x, cmd: y}]",
+	}
+	for name, data := range tests {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// Watcher turns changes between system snapshots into events: mountpoints
+// rising past a usage level, listening ports that appear and new login
+// sessions. Ports and sessions present in the first snapshot are taken as
+// the baseline rather than reported.
+type Watcher struct {
+	cfg EventsConfig
+
+	// diskLevel is the index of the highest level each mountpoint is at,
+	// -1 below all of them
+	diskLevel map[string]int
+	ports     map[string]bool
+	users     map[string]bool
+}
+
+func NewWatcher(cfg EventsConfig) *Watcher {
+	return &Watcher{cfg: cfg, diskLevel: make(map[string]int)}
+}
+
+// Collectors returns the collectors the snapshots passed to Observe need
+func (w *Watcher) Collectors() []string {
+	var names []string
+	if len(w.cfg.DiskLevels) > 0 {
+		names = append(names, sysinfo.SectionDisk)
+	}
+	if w.cfg.Users {
+		names = append(names, sysinfo.SectionUsers)
+	}
+	return names
+}
+
+// Observe checks a snapshot taken with Collectors, reading the listening
+// ports itself when they are watched, and returns the events
+func (w *Watcher) Observe(info *sysinfo.SystemInfo) []Event {
+	var ports []sysinfo.PortInfo
+	if w.cfg.Ports {
+		ports, _ = sysinfo.GetOpenPorts()
+	}
+	return w.Check(info, ports)
+}
+
+// Check compares a snapshot with the previous one. Sections that weren't
+// collected, and a nil ports list, leave their state untouched.
+func (w *Watcher) Check(info *sysinfo.SystemInfo, ports []sysinfo.PortInfo) []Event {
+	collected := func(section string) bool {
+		return info.Collected(section) && !info.SectionFailed(section)
+	}
+
+	var events []Event
+	if len(w.cfg.DiskLevels) > 0 && collected(sysinfo.SectionDisk) {
+		events = append(events, w.checkDisks(info.Disk)...)
+	}
+	if w.cfg.Ports && ports != nil {
+		events = append(events, w.checkPorts(ports)...)
+	}
+	if w.cfg.Users && collected(sysinfo.SectionUsers) {
+		events = append(events, w.checkUsers(info.Users)...)
+	}
+	return events
+}
+
+func (w *Watcher) checkDisks(disks []sysinfo.DiskInfo) []Event {
+	var events []Event
+	levels := w.cfg.DiskLevels
+	for _, d := range disks {
+		level := -1
+		for i, l := range levels {
+			if d.UsedPercent >= l {
+				level = i
+			}
+		}
+		prev, ok := w.diskLevel[d.Mountpoint]
+		if !ok {
+			prev = -1
+		}
+		// Falling back below a level is not reported, but lets the level be
+		// reported again the next time it is crossed
+		w.diskLevel[d.Mountpoint] = level
+		if level <= prev {
+			continue
+		}
+
+		severity := SeverityWarning
+		if level == len(levels)-1 {
+			severity = SeverityCritical
+		}
+		threshold := strconv.FormatFloat(levels[level], 'f', -1, 64)
+		events = append(events, NewEvent(KindDiskUsage, severity,
+			fmt.Sprintf("%s is %.1f%% full, above %s%%", d.Mountpoint, d.UsedPercent, threshold),
+			map[string]string{
+				"mountpoint":   d.Mountpoint,
+				"device":       d.Device,
+				"level":        threshold,
+				"used_percent": strconv.FormatFloat(d.UsedPercent, 'f', 1, 64),
+			}))
+	}
+	return events
+}
+
+func (w *Watcher) checkPorts(ports []sysinfo.PortInfo) []Event {
+	current := make(map[string]bool, len(ports))
+	var events []Event
+	for _, p := range ports {
+		key := p.Protocol + " " + p.Address + " " + p.Port
+		if current[key] {
+			continue
+		}
+		current[key] = true
+		if w.ports == nil || w.ports[key] {
+			continue
+		}
+		process := p.Process
+		if p.PID != 0 {
+			process = fmt.Sprintf("%s (pid %d)", p.Process, p.PID)
+		}
+		events = append(events, NewEvent(KindPortOpened, SeverityWarning,
+			fmt.Sprintf("New listening port %s/%s by %s", p.Port, p.Protocol, process),
+			map[string]string{
+				"port":     p.Port,
+				"protocol": p.Protocol,
+				"address":  p.Address,
+				"process":  p.Process,
+				"pid":      strconv.Itoa(int(p.PID)),
+			}))
+	}
+	w.ports = current
+	return events
+}
+
+func (w *Watcher) checkUsers(users []sysinfo.UserInfo) []Event {
+	current := make(map[string]bool, len(users))
+	var events []Event
+	for _, u := range users {
+		key := fmt.Sprintf("%s|%s|%s|%d", u.User, u.Terminal, u.Host, u.Started)
+		current[key] = true
+		if w.users == nil || w.users[key] {
+			continue
+		}
+		from := u.Host
+		if from == "" {
+			from = "local"
+		}
+		events = append(events, NewEvent(KindUserLogin, SeverityInfo,
+			fmt.Sprintf("%s logged in on %s from %s", u.User, u.Terminal, from),
+			map[string]string{"user": u.User, "terminal": u.Terminal, "from": u.Host}))
+	}
+	w.users = current
+	return events
+}
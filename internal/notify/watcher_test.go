@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func diskSnapshot(used ...float64) *sysinfo.SystemInfo {
+	info := &sysinfo.SystemInfo{Collectors: []string{sysinfo.SectionDisk, sysinfo.SectionUsers}}
+	mounts := []string{"/", "/var"}
+	for i, u := range used {
+		info.Disk = append(info.Disk, sysinfo.DiskInfo{Mountpoint: mounts[i], UsedPercent: u})
+	}
+	return info
+}
+
+func summaries(events []Event) []string {
+	var s []string
+	for _, e := range events {
+		s = append(s, e.Severity+": "+e.Summary)
+	}
+	return s
+}
+
+func TestWatcherDiskLevels(t *testing.T) {
+	w := NewWatcher(EventsConfig{DiskLevels: []float64{80, 90}})
+
+	got := summaries(w.Check(diskSnapshot(50, 85), nil))
+	if len(got) != 1 || got[0] != "warning: /var is 85.0% full, above 80%" {
+		t.Errorf("first check: %q", got)
+	}
+	if got := w.Check(diskSnapshot(50, 88), nil); len(got) != 0 {
+		t.Errorf("same level reported again: %q", summaries(got))
+	}
+	got = summaries(w.Check(diskSnapshot(50, 93), nil))
+	if len(got) != 1 || got[0] != "critical: /var is 93.0% full, above 90%" {
+		t.Errorf("highest level: %q", got)
+	}
+	// Dropping back is silent, rising again is reported
+	w.Check(diskSnapshot(50, 70), nil)
+	if got := w.Check(diskSnapshot(50, 82), nil); len(got) != 1 {
+		t.Errorf("re-crossing: %q", summaries(got))
+	}
+	// A failed disk collection changes nothing
+	if got := w.Check(&sysinfo.SystemInfo{}, nil); len(got) != 0 {
+		t.Errorf("uncollected disks: %q", summaries(got))
+	}
+}
+
+func TestWatcherPortsAndUsers(t *testing.T) {
+	w := NewWatcher(EventsConfig{Ports: true, Users: true})
+	info := diskSnapshot()
+	info.Users = []sysinfo.UserInfo{{User: "alice", Terminal: "pts/0", Host: "10.0.0.5", Started: 1}}
+	ports := []sysinfo.PortInfo{{Port: "22", Protocol: "TCP", Address: "0.0.0.0", Process: "sshd", PID: 10}}
+
+	if got := w.Check(info, ports); len(got) != 0 {
+		t.Errorf("baseline reported: %q", summaries(got))
+	}
+
+	info.Users = append(info.Users, sysinfo.UserInfo{User: "bob", Terminal: "pts/1", Started: 2})
+	ports = append(ports, sysinfo.PortInfo{Port: "4444", Protocol: "TCP", Address: "0.0.0.0", Process: "nc", PID: 99})
+	got := w.Check(info, ports)
+	want := []string{
+		"warning: New listening port 4444/TCP by nc (pid 99)",
+		"info: bob logged in on pts/1 from local",
+	}
+	if s := summaries(got); len(s) != 2 || s[0] != want[0] || s[1] != want[1] {
+		t.Errorf("got %q, want %q", s, want)
+	}
+	if got[0].Labels["port"] != "4444" || got[1].Labels["user"] != "bob" {
+		t.Errorf("labels: %v, %v", got[0].Labels, got[1].Labels)
+	}
+
+	// A port that closes and opens again is new again
+	w.Check(info, ports[:1])
+	if got := w.Check(info, ports); len(got) != 1 {
+		t.Errorf("reopened port: %q", summaries(got))
+	}
+}
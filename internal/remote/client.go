@@ -0,0 +1,218 @@
+// Package remote reads system information from a running sysinfo server
+// over its HTTP API
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// DefaultTimeout bounds one request to a server
+const DefaultTimeout = 30 * time.Second
+
+// Options configure the connection to a sysinfo server
+type Options struct {
+	// Token is sent as a bearer token when set
+	Token string
+	// Timeout for each request, DefaultTimeout when zero
+	Timeout time.Duration
+	// CAFile verifies the server against these PEM certificates instead
+	// of the system roots
+	CAFile string
+	// CertFile and KeyFile present a client certificate
+	CertFile string
+	KeyFile  string
+	// Insecure skips verifying the server certificate
+	Insecure bool
+}
+
+// TLSConfig builds the TLS settings for the options, nil when they need
+// none beyond the defaults
+func (o Options) TLSConfig() (*tls.Config, error) {
+	if o.CAFile == "" && o.CertFile == "" && o.KeyFile == "" && !o.Insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: o.Insecure}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New("a client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// HTTPClient builds an HTTP client with the options' timeout and TLS settings
+func (o Options) HTTPClient() (*http.Client, error) {
+	timeout := o.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	tlsConfig, err := o.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return client, nil
+}
+
+// Client queries one sysinfo server
+type Client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// NewClient returns a client for the server at base, e.g.
+// http://host:8080. A base without a scheme is taken to be http.
+func NewClient(base string, opts Options) (*Client, error) {
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", base)
+	}
+	client, err := opts.HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{base: strings.TrimRight(base, "/"), token: opts.Token, http: client}, nil
+}
+
+// NewClientWith returns a client for the server at base that sends its
+// requests through client
+func NewClientWith(base, token string, client *http.Client) *Client {
+	return &Client{base: strings.TrimRight(base, "/"), token: token, http: client}
+}
+
+// Info fetches the server's system information, limited to collectors when
+// given, and when it was collected
+func (c *Client) Info(ctx context.Context, collectors []string) (*sysinfo.SystemInfo, time.Time, error) {
+	path := "/api/info"
+	if len(collectors) > 0 {
+		path += "?collectors=" + url.QueryEscape(strings.Join(collectors, ","))
+	}
+	var info sysinfo.SystemInfo
+	collectedAt, err := c.Get(ctx, path, &info)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return &info, collectedAt, nil
+}
+
+// Ports fetches the server's listening ports
+func (c *Client) Ports(ctx context.Context) ([]sysinfo.PortInfo, error) {
+	var ports []sysinfo.PortInfo
+	if _, err := c.Get(ctx, "/api/ports", &ports); err != nil {
+		return nil, err
+	}
+	return ports, nil
+}
+
+// Processes fetches the server's top processes
+func (c *Client) Processes(ctx context.Context) ([]sysinfo.ProcessInfo, error) {
+	var resp struct {
+		Processes []sysinfo.ProcessInfo `json:"processes"`
+	}
+	if _, err := c.Get(ctx, "/api/processes", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Processes, nil
+}
+
+// Get decodes the JSON the server returns for path into v and returns when
+// the server collected it, going by its X-Collected-At header
+func (c *Client) Get(ctx context.Context, path string, v interface{}) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	client := c.http
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("GET %s: %w", req.URL, statusError(resp))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return time.Time{}, fmt.Errorf("decode %s: %w", req.URL, err)
+	}
+	collectedAt, err := time.Parse(time.RFC3339Nano, resp.Header.Get("X-Collected-At"))
+	if err != nil {
+		collectedAt = time.Now()
+	}
+	return collectedAt, nil
+}
+
+// StatusError is a response other than 200 OK from a server
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Message is the error the server gave, if any
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return e.Status + ": " + e.Message
+}
+
+// statusError describes a failed response, using the error message of the
+// server's JSON body when it has one
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	e := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	var msg struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &msg) == nil && msg.Error != "" {
+		e.Message = msg.Error
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
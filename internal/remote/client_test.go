@@ -0,0 +1,112 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func testServer(t *testing.T, tls bool) (*httptest.Server, *http.Request) {
+	var last http.Request
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = *r
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid token"})
+			return
+		}
+		w.Header().Set("X-Collected-At", "2026-01-02T03:04:05Z")
+		switch r.URL.Path {
+		case "/api/info":
+			json.NewEncoder(w).Encode(sysinfo.SystemInfo{Hostname: "db1", Collectors: []string{sysinfo.SectionHost}})
+		case "/api/ports":
+			json.NewEncoder(w).Encode([]sysinfo.PortInfo{{Port: "5432", Protocol: "tcp"}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	srv := httptest.NewUnstartedServer(handler)
+	if tls {
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv, &last
+}
+
+func TestClient(t *testing.T) {
+	srv, last := testServer(t, false)
+	c, err := NewClient(strings.TrimPrefix(srv.URL, "http://")+"/", Options{Token: "s3cret", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, collectedAt, err := c.Info(context.Background(), []string{"host", "-services"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Hostname != "db1" || !collectedAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("info = %+v at %s", info, collectedAt)
+	}
+	if got := last.URL.Query().Get("collectors"); got != "host,-services" {
+		t.Errorf("collectors = %q", got)
+	}
+
+	ports, err := c.Ports(context.Background())
+	if err != nil || len(ports) != 1 || ports[0].Port != "5432" {
+		t.Errorf("ports = %+v, %v", ports, err)
+	}
+
+	c, _ = NewClient(srv.URL, Options{Token: "wrong"})
+	if _, _, err := c.Info(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("wrong token: %v", err)
+	}
+	c, _ = NewClient(srv.URL, Options{Token: "s3cret"})
+	var status *StatusError
+	if _, err := c.Get(context.Background(), "/api/alerts", &struct{}{}); !errors.As(err, &status) || status.StatusCode != http.StatusNotFound {
+		t.Errorf("missing endpoint: %v", err)
+	}
+
+	if _, err := NewClient("ftp://host", Options{}); err == nil {
+		t.Error("NewClient accepted an ftp URL")
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	srv, _ := testServer(t, true)
+
+	c, _ := NewClient(srv.URL, Options{Token: "s3cret"})
+	if _, err := c.Ports(context.Background()); err == nil {
+		t.Error("untrusted certificate accepted")
+	}
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(ca, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{{Token: "s3cret", CAFile: ca}, {Token: "s3cret", Insecure: true}} {
+		c, err := NewClient(srv.URL, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Ports(context.Background()); err != nil {
+			t.Errorf("%+v: %v", opts, err)
+		}
+	}
+
+	if _, err := NewClient(srv.URL, Options{CertFile: ca}); err == nil {
+		t.Error("client certificate without key accepted")
+	}
+}
@@ -0,0 +1,170 @@
+package sysinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultSectionTimeout bounds each section when Options.Timeout is zero
+const DefaultSectionTimeout = 5 * time.Second
+
+// sectionGrace is how long a section may keep running past its deadline to
+// hand back partial results after noticing its context was cancelled
+const sectionGrace = 250 * time.Millisecond
+
+// Options controls a GetSystemInfoContext call
+type Options struct {
+	// Timeout bounds each section individually
+	Timeout time.Duration
+	// SectionTimeouts overrides Timeout for specific sections
+	SectionTimeouts map[string]time.Duration
+	// Collectors selects which collectors run, see ResolveCollectors
+	Collectors []string
+}
+
+// timeoutFor returns the deadline that applies to a section
+func (o Options) timeoutFor(section string) time.Duration {
+	if d, ok := o.SectionTimeouts[section]; ok && d > 0 {
+		return d
+	}
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return DefaultSectionTimeout
+}
+
+// GetSystemInfo collects system information with default options
+func GetSystemInfo() (*SystemInfo, error) {
+	return GetSystemInfoContext(context.Background(), Options{})
+}
+
+// GetSystemInfoContext runs the selected collectors concurrently, each bounded
+// by its own deadline. Sections that fail or time out are recorded in Errors
+// (section missing) or Warnings (section incomplete) rather than aborting
+// the whole collection; an error is only returned when no section could be
+// collected at all.
+func GetSystemInfoContext(ctx context.Context, opts Options) (*SystemInfo, error) {
+	collectors, err := ResolveCollectors(opts.Collectors)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SystemInfo{}
+	for _, c := range collectors {
+		info.Collectors = append(info.Collectors, c.Name())
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range collectors {
+		wg.Add(1)
+		go func(c Collector) {
+			defer wg.Done()
+			partial, err := runCollector(ctx, c, opts.timeoutFor(c.Name()))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if partial != nil {
+				mergeInto(info, partial)
+			}
+			if err != nil {
+				info.addError(c.Name(), err)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	info.sortProblems()
+
+	if len(collectors) > 0 && len(info.Errors) == len(collectors) {
+		return info, fmt.Errorf("no system information could be collected: %s", info.Errors[0].Message)
+	}
+	return info, nil
+}
+
+// runCollector runs one collector against a private SystemInfo so an
+// abandoned collector can never race with the merged result
+func runCollector(parent context.Context, c Collector, timeout time.Duration) (*SystemInfo, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	type result struct {
+		info *SystemInfo
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		partial := &SystemInfo{}
+		err := c.Collect(ctx, partial)
+		done <- result{partial, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.info, r.err
+	case <-ctx.Done():
+	}
+
+	// Give collectors that honour ctx a moment to return what they have
+	grace := time.NewTimer(sectionGrace)
+	defer grace.Stop()
+	select {
+	case r := <-done:
+		if r.err == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.info.addWarning(c.Name(), fmt.Errorf("timed out after %s, results are incomplete", timeout))
+		}
+		return r.info, r.err
+	case <-grace.C:
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, &TimeoutError{Section: c.Name(), Timeout: timeout}
+	}
+	return nil, ctx.Err()
+}
+
+// TimeoutError reports a section that did not finish before its deadline
+type TimeoutError struct {
+	Section string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("collector timed out after %s", e.Timeout)
+}
+
+// mergeInto copies every non-zero field of src into dst. Collectors own
+// disjoint fields, so this only ever fills in what a collector gathered.
+func mergeInto(dst, src *SystemInfo) {
+	dst.Errors = append(dst.Errors, src.Errors...)
+	dst.Warnings = append(dst.Warnings, src.Warnings...)
+	for key, value := range src.Extra {
+		if dst.Extra == nil {
+			dst.Extra = make(map[string]interface{})
+		}
+		dst.Extra[key] = value
+	}
+
+	errs, warnings, extra := src.Errors, src.Warnings, src.Extra
+	src.Errors, src.Warnings, src.Extra = nil, nil, nil
+	mergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+	src.Errors, src.Warnings, src.Extra = errs, warnings, extra
+}
+
+func mergeValue(dst, src reflect.Value) {
+	if src.Kind() == reflect.Struct {
+		for i := 0; i < src.NumField(); i++ {
+			mergeValue(dst.Field(i), src.Field(i))
+		}
+		return
+	}
+	if !src.IsZero() {
+		dst.Set(src)
+	}
+}
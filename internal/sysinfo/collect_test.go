@@ -0,0 +1,74 @@
+package sysinfo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunCollectorTimeout(t *testing.T) {
+	hung := CollectorFunc{SectionDisk, true, func(ctx context.Context, info *SystemInfo) error {
+		time.Sleep(time.Minute)
+		return nil
+	}}
+
+	start := time.Now()
+	partial, err := runCollector(context.Background(), hung, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("runCollector waited %v for a hung collector", elapsed)
+	}
+	if partial != nil {
+		t.Errorf("expected no partial result, got %+v", partial)
+	}
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("expected TimeoutError, got %v", err)
+	}
+
+	info := &SystemInfo{}
+	info.addError(SectionDisk, err)
+	if got := info.TimedOut(); len(got) != 1 || got[0] != SectionDisk {
+		t.Errorf("TimedOut() = %v, want [%s]", got, SectionDisk)
+	}
+}
+
+func TestRunCollectorPartialAfterDeadline(t *testing.T) {
+	cooperative := CollectorFunc{SectionDisk, true, func(ctx context.Context, info *SystemInfo) error {
+		info.Disk = append(info.Disk, DiskInfo{Mountpoint: "/"})
+		<-ctx.Done()
+		return nil
+	}}
+
+	partial, err := runCollector(context.Background(), cooperative, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(partial.Disk) != 1 {
+		t.Fatalf("expected the partial disk list, got %+v", partial.Disk)
+	}
+	if len(partial.Warnings) != 1 {
+		t.Errorf("expected a timeout warning, got %+v", partial.Warnings)
+	}
+}
+
+func TestMergeInto(t *testing.T) {
+	dst := &SystemInfo{CPU: CPUInfo{ModelName: "test cpu", Cores: 4}}
+	src := &SystemInfo{
+		Hostname: "host",
+		CPU:      CPUInfo{Temperature: 42},
+		Warnings: []SectionError{{Section: SectionTemperature, Message: "partial"}},
+	}
+
+	mergeInto(dst, src)
+
+	if dst.Hostname != "host" {
+		t.Errorf("Hostname = %q, want host", dst.Hostname)
+	}
+	if dst.CPU.ModelName != "test cpu" || dst.CPU.Cores != 4 || dst.CPU.Temperature != 42 {
+		t.Errorf("CPU not merged field by field: %+v", dst.CPU)
+	}
+	if len(dst.Warnings) != 1 {
+		t.Errorf("expected warnings to be appended, got %+v", dst.Warnings)
+	}
+}
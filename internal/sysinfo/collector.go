@@ -0,0 +1,175 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Collector gathers one section of SystemInfo. Collect fills in a private
+// SystemInfo that is merged into the final result once it returns, so a
+// collector only needs to set the fields it owns. Collectors that gather
+// data with no dedicated field can store it under info.Extra[Name()].
+type Collector interface {
+	Name() string
+	EnabledByDefault() bool
+	Collect(ctx context.Context, info *SystemInfo) error
+}
+
+// CollectorFunc adapts a plain function into a Collector
+type CollectorFunc struct {
+	CollectorName string
+	Default       bool
+	Fn            func(ctx context.Context, info *SystemInfo) error
+}
+
+func (c CollectorFunc) Name() string           { return c.CollectorName }
+func (c CollectorFunc) EnabledByDefault() bool { return c.Default }
+func (c CollectorFunc) Collect(ctx context.Context, info *SystemInfo) error {
+	return c.Fn(ctx, info)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Collector
+)
+
+func init() {
+	for _, c := range []CollectorFunc{
+		{SectionHost, true, collectHost},
+		{SectionCPU, true, collectCPU},
+		{SectionMemory, true, collectMemory},
+		{SectionSwap, true, collectSwap},
+		{SectionLoad, true, collectLoad},
+		{SectionDisk, true, collectDisks},
+		{SectionNetwork, true, collectNetwork},
+		{SectionProcesses, true, collectProcesses},
+		{SectionTemperature, true, collectTemperature},
+		{SectionIO, true, collectIOStats},
+		{SectionUsers, true, collectUsers},
+		{SectionServices, true, collectServices},
+	} {
+		Register(c)
+	}
+}
+
+// Register adds a collector to the registry. It panics if the name is empty
+// or already registered, so conflicts surface at startup.
+func Register(c Collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name := c.Name()
+	if name == "" {
+		panic("sysinfo: Register collector with empty name")
+	}
+	for _, existing := range registry {
+		if existing.Name() == name {
+			panic("sysinfo: Register called twice for collector " + name)
+		}
+	}
+	registry = append(registry, c)
+}
+
+// unregister removes a collector added by Register, for tests
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = slices.DeleteFunc(registry, func(c Collector) bool { return c.Name() == name })
+}
+
+// Collectors returns every registered collector in registration order
+func Collectors() []Collector {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	collectors := make([]Collector, len(registry))
+	copy(collectors, registry)
+	return collectors
+}
+
+// CollectorNames returns the names of every registered collector
+func CollectorNames() []string {
+	var names []string
+	for _, c := range Collectors() {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// ResolveCollectors turns a selection into the collectors to run. An empty
+// selection means every collector enabled by default. Plain names select
+// exactly those collectors; names prefixed with "+" or "-" add to or remove
+// from the default set instead.
+func ResolveCollectors(selection []string) ([]Collector, error) {
+	all := Collectors()
+	byName := make(map[string]Collector, len(all))
+	for _, c := range all {
+		byName[c.Name()] = c
+	}
+
+	enabled := make(map[string]bool)
+	explicit := false
+	for _, raw := range selection {
+		name := strings.TrimSpace(raw)
+		if name != "" && name[0] != '+' && name[0] != '-' {
+			explicit = true
+		}
+	}
+	if !explicit {
+		for _, c := range all {
+			if c.EnabledByDefault() {
+				enabled[c.Name()] = true
+			}
+		}
+	}
+
+	var unknown []string
+	for _, raw := range selection {
+		name := strings.TrimSpace(raw)
+		if name == "" {
+			continue
+		}
+		enable := true
+		switch name[0] {
+		case '+':
+			name = name[1:]
+		case '-':
+			name, enable = name[1:], false
+		}
+		if _, ok := byName[name]; !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		enabled[name] = enable
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown collectors: %s (available: %s)",
+			strings.Join(unknown, ", "), strings.Join(CollectorNames(), ", "))
+	}
+
+	var collectors []Collector
+	for _, c := range all {
+		if enabled[c.Name()] {
+			collectors = append(collectors, c)
+		}
+	}
+	return collectors, nil
+}
+
+// ParseCollectorList splits a comma-separated collector selection such as
+// the value of --collectors or ?collectors=
+func ParseCollectorList(spec string) []string {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
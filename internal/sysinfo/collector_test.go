@@ -0,0 +1,79 @@
+package sysinfo
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveCollectors(t *testing.T) {
+	names := func(collectors []Collector) []string {
+		var out []string
+		for _, c := range collectors {
+			out = append(out, c.Name())
+		}
+		return out
+	}
+
+	all, err := ResolveCollectors(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaults []string
+	for _, c := range Collectors() {
+		if c.EnabledByDefault() {
+			defaults = append(defaults, c.Name())
+		}
+	}
+	if got := names(all); !reflect.DeepEqual(got, defaults) {
+		t.Errorf("default selection = %v, want %v", got, defaults)
+	}
+
+	explicit, err := ResolveCollectors([]string{"memory", "cpu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(explicit); !reflect.DeepEqual(got, []string{"cpu", "memory"}) {
+		t.Errorf("explicit selection = %v, want [cpu memory] in registry order", got)
+	}
+
+	adjusted, err := ResolveCollectors([]string{"-services", "-processes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names(adjusted) {
+		if name == SectionServices || name == SectionProcesses {
+			t.Errorf("%s should have been disabled", name)
+		}
+	}
+	if len(adjusted) != len(all)-2 {
+		t.Errorf("expected %d collectors, got %d", len(all)-2, len(adjusted))
+	}
+
+	if _, err := ResolveCollectors([]string{"cpu", "gpu"}); err == nil || !strings.Contains(err.Error(), "gpu") {
+		t.Errorf("expected unknown collector error naming gpu, got %v", err)
+	}
+}
+
+func TestCustomCollector(t *testing.T) {
+	Register(CollectorFunc{"test_custom", false, func(ctx context.Context, info *SystemInfo) error {
+		info.Extra = map[string]interface{}{"test_custom": "ok"}
+		return nil
+	}})
+	t.Cleanup(func() { unregister("test_custom") })
+
+	info, err := GetSystemInfoContext(context.Background(), Options{Collectors: []string{"test_custom"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Collectors, []string{"test_custom"}) {
+		t.Errorf("Collectors = %v, want [test_custom]", info.Collectors)
+	}
+	if info.Extra["test_custom"] != "ok" {
+		t.Errorf("Extra = %v, want test_custom=ok", info.Extra)
+	}
+	if info.Hostname != "" {
+		t.Error("host collector should not have run")
+	}
+}
@@ -0,0 +1,95 @@
+package sysinfo
+
+import (
+	"errors"
+	"sort"
+)
+
+// Names of the built-in collectors, also used to key errors and warnings
+const (
+	SectionHost        = "host"
+	SectionCPU         = "cpu"
+	SectionMemory      = "memory"
+	SectionSwap        = "swap"
+	SectionLoad        = "load"
+	SectionDisk        = "disk"
+	SectionNetwork     = "network"
+	SectionProcesses   = "processes"
+	SectionTemperature = "temperature"
+	SectionIO          = "io"
+	SectionUsers       = "users"
+	SectionServices    = "services"
+)
+
+// SectionError records why a section of SystemInfo is missing or incomplete
+type SectionError struct {
+	Section  string `json:"section"`
+	Message  string `json:"message"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+}
+
+func (e SectionError) Error() string {
+	return e.Section + ": " + e.Message
+}
+
+// Collected reports whether the named collector ran for this result
+func (s *SystemInfo) Collected(section string) bool {
+	for _, name := range s.Collectors {
+		if name == section {
+			return true
+		}
+	}
+	return false
+}
+
+// SectionFailed reports whether the named section could not be collected
+func (s *SystemInfo) SectionFailed(section string) bool {
+	for _, e := range s.Errors {
+		if e.Section == section {
+			return true
+		}
+	}
+	return false
+}
+
+// TimedOut returns the sections that were abandoned because their collector
+// did not finish before its deadline
+func (s *SystemInfo) TimedOut() []string {
+	var timedOut []string
+	for _, e := range s.Errors {
+		if e.TimedOut {
+			timedOut = append(timedOut, e.Section)
+		}
+	}
+	return timedOut
+}
+
+// addError marks a section as missing
+func (s *SystemInfo) addError(section string, err error) {
+	var timeout *TimeoutError
+	s.Errors = append(s.Errors, SectionError{
+		Section:  section,
+		Message:  err.Error(),
+		TimedOut: errors.As(err, &timeout),
+	})
+}
+
+// addWarning marks a section as collected but incomplete
+func (s *SystemInfo) addWarning(section string, err error) {
+	s.Warnings = append(s.Warnings, SectionError{Section: section, Message: err.Error()})
+}
+
+// sortProblems orders errors and warnings by section so concurrent
+// collection produces stable output
+func (s *SystemInfo) sortProblems() {
+	order := make(map[string]int, len(s.Collectors))
+	for i, name := range s.Collectors {
+		order[name] = i
+	}
+	sort.SliceStable(s.Errors, func(i, j int) bool {
+		return order[s.Errors[i].Section] < order[s.Errors[j].Section]
+	})
+	sort.SliceStable(s.Warnings, func(i, j int) bool {
+		return order[s.Warnings[i].Section] < order[s.Warnings[j].Section]
+	})
+}
@@ -0,0 +1,272 @@
+package sysinfo
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultForecastWindow is how far back disk usage samples are kept for
+// forecasting when no window is given
+const DefaultForecastWindow = 7 * 24 * time.Hour
+
+// MinForecastConfidence is the confidence a prediction needs to be set on
+// DiskInfo
+const MinForecastConfidence = 0.5
+
+const (
+	// forecastSamples is the most samples kept per mountpoint, samples are
+	// spaced at least window/forecastSamples apart
+	forecastSamples = 500
+	// minForecastSamples is the fewest samples a prediction is made from
+	minForecastSamples = 3
+	// maxForecastAhead is the furthest ahead a mount is predicted to fill,
+	// slower growth counts as not filling up
+	maxForecastAhead = 10 * 365 * 24 * time.Hour
+)
+
+// DiskForecast is the predicted growth of a mountpoint's bytes and inodes
+type DiskForecast struct {
+	Mountpoint      string     `json:"mountpoint"`
+	UsedPercent     float64    `json:"used_percent"`
+	GrowthPerDay    float64    `json:"growth_bytes_per_day"`
+	PredictedFullAt *time.Time `json:"predicted_full_at"`
+	// Confidence from 0 to 1 is how well a line fits the samples, reduced
+	// when the prediction reaches further ahead than the samples go back
+	Confidence            float64    `json:"confidence"`
+	InodesGrowthPerDay    float64    `json:"inodes_growth_per_day"`
+	InodesPredictedFullAt *time.Time `json:"inodes_predicted_full_at"`
+	InodesConfidence      float64    `json:"inodes_confidence"`
+	Samples               int        `json:"samples"`
+	Since                 *time.Time `json:"since"`
+}
+
+// diskSample is the usage of one mountpoint at one time
+type diskSample struct {
+	Time        int64  `json:"t"` // unix seconds
+	Used        uint64 `json:"used"`
+	Capacity    uint64 `json:"capacity"` // used + free, the usable size
+	InodesUsed  uint64 `json:"inodes_used"`
+	InodesTotal uint64 `json:"inodes_total"`
+}
+
+// DiskForecaster keeps a window of usage samples per mountpoint and
+// predicts when each mount fills up by fitting a line to them. It is fed
+// by disk collections once set with SetDiskForecaster.
+type DiskForecaster struct {
+	window time.Duration
+	path   string
+
+	mu      sync.Mutex
+	samples map[string][]diskSample
+}
+
+var (
+	forecasterMu     sync.RWMutex
+	activeForecaster *DiskForecaster
+)
+
+// NewDiskForecaster creates a forecaster keeping samples for window. With a
+// path the samples are loaded from and saved to that file, so forecasts
+// carry over between runs; an unreadable file is started over.
+func NewDiskForecaster(window time.Duration, path string) *DiskForecaster {
+	if window <= 0 {
+		window = DefaultForecastWindow
+	}
+	f := &DiskForecaster{window: window, path: path, samples: make(map[string][]diskSample)}
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			var state struct {
+				Mounts map[string][]diskSample `json:"mounts"`
+			}
+			if json.Unmarshal(data, &state) == nil && state.Mounts != nil {
+				f.samples = state.Mounts
+			}
+		}
+	}
+	return f
+}
+
+// SetDiskForecaster makes disk collections record samples into f and set
+// the predictions on DiskInfo. A nil f turns forecasting off.
+func SetDiskForecaster(f *DiskForecaster) {
+	forecasterMu.Lock()
+	defer forecasterMu.Unlock()
+	activeForecaster = f
+}
+
+func diskForecaster() *DiskForecaster {
+	forecasterMu.RLock()
+	defer forecasterMu.RUnlock()
+	return activeForecaster
+}
+
+// Observe records the usage of each disk at t and drops samples that fell
+// out of the window. Mounts sampled less than window/500 ago are skipped.
+func (f *DiskForecaster) Observe(t time.Time, disks []DiskInfo) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := t.Unix()
+	spacing := int64((f.window / forecastSamples).Seconds())
+	oldest := now - int64(f.window.Seconds())
+	changed := false
+	for _, d := range disks {
+		samples := f.samples[d.Mountpoint]
+		if n := len(samples); n > 0 && now-samples[n-1].Time < spacing {
+			continue
+		}
+		samples = append(samples, diskSample{
+			Time:        now,
+			Used:        d.Used,
+			Capacity:    d.Used + d.Free,
+			InodesUsed:  d.InodesUsed,
+			InodesTotal: d.InodesTotal,
+		})
+		f.samples[d.Mountpoint] = samples
+		changed = true
+	}
+	for mount, samples := range f.samples {
+		i := 0
+		for i < len(samples) && samples[i].Time < oldest {
+			i++
+		}
+		if i == len(samples) {
+			delete(f.samples, mount)
+		} else if i > 0 {
+			f.samples[mount] = append([]diskSample(nil), samples[i:]...)
+		}
+		changed = changed || i > 0
+	}
+
+	if !changed || f.path == "" {
+		return nil
+	}
+	return f.save()
+}
+
+// save writes the samples to the state file, replacing it atomically
+func (f *DiskForecaster) save() error {
+	data, err := json.Marshal(map[string]interface{}{"mounts": f.samples})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// Forecast predicts when each of the disks fills up
+func (f *DiskForecaster) Forecast(disks []DiskInfo) []DiskForecast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	forecasts := make([]DiskForecast, 0, len(disks))
+	for _, d := range disks {
+		fc := DiskForecast{Mountpoint: d.Mountpoint, UsedPercent: d.UsedPercent}
+		samples := f.samples[d.Mountpoint]
+		fc.Samples = len(samples)
+		if len(samples) > 0 {
+			since := time.Unix(samples[0].Time, 0)
+			fc.Since = &since
+		}
+		fc.GrowthPerDay, fc.PredictedFullAt, fc.Confidence = predictFull(samples, func(s diskSample) (float64, float64) {
+			return float64(s.Used), float64(s.Capacity)
+		})
+		if d.InodesTotal > 0 {
+			fc.InodesGrowthPerDay, fc.InodesPredictedFullAt, fc.InodesConfidence = predictFull(samples, func(s diskSample) (float64, float64) {
+				return float64(s.InodesUsed), float64(s.InodesTotal)
+			})
+		}
+		forecasts = append(forecasts, fc)
+	}
+	return forecasts
+}
+
+// annotate sets the predictions confident enough on the disks
+func (f *DiskForecaster) annotate(disks []DiskInfo) {
+	for i, fc := range f.Forecast(disks) {
+		if fc.Confidence >= MinForecastConfidence {
+			disks[i].PredictedFullAt = fc.PredictedFullAt
+		}
+		if fc.InodesConfidence >= MinForecastConfidence {
+			disks[i].InodesPredictedFullAt = fc.InodesPredictedFullAt
+		}
+	}
+}
+
+// predictFull fits a line to the used values of the samples and returns the
+// growth per day and, if it is growing, when used reaches capacity and how
+// confident that prediction is
+func predictFull(samples []diskSample, value func(diskSample) (used, capacity float64)) (float64, *time.Time, float64) {
+	n := len(samples)
+	if n < minForecastSamples {
+		return 0, nil, 0
+	}
+	first, last := samples[0].Time, samples[n-1].Time
+	span := float64(last - first)
+	if span <= 0 {
+		return 0, nil, 0
+	}
+
+	xs := make([]float64, n)
+	ys := make([]float64, n)
+	for i, s := range samples {
+		xs[i] = float64(s.Time - first)
+		ys[i], _ = value(s)
+	}
+	slope, r2 := fitLine(xs, ys)
+	growth := slope * 86400
+	if slope <= 0 {
+		return growth, nil, 0
+	}
+
+	used, capacity := value(samples[n-1])
+	remaining := math.Max(capacity-used, 0)
+	ahead := remaining / slope
+	if ahead > maxForecastAhead.Seconds() {
+		return growth, nil, 0
+	}
+	full := time.Unix(last, 0).Add(time.Duration(ahead * float64(time.Second)))
+	confidence := r2
+	if ahead > span {
+		confidence *= span / ahead
+	}
+	return growth, &full, confidence
+}
+
+// fitLine returns the least squares slope of ys over xs and the coefficient
+// of determination of the fit
+func fitLine(xs, ys []float64) (slope, r2 float64) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+	slope = sxy / sxx
+	if syy == 0 {
+		return slope, 1
+	}
+	return slope, sxy * sxy / (sxx * syy)
+}
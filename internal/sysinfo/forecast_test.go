@@ -0,0 +1,112 @@
+package sysinfo
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const gb = 1 << 30
+
+func TestDiskForecasterPredictsFull(t *testing.T) {
+	f := NewDiskForecaster(10*24*time.Hour, "")
+	start := time.Unix(1700000000, 0)
+
+	// 1 GB a day on a 100 GB disk, with inodes flat
+	for day := 0; day <= 5; day++ {
+		used := uint64(50+day) * gb
+		disks := []DiskInfo{{Mountpoint: "/data", Used: used, Free: 100*gb - used, InodesTotal: 1000, InodesUsed: 100}}
+		if err := f.Observe(start.Add(time.Duration(day)*24*time.Hour), disks); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current := []DiskInfo{{Mountpoint: "/data", Used: 55 * gb, Free: 45 * gb, InodesTotal: 1000, InodesUsed: 100}, {Mountpoint: "/new"}}
+	got := f.Forecast(current)
+	fc := got[0]
+	if math.Abs(fc.GrowthPerDay-gb) > 1 {
+		t.Errorf("growth = %v bytes/day, want 1 GB", fc.GrowthPerDay)
+	}
+	want := start.Add(50 * 24 * time.Hour)
+	if fc.PredictedFullAt == nil || fc.PredictedFullAt.Sub(want).Abs() > time.Minute {
+		t.Errorf("predicted full at %v, want %v", fc.PredictedFullAt, want)
+	}
+	// A perfect fit, but 45 days ahead from 5 days of samples
+	if fc.Confidence < 0.1 || fc.Confidence > 0.12 {
+		t.Errorf("confidence = %v, want 5/45", fc.Confidence)
+	}
+	if fc.InodesPredictedFullAt != nil || fc.Samples != 6 {
+		t.Errorf("inodes full at %v from %d samples", fc.InodesPredictedFullAt, fc.Samples)
+	}
+	if got[1].Samples != 0 || got[1].PredictedFullAt != nil {
+		t.Errorf("mount without samples: %+v", got[1])
+	}
+
+	// Too unsure to be set on DiskInfo
+	f.annotate(current)
+	if current[0].PredictedFullAt != nil {
+		t.Errorf("low confidence prediction set: %v", current[0].PredictedFullAt)
+	}
+}
+
+func TestDiskForecasterAnnotatesConfident(t *testing.T) {
+	f := NewDiskForecaster(24*time.Hour, "")
+	start := time.Unix(1700000000, 0)
+
+	// Filling 5% every 10 minutes with some noise
+	for i, used := range []uint64{50, 55, 61, 65, 70, 76, 80} {
+		disks := []DiskInfo{{Mountpoint: "/", Used: used * gb, Free: (100 - used) * gb}}
+		f.Observe(start.Add(time.Duration(i)*10*time.Minute), disks)
+	}
+	disks := []DiskInfo{{Mountpoint: "/", Used: 80 * gb, Free: 20 * gb}}
+	f.annotate(disks)
+	if disks[0].PredictedFullAt == nil {
+		t.Fatal("no prediction set")
+	}
+	if ahead := disks[0].PredictedFullAt.Sub(start.Add(time.Hour)); ahead < 35*time.Minute || ahead > 45*time.Minute {
+		t.Errorf("full %v after the last sample, want about 40m", ahead)
+	}
+}
+
+func TestDiskForecasterWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forecast", "state.json")
+	f := NewDiskForecaster(500*time.Minute, path)
+	start := time.Unix(1700000000, 0)
+	disks := []DiskInfo{{Mountpoint: "/", Used: gb, Free: gb}}
+
+	f.Observe(start, disks)
+	// Closer than window/500 to the last sample
+	f.Observe(start.Add(30*time.Second), disks)
+	f.Observe(start.Add(2*time.Minute), disks)
+	if n := len(f.samples["/"]); n != 2 {
+		t.Errorf("kept %d samples, want 2", n)
+	}
+
+	// Samples persist across forecasters and expire with the window
+	g := NewDiskForecaster(500*time.Minute, path)
+	if n := len(g.samples["/"]); n != 2 {
+		t.Fatalf("loaded %d samples, want 2", n)
+	}
+	g.Observe(start.Add(501*time.Minute), disks)
+	if n := len(g.samples["/"]); n != 2 {
+		t.Errorf("%d samples after expiry, want 2", n)
+	}
+	g.Observe(start.Add(2000*time.Minute), nil)
+	if _, ok := g.samples["/"]; ok {
+		t.Error("mount with only expired samples kept")
+	}
+}
+
+func TestDiskForecasterShrinking(t *testing.T) {
+	f := NewDiskForecaster(time.Hour, "")
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		used := uint64(60-i) * gb
+		f.Observe(start.Add(time.Duration(i)*time.Minute), []DiskInfo{{Mountpoint: "/", Used: used, Free: 100*gb - used}})
+	}
+	fc := f.Forecast([]DiskInfo{{Mountpoint: "/"}})[0]
+	if fc.PredictedFullAt != nil || fc.GrowthPerDay >= 0 {
+		t.Errorf("shrinking disk: growth %v, full at %v", fc.GrowthPerDay, fc.PredictedFullAt)
+	}
+}
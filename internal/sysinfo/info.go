@@ -1,6 +1,7 @@
 package sysinfo
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"time"
@@ -15,24 +16,28 @@ import (
 )
 
 type SystemInfo struct {
-	OS             string          `json:"os"`
-	Hostname       string          `json:"hostname"`
-	Uptime         string          `json:"uptime"`
-	CPU            CPUInfo         `json:"cpu"`
-	Memory         MemoryInfo      `json:"memory"`
-	Swap           SwapInfo        `json:"swap"`
-	Disk           []DiskInfo      `json:"disk"`
-	Network        NetworkInfo     `json:"network"`
-	LoadAverage    LoadAverageInfo `json:"load_average"`
-	ProcessCount   uint64          `json:"process_count"`
-	Architecture   string          `json:"architecture"`
-	KernelVersion  string          `json:"kernel_version"`
-	LastBoot       string          `json:"last_boot"`
-	TopProcesses   []ProcessInfo   `json:"top_processes"`
-	Temperature    TemperatureInfo `json:"temperature"`
-	IOStats        IOStatsInfo     `json:"io_stats"`
-	Users          []UserInfo      `json:"users"`
-	SystemServices []ServiceInfo   `json:"system_services"`
+	OS             string                 `json:"os"`
+	Hostname       string                 `json:"hostname"`
+	Uptime         string                 `json:"uptime"`
+	CPU            CPUInfo                `json:"cpu"`
+	Memory         MemoryInfo             `json:"memory"`
+	Swap           SwapInfo               `json:"swap"`
+	Disk           []DiskInfo             `json:"disk"`
+	Network        NetworkInfo            `json:"network"`
+	LoadAverage    LoadAverageInfo        `json:"load_average"`
+	ProcessCount   uint64                 `json:"process_count"`
+	Architecture   string                 `json:"architecture"`
+	KernelVersion  string                 `json:"kernel_version"`
+	LastBoot       string                 `json:"last_boot"`
+	TopProcesses   []ProcessInfo          `json:"top_processes"`
+	Temperature    TemperatureInfo        `json:"temperature"`
+	IOStats        IOStatsInfo            `json:"io_stats"`
+	Users          []UserInfo             `json:"users"`
+	SystemServices []ServiceInfo          `json:"system_services"`
+	Extra          map[string]interface{} `json:"extra,omitempty"`
+	Collectors     []string               `json:"collectors"`
+	Errors         []SectionError         `json:"errors,omitempty"`
+	Warnings       []SectionError         `json:"warnings,omitempty"`
 }
 
 type CPUInfo struct {
@@ -76,6 +81,9 @@ type DiskInfo struct {
 	InodesTotal uint64  `json:"inodes_total"`
 	InodesUsed  uint64  `json:"inodes_used"`
 	InodesFree  uint64  `json:"inodes_free"`
+	// Set when a DiskForecaster predicts the mount fills up
+	PredictedFullAt       *time.Time `json:"predicted_full_at,omitempty"`
+	InodesPredictedFullAt *time.Time `json:"inodes_predicted_full_at,omitempty"`
 }
 
 type NetworkInfo struct {
@@ -104,11 +112,15 @@ type LoadAverageInfo struct {
 	Load15 float64 `json:"load15"`
 }
 
+// ProcessInfo describes a process. CPUPercent is its average usage of one
+// CPU over its lifetime; CPUTime is the CPU seconds it used so far, to work
+// out its usage over an interval from two snapshots.
 type ProcessInfo struct {
 	PID         int32   `json:"pid"`
 	Name        string  `json:"name"`
 	Status      string  `json:"status"`
 	CPUPercent  float64 `json:"cpu_percent"`
+	CPUTime     float64 `json:"cpu_time"`
 	MemPercent  float32 `json:"mem_percent"`
 	MemoryRSS   uint64  `json:"memory_rss"`
 	MemoryVMS   uint64  `json:"memory_vms"`
@@ -124,8 +136,12 @@ type TemperatureInfo struct {
 	ThermalZone map[string]float64 `json:"thermal_zone"`
 }
 
+// SensorInfo is a hwmon temperature sensor. Name is the chip and sensor
+// label, which repeats for identical chips such as two NVMe drives; Device
+// is the hwmon directory that tells them apart.
 type SensorInfo struct {
 	Name        string  `json:"name"`
+	Device      string  `json:"device"`
 	Temperature float64 `json:"temperature"`
 	High        float64 `json:"high"`
 	Critical    float64 `json:"critical"`
@@ -153,60 +169,124 @@ type ServiceInfo struct {
 	PID    int32  `json:"pid"`
 }
 
-func GetSystemInfo() (*SystemInfo, error) {
-	// Host Info
-	hostInfo, err := host.Info()
+func collectHost(ctx context.Context, info *SystemInfo) error {
+	hostInfo, err := host.InfoWithContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// CPU Info
-	cpuInfos, err := cpu.Info()
+	info.OS = fmt.Sprintf("%s %s", hostInfo.Platform, hostInfo.PlatformVersion)
+	info.Hostname = hostInfo.Hostname
+	info.Uptime = fmt.Sprintf("%.0f hours", float64(hostInfo.Uptime)/3600)
+	info.ProcessCount = hostInfo.Procs
+	info.Architecture = hostInfo.KernelArch
+	info.KernelVersion = hostInfo.KernelVersion
+	info.LastBoot = time.Unix(int64(hostInfo.BootTime), 0).Format("2006-01-02 15:04:05")
+	return nil
+}
+
+func collectCPU(ctx context.Context, info *SystemInfo) error {
+	cpuUsage, usageErr := getCPUUsage(ctx)
+	if usageErr != nil {
+		cpuUsage = []float64{}
+	}
+
+	cpuInfos, err := cpu.InfoWithContext(ctx)
 	if err != nil {
-		return nil, err
+		if usageErr != nil {
+			return err
+		}
+		// Usage is still meaningful without the model details
+		info.addWarning(SectionCPU, fmt.Errorf("cpu details unavailable: %w", err))
+		info.CPU.LogicalCores = int32(len(cpuUsage))
+		info.CPU.Usage = cpuUsage
+		return nil
+	}
+	if usageErr != nil {
+		info.addWarning(SectionCPU, fmt.Errorf("cpu usage unavailable: %w", usageErr))
+	}
+
+	if len(cpuInfos) > 0 {
+		info.CPU = CPUInfo{
+			ModelName:    cpuInfos[0].ModelName,
+			Cores:        cpuInfos[0].Cores,
+			LogicalCores: int32(len(cpuInfos)),
+			Usage:        cpuUsage,
+			Frequency:    cpuInfos[0].Mhz,
+			CacheSize:    cpuInfos[0].CacheSize,
+		}
 	}
+	return nil
+}
 
-	cpuUsage, err := cpu.Percent(time.Second, true)
+func collectMemory(ctx context.Context, info *SystemInfo) error {
+	memInfo, err := mem.VirtualMemoryWithContext(ctx)
 	if err != nil {
-		cpuUsage = []float64{}
+		return err
 	}
 
-	// Memory Info
-	memInfo, err := mem.VirtualMemory()
+	info.Memory = MemoryInfo{
+		Total:       memInfo.Total,
+		Available:   memInfo.Available,
+		Used:        memInfo.Used,
+		UsedPercent: memInfo.UsedPercent,
+		Free:        memInfo.Free,
+		Cached:      memInfo.Cached,
+		Buffers:     memInfo.Buffers,
+		Shared:      memInfo.Shared,
+		Active:      memInfo.Active,
+		Inactive:    memInfo.Inactive,
+	}
+	return nil
+}
+
+func collectSwap(ctx context.Context, info *SystemInfo) error {
+	swapInfo, err := mem.SwapMemoryWithContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Swap Info
-	swapInfo, err := mem.SwapMemory()
+	info.Swap = SwapInfo{
+		Total:       swapInfo.Total,
+		Used:        swapInfo.Used,
+		Free:        swapInfo.Free,
+		UsedPercent: swapInfo.UsedPercent,
+	}
+	return nil
+}
+
+func collectLoad(ctx context.Context, info *SystemInfo) error {
+	loadAvg, err := load.AvgWithContext(ctx)
 	if err != nil {
-		swapInfo = &mem.SwapMemoryStat{}
+		return err
 	}
 
-	// Load Average
-	loadAvg, err := load.Avg()
-	var loadAvgInfo LoadAverageInfo
-	if err == nil {
-		loadAvgInfo = LoadAverageInfo{
-			Load1:  loadAvg.Load1,
-			Load5:  loadAvg.Load5,
-			Load15: loadAvg.Load15,
-		}
+	info.LoadAverage = LoadAverageInfo{
+		Load1:  loadAvg.Load1,
+		Load5:  loadAvg.Load5,
+		Load15: loadAvg.Load15,
 	}
+	return nil
+}
 
-	// Disk Info
-	diskPartitions, err := disk.Partitions(false)
+func collectDisks(ctx context.Context, info *SystemInfo) error {
+	diskPartitions, err := disk.PartitionsWithContext(ctx, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var diskInfos []DiskInfo
-	for _, partition := range diskPartitions {
-		usage, err := disk.Usage(partition.Mountpoint)
+	for i, partition := range diskPartitions {
+		usage, err := diskUsage(ctx, partition.Mountpoint)
 		if err != nil {
+			if ctx.Err() != nil {
+				// A hung mount (e.g. stale NFS) ate the deadline; skip the rest
+				info.addWarning(SectionDisk, fmt.Errorf("%s: %w, %d mounts skipped", partition.Mountpoint, err, len(diskPartitions)-i-1))
+				break
+			}
+			info.addWarning(SectionDisk, fmt.Errorf("%s: %w", partition.Mountpoint, err))
 			continue
 		}
-		diskInfos = append(diskInfos, DiskInfo{
+		info.Disk = append(info.Disk, DiskInfo{
 			Device:      partition.Device,
 			Mountpoint:  partition.Mountpoint,
 			Fstype:      partition.Fstype,
@@ -220,19 +300,44 @@ func GetSystemInfo() (*SystemInfo, error) {
 		})
 	}
 
-	// Network Info
-	netInterfaces, err := net.Interfaces()
-	if err != nil {
-		return nil, err
+	if f := diskForecaster(); f != nil {
+		if err := f.Observe(time.Now(), info.Disk); err != nil {
+			info.addWarning(SectionDisk, fmt.Errorf("forecast: %w", err))
+		}
+		f.annotate(info.Disk)
+	}
+	return nil
+}
+
+// diskUsage runs disk.Usage in its own goroutine because statfs on a stale
+// network mount blocks in the kernel and ignores ctx
+func diskUsage(ctx context.Context, mountpoint string) (*disk.UsageStat, error) {
+	type result struct {
+		usage *disk.UsageStat
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		usage, err := disk.UsageWithContext(ctx, mountpoint)
+		done <- result{usage, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.usage, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
+}
 
-	var networkInterfaces []NetworkInterface
+func collectNetwork(ctx context.Context, info *SystemInfo) error {
+	netInterfaces, ifaceErr := net.InterfacesWithContext(ctx)
 	for _, iface := range netInterfaces {
 		var addresses []string
 		for _, addr := range iface.Addrs {
 			addresses = append(addresses, addr.Addr)
 		}
-		networkInterfaces = append(networkInterfaces, NetworkInterface{
+		info.Network.Interfaces = append(info.Network.Interfaces, NetworkInterface{
 			Name:         iface.Name,
 			Addresses:    addresses,
 			MTU:          iface.MTU,
@@ -241,100 +346,82 @@ func GetSystemInfo() (*SystemInfo, error) {
 		})
 	}
 
-	netIO, err := net.IOCounters(false)
-	var bytesSent, bytesRecv, packetsSent, packetsRecv, errorsIn, errorsOut, dropsIn, dropsOut uint64
+	netIO, err := net.IOCountersWithContext(ctx, false)
 	if err == nil && len(netIO) > 0 {
-		bytesSent = netIO[0].BytesSent
-		bytesRecv = netIO[0].BytesRecv
-		packetsSent = netIO[0].PacketsSent
-		packetsRecv = netIO[0].PacketsRecv
-		errorsIn = netIO[0].Errin
-		errorsOut = netIO[0].Errout
-		dropsIn = netIO[0].Dropin
-		dropsOut = netIO[0].Dropout
+		info.Network.BytesSent = netIO[0].BytesSent
+		info.Network.BytesRecv = netIO[0].BytesRecv
+		info.Network.PacketsSent = netIO[0].PacketsSent
+		info.Network.PacketsRecv = netIO[0].PacketsRecv
+		info.Network.ErrorsIn = netIO[0].Errin
+		info.Network.ErrorsOut = netIO[0].Errout
+		info.Network.DropsIn = netIO[0].Dropin
+		info.Network.DropsOut = netIO[0].Dropout
 	}
 
-	// Get top processes
-	topProcesses := getTopProcesses(10)
+	switch {
+	case ifaceErr != nil && err != nil:
+		return ifaceErr
+	case ifaceErr != nil:
+		info.addWarning(SectionNetwork, fmt.Errorf("interfaces unavailable: %w", ifaceErr))
+	case err != nil:
+		info.addWarning(SectionNetwork, fmt.Errorf("io counters unavailable: %w", err))
+	}
+	return nil
+}
 
-	// Get temperature info
-	tempInfo := getTemperatureInfo()
+func collectProcesses(ctx context.Context, info *SystemInfo) error {
+	processes, err := getTopProcesses(ctx, 10)
+	if err != nil {
+		return err
+	}
+	info.TopProcesses = processes
+	return nil
+}
 
-	// Get IO stats
-	ioStats := getIOStats()
+func collectTemperature(ctx context.Context, info *SystemInfo) error {
+	info.Temperature = getTemperatureInfo()
+	info.CPU.Temperature = info.Temperature.CPUTemp
+	return nil
+}
 
-	// Get users
-	users := getLoggedInUsers()
+func collectIOStats(ctx context.Context, info *SystemInfo) error {
+	ioStats, err := getIOStats(ctx)
+	if err != nil {
+		return err
+	}
+	info.IOStats = ioStats
+	return nil
+}
 
-	// Get system services (basic implementation)
-	services := getSystemServices()
+func collectUsers(ctx context.Context, info *SystemInfo) error {
+	users, err := getLoggedInUsers(ctx)
+	if err != nil {
+		return err
+	}
+	info.Users = users
+	return nil
+}
 
-	var cpuInfo CPUInfo
-	if len(cpuInfos) > 0 {
-		cpuInfo = CPUInfo{
-			ModelName:    cpuInfos[0].ModelName,
-			Cores:        cpuInfos[0].Cores,
-			LogicalCores: int32(len(cpuInfos)),
-			Usage:        cpuUsage,
-			Frequency:    cpuInfos[0].Mhz,
-			CacheSize:    cpuInfos[0].CacheSize,
-			Temperature:  getCPUTemperature(),
-		}
+func collectServices(ctx context.Context, info *SystemInfo) error {
+	services, err := getSystemServices(ctx)
+	if err != nil {
+		return err
 	}
+	info.SystemServices = services
+	return nil
+}
 
-	return &SystemInfo{
-		OS:       fmt.Sprintf("%s %s", hostInfo.Platform, hostInfo.PlatformVersion),
-		Hostname: hostInfo.Hostname,
-		Uptime:   fmt.Sprintf("%.0f hours", float64(hostInfo.Uptime)/3600),
-		CPU:      cpuInfo,
-		Memory: MemoryInfo{
-			Total:       memInfo.Total,
-			Available:   memInfo.Available,
-			Used:        memInfo.Used,
-			UsedPercent: memInfo.UsedPercent,
-			Free:        memInfo.Free,
-			Cached:      memInfo.Cached,
-			Buffers:     memInfo.Buffers,
-			Shared:      memInfo.Shared,
-			Active:      memInfo.Active,
-			Inactive:    memInfo.Inactive,
-		},
-		Swap: SwapInfo{
-			Total:       swapInfo.Total,
-			Used:        swapInfo.Used,
-			Free:        swapInfo.Free,
-			UsedPercent: swapInfo.UsedPercent,
-		},
-		Disk: diskInfos,
-		Network: NetworkInfo{
-			Interfaces:  networkInterfaces,
-			BytesSent:   bytesSent,
-			BytesRecv:   bytesRecv,
-			PacketsSent: packetsSent,
-			PacketsRecv: packetsRecv,
-			ErrorsIn:    errorsIn,
-			ErrorsOut:   errorsOut,
-			DropsIn:     dropsIn,
-			DropsOut:    dropsOut,
-		},
-		LoadAverage:    loadAvgInfo,
-		ProcessCount:   hostInfo.Procs,
-		Architecture:   hostInfo.KernelArch,
-		KernelVersion:  hostInfo.KernelVersion,
-		LastBoot:       time.Unix(int64(hostInfo.BootTime), 0).Format("2006-01-02 15:04:05"),
-		TopProcesses:   topProcesses,
-		Temperature:    tempInfo,
-		IOStats:        ioStats,
-		Users:          users,
-		SystemServices: services,
-	}, nil
+// GetProcesses returns every process sorted by CPU usage
+func GetProcesses(ctx context.Context) ([]ProcessInfo, error) {
+	return getTopProcesses(ctx, 0)
 }
 
-// getTopProcesses returns the top N processes by CPU usage
-func getTopProcesses(limit int) []ProcessInfo {
-	processes, err := process.Processes()
+// getTopProcesses returns the top N processes by CPU usage, or all of them
+// when limit is 0
+func getTopProcesses(ctx context.Context, limit int) ([]ProcessInfo, error) {
+	processes, err := process.ProcessesWithContext(ctx)
 	if err != nil {
-		return []ProcessInfo{}
+		return []ProcessInfo{}, err
 	}
 
 	var procInfos []ProcessInfo
@@ -342,14 +429,22 @@ func getTopProcesses(limit int) []ProcessInfo {
 		// Get process info
 		name, _ := p.Name()
 		status, _ := p.Status()
-		cpuPercent, _ := p.CPUPercent()
+		createTime, _ := p.CreateTime()
 		memPercent, _ := p.MemoryPercent()
 		memInfo, _ := p.MemoryInfo()
-		createTime, _ := p.CreateTime()
 		numThreads, _ := p.NumThreads()
 		username, _ := p.Username()
 		cmdline, _ := p.Cmdline()
 
+		// Worked out like Process.CPUPercent, without reading the times twice
+		var cpuTime, cpuPercent float64
+		if times, err := p.Times(); err == nil {
+			cpuTime = times.Total()
+			if lifetime := time.Since(time.UnixMilli(createTime)).Seconds(); createTime > 0 && lifetime > 0 {
+				cpuPercent = 100 * cpuTime / lifetime
+			}
+		}
+
 		var memRSS, memVMS uint64
 		if memInfo != nil {
 			memRSS = memInfo.RSS
@@ -367,6 +462,7 @@ func getTopProcesses(limit int) []ProcessInfo {
 			Name:        name,
 			Status:      statusStr,
 			CPUPercent:  cpuPercent,
+			CPUTime:     cpuTime,
 			MemPercent:  memPercent,
 			MemoryRSS:   memRSS,
 			MemoryVMS:   memVMS,
@@ -384,42 +480,23 @@ func getTopProcesses(limit int) []ProcessInfo {
 	})
 
 	// Return top N processes
-	if len(procInfos) > limit {
-		return procInfos[:limit]
+	if limit > 0 && len(procInfos) > limit {
+		return procInfos[:limit], nil
 	}
-	return procInfos
+	return procInfos, nil
 }
 
-// getTemperatureInfo collects temperature information from various sensors
+// getTemperatureInfo collects temperature information from thermal zones
+// and hwmon sensors exposed through sysfs
 func getTemperatureInfo() TemperatureInfo {
-	tempInfo := TemperatureInfo{
-		CPUTemp:     getCPUTemperature(),
-		Sensors:     []SensorInfo{},
-		ThermalZone: make(map[string]float64),
-	}
-
-	// Try to get sensor temperatures (this may not work on all systems)
-	// gopsutil doesn't have direct temperature support, so we'll return empty for now
-	// In a real implementation, you might read from /sys/class/thermal/ on Linux
-	// or use platform-specific APIs
-
-	return tempInfo
-}
-
-// getCPUTemperature attempts to get CPU temperature
-func getCPUTemperature() float64 {
-	// This is a placeholder - actual implementation would depend on the platform
-	// On Linux, you might read from /sys/class/thermal/thermal_zone*/temp
-	// On macOS, you might use IOKit
-	// For now, return 0 to indicate unavailable
-	return 0.0
+	return readTemperatures(SysfsRoot)
 }
 
 // getIOStats collects disk I/O statistics
-func getIOStats() IOStatsInfo {
-	ioCounters, err := disk.IOCounters()
+func getIOStats(ctx context.Context) (IOStatsInfo, error) {
+	ioCounters, err := disk.IOCountersWithContext(ctx)
 	if err != nil {
-		return IOStatsInfo{}
+		return IOStatsInfo{}, err
 	}
 
 	var totalReadBytes, totalWriteBytes, totalReadCount, totalWriteCount, totalReadTime, totalWriteTime uint64
@@ -440,14 +517,14 @@ func getIOStats() IOStatsInfo {
 		DiskWriteCount: totalWriteCount,
 		DiskReadTime:   totalReadTime,
 		DiskWriteTime:  totalWriteTime,
-	}
+	}, nil
 }
 
 // getLoggedInUsers returns information about currently logged in users
-func getLoggedInUsers() []UserInfo {
-	users, err := host.Users()
+func getLoggedInUsers(ctx context.Context) ([]UserInfo, error) {
+	users, err := host.UsersWithContext(ctx)
 	if err != nil {
-		return []UserInfo{}
+		return []UserInfo{}, err
 	}
 
 	var userInfos []UserInfo
@@ -460,11 +537,11 @@ func getLoggedInUsers() []UserInfo {
 		})
 	}
 
-	return userInfos
+	return userInfos, nil
 }
 
 // getSystemServices returns basic information about system services
-func getSystemServices() []ServiceInfo {
+func getSystemServices(ctx context.Context) ([]ServiceInfo, error) {
 	// This is a basic implementation that shows some common processes
 	// A more complete implementation would interact with systemd on Linux,
 	// launchd on macOS, or Windows services on Windows
@@ -472,9 +549,9 @@ func getSystemServices() []ServiceInfo {
 	services := []ServiceInfo{}
 
 	// Get all processes and filter for common system services
-	processes, err := process.Processes()
+	processes, err := process.ProcessesWithContext(ctx)
 	if err != nil {
-		return services
+		return services, err
 	}
 
 	systemProcessNames := map[string]bool{
@@ -526,5 +603,5 @@ func getSystemServices() []ServiceInfo {
 		}
 	}
 
-	return services
+	return services, nil
 }
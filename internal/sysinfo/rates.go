@@ -0,0 +1,89 @@
+package sysinfo
+
+import "time"
+
+// Rates are the per-second rates and deltas of the cumulative counters in two
+// consecutive snapshots
+type Rates struct {
+	Elapsed time.Duration
+
+	// Network is set when both snapshots collected network counters
+	Network bool
+	// Bytes and packets per second
+	NetSent        float64
+	NetRecv        float64
+	NetPacketsSent float64
+	NetPacketsRecv float64
+	// New errors and drops since the previous snapshot
+	NetErrorsIn  uint64
+	NetErrorsOut uint64
+	NetDropsIn   uint64
+	NetDropsOut  uint64
+
+	// IO is set when both snapshots collected disk I/O counters
+	IO bool
+	// Bytes and operations per second
+	DiskRead      float64
+	DiskWrite     float64
+	DiskReadIOPS  float64
+	DiskWriteIOPS float64
+	// Average time per operation in the interval, in milliseconds
+	DiskReadAwait  float64
+	DiskWriteAwait float64
+}
+
+// NewRates computes the rates between prev and cur, taken elapsed apart.
+// Counters that went backwards (a reset or a removed device) count as zero.
+func NewRates(prev, cur *SystemInfo, elapsed time.Duration) Rates {
+	r := Rates{Elapsed: elapsed}
+	secs := elapsed.Seconds()
+	if prev == nil || cur == nil || secs <= 0 {
+		return r
+	}
+
+	if counted(prev, SectionNetwork) && counted(cur, SectionNetwork) {
+		p, c := prev.Network, cur.Network
+		r.Network = true
+		r.NetSent = float64(delta(p.BytesSent, c.BytesSent)) / secs
+		r.NetRecv = float64(delta(p.BytesRecv, c.BytesRecv)) / secs
+		r.NetPacketsSent = float64(delta(p.PacketsSent, c.PacketsSent)) / secs
+		r.NetPacketsRecv = float64(delta(p.PacketsRecv, c.PacketsRecv)) / secs
+		r.NetErrorsIn = delta(p.ErrorsIn, c.ErrorsIn)
+		r.NetErrorsOut = delta(p.ErrorsOut, c.ErrorsOut)
+		r.NetDropsIn = delta(p.DropsIn, c.DropsIn)
+		r.NetDropsOut = delta(p.DropsOut, c.DropsOut)
+	}
+
+	if counted(prev, SectionIO) && counted(cur, SectionIO) {
+		p, c := prev.IOStats, cur.IOStats
+		reads := delta(p.DiskReadCount, c.DiskReadCount)
+		writes := delta(p.DiskWriteCount, c.DiskWriteCount)
+		r.IO = true
+		r.DiskRead = float64(delta(p.DiskReadBytes, c.DiskReadBytes)) / secs
+		r.DiskWrite = float64(delta(p.DiskWriteBytes, c.DiskWriteBytes)) / secs
+		r.DiskReadIOPS = float64(reads) / secs
+		r.DiskWriteIOPS = float64(writes) / secs
+		r.DiskReadAwait = getAwait(delta(p.DiskReadTime, c.DiskReadTime), reads)
+		r.DiskWriteAwait = getAwait(delta(p.DiskWriteTime, c.DiskWriteTime), writes)
+	}
+	return r
+}
+
+// counted reports whether a snapshot holds valid counters for a section
+func counted(info *SystemInfo, section string) bool {
+	return info.Collected(section) && !info.SectionFailed(section)
+}
+
+func delta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+func getAwait(ms, ops uint64) float64 {
+	if ops == 0 {
+		return 0
+	}
+	return float64(ms) / float64(ops)
+}
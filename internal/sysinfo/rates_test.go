@@ -0,0 +1,64 @@
+package sysinfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRates(t *testing.T) {
+	prev := &SystemInfo{
+		Collectors: []string{SectionNetwork, SectionIO},
+		Network:    NetworkInfo{BytesSent: 1000, BytesRecv: 5000, PacketsRecv: 10, ErrorsIn: 3},
+		IOStats:    IOStatsInfo{DiskReadBytes: 4096, DiskReadCount: 10, DiskReadTime: 20, DiskWriteCount: 50},
+	}
+	cur := &SystemInfo{
+		Collectors: []string{SectionNetwork, SectionIO},
+		Network:    NetworkInfo{BytesSent: 3000, BytesRecv: 1000, PacketsRecv: 30, ErrorsIn: 5},
+		IOStats:    IOStatsInfo{DiskReadBytes: 4096 + 8192, DiskReadCount: 14, DiskReadTime: 40, DiskWriteCount: 50},
+	}
+
+	r := NewRates(prev, cur, 2*time.Second)
+	if !r.Network || !r.IO {
+		t.Fatalf("expected network and io rates, got %+v", r)
+	}
+	if r.NetSent != 1000 {
+		t.Errorf("NetSent = %v, want 1000", r.NetSent)
+	}
+	if r.NetRecv != 0 {
+		t.Errorf("NetRecv = %v, want 0 after a counter reset", r.NetRecv)
+	}
+	if r.NetPacketsRecv != 10 {
+		t.Errorf("NetPacketsRecv = %v, want 10", r.NetPacketsRecv)
+	}
+	if r.NetErrorsIn != 2 {
+		t.Errorf("NetErrorsIn = %v, want 2", r.NetErrorsIn)
+	}
+	if r.DiskRead != 4096 || r.DiskReadIOPS != 2 {
+		t.Errorf("DiskRead = %v B/s %v IOPS, want 4096 B/s 2 IOPS", r.DiskRead, r.DiskReadIOPS)
+	}
+	if r.DiskReadAwait != 5 {
+		t.Errorf("DiskReadAwait = %v, want 5", r.DiskReadAwait)
+	}
+	if r.DiskWriteIOPS != 0 || r.DiskWriteAwait != 0 {
+		t.Errorf("idle writes = %v IOPS %v ms, want 0", r.DiskWriteIOPS, r.DiskWriteAwait)
+	}
+}
+
+func TestNewRatesSkipsFailedSections(t *testing.T) {
+	prev := &SystemInfo{Collectors: []string{SectionNetwork, SectionIO}}
+	cur := &SystemInfo{
+		Collectors: []string{SectionNetwork, SectionIO},
+		Errors:     []SectionError{{Section: SectionIO, Message: "boom"}},
+	}
+
+	r := NewRates(prev, cur, time.Second)
+	if !r.Network {
+		t.Error("expected network rates")
+	}
+	if r.IO {
+		t.Error("expected no io rates when the io collector failed")
+	}
+	if r := NewRates(nil, cur, time.Second); r.Network || r.IO {
+		t.Error("expected no rates without a previous snapshot")
+	}
+}
@@ -0,0 +1,198 @@
+package sysinfo
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// DefaultSampleInterval is the CPU sampling interval used when none is given
+const DefaultSampleInterval = time.Second
+
+// CPUSampler keeps rolling per-core CPU usage in the background so callers
+// can read the latest sample without blocking for a measurement window
+type CPUSampler struct {
+	interval time.Duration
+
+	mu      sync.RWMutex
+	last    []cpu.TimesStat
+	usage   []float64
+	updated time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var (
+	samplerMu     sync.RWMutex
+	activeSampler *CPUSampler
+)
+
+// NewCPUSampler creates a sampler that refreshes every interval
+func NewCPUSampler(interval time.Duration) *CPUSampler {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	return &CPUSampler{interval: interval}
+}
+
+// Start begins background sampling. It is a no-op if already running.
+func (s *CPUSampler) Start() {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	// Take the baseline immediately so the first delta is ready after one interval
+	s.sample()
+	go s.run(s.stop, s.done)
+}
+
+// Stop halts background sampling and waits for the goroutine to exit
+func (s *CPUSampler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Usage returns the latest per-core usage and whether a sample is available
+func (s *CPUSampler) Usage() ([]float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.usage == nil {
+		return nil, false
+	}
+	usage := make([]float64, len(s.usage))
+	copy(usage, s.usage)
+	return usage, true
+}
+
+// Updated returns the time of the latest sample
+func (s *CPUSampler) Updated() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updated
+}
+
+func (s *CPUSampler) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *CPUSampler) sample() {
+	times, err := cpu.Times(true)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last != nil && len(s.last) == len(times) {
+		s.usage = cpuDeltas(s.last, times)
+		s.updated = time.Now()
+	}
+	s.last = times
+}
+
+// cpuDeltas computes per-core busy percentages between two time samples
+func cpuDeltas(prev, cur []cpu.TimesStat) []float64 {
+	usage := make([]float64, len(cur))
+	for i := range cur {
+		usage[i] = busyPercent(prev[i], cur[i])
+	}
+	return usage
+}
+
+// busyPercent mirrors the calculation gopsutil uses for cpu.Percent
+func busyPercent(t1, t2 cpu.TimesStat) float64 {
+	t1All, t1Busy := allBusy(t1)
+	t2All, t2Busy := allBusy(t2)
+
+	if t2Busy <= t1Busy {
+		return 0
+	}
+	if t2All <= t1All {
+		return 100
+	}
+	percent := (t2Busy - t1Busy) / (t2All - t1All) * 100
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+func allBusy(t cpu.TimesStat) (float64, float64) {
+	total := t.Total()
+	if runtime.GOOS == "linux" {
+		total -= t.Guest
+		total -= t.GuestNice
+	}
+	return total, total - t.Idle - t.Iowait
+}
+
+// StartCPUSampler starts a package-wide sampler that GetSystemInfo reads from
+// instead of blocking for a one-shot measurement
+func StartCPUSampler(interval time.Duration) *CPUSampler {
+	samplerMu.Lock()
+	defer samplerMu.Unlock()
+
+	if activeSampler != nil {
+		activeSampler.Stop()
+	}
+	activeSampler = NewCPUSampler(interval)
+	activeSampler.Start()
+	return activeSampler
+}
+
+// StopCPUSampler stops the package-wide sampler, restoring one-shot sampling
+func StopCPUSampler() {
+	samplerMu.Lock()
+	defer samplerMu.Unlock()
+
+	if activeSampler != nil {
+		activeSampler.Stop()
+		activeSampler = nil
+	}
+}
+
+// getCPUUsage returns the latest background sample if one is available,
+// otherwise it falls back to a blocking one-shot measurement
+func getCPUUsage(ctx context.Context) ([]float64, error) {
+	samplerMu.RLock()
+	sampler := activeSampler
+	samplerMu.RUnlock()
+
+	if sampler != nil {
+		if usage, ok := sampler.Usage(); ok {
+			return usage, nil
+		}
+	}
+	return cpu.PercentWithContext(ctx, time.Second, true)
+}
@@ -0,0 +1,52 @@
+package sysinfo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+func TestCPUDeltas(t *testing.T) {
+	prev := []cpu.TimesStat{
+		{CPU: "cpu0", User: 100, System: 50, Idle: 850},
+		{CPU: "cpu1", User: 10, Idle: 990},
+	}
+	cur := []cpu.TimesStat{
+		{CPU: "cpu0", User: 150, System: 75, Idle: 875},
+		{CPU: "cpu1", User: 10, Idle: 1090},
+	}
+
+	usage := cpuDeltas(prev, cur)
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 cores, got %d", len(usage))
+	}
+	if usage[0] != 75 {
+		t.Errorf("cpu0 usage = %.1f, want 75.0", usage[0])
+	}
+	if usage[1] != 0 {
+		t.Errorf("cpu1 usage = %.1f, want 0.0", usage[1])
+	}
+}
+
+func TestCPUSamplerUsage(t *testing.T) {
+	s := NewCPUSampler(50 * time.Millisecond)
+	if _, ok := s.Usage(); ok {
+		t.Fatal("expected no sample before Start")
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if usage, ok := s.Usage(); ok {
+			if len(usage) == 0 {
+				t.Fatal("sample has no cores")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("sampler produced no sample")
+}
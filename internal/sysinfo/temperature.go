@@ -0,0 +1,158 @@
+package sysinfo
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SysfsRoot is the mount point of sysfs. It can be pointed at a fixture
+// directory in tests.
+var SysfsRoot = "/sys"
+
+// cpuSensorChips are hwmon chip names that report the CPU package temperature
+var cpuSensorChips = []string{"coretemp", "k10temp", "zenpower", "cpu_thermal", "cpu-thermal"}
+
+// cpuSensorLabels are preferred labels within a CPU chip, in priority order
+var cpuSensorLabels = []string{"Package id 0", "Tdie", "Tctl", "Tccd1"}
+
+// cpuThermalZones are thermal zone types that track the CPU when hwmon is unavailable
+var cpuThermalZones = []string{"x86_pkg_temp", "cpu-thermal", "cpu_thermal", "soc_thermal", "acpitz"}
+
+type hwmonSensor struct {
+	chip  string
+	label string
+	SensorInfo
+}
+
+// readTemperatures collects thermal zones and hwmon sensors under root
+func readTemperatures(root string) TemperatureInfo {
+	tempInfo := TemperatureInfo{
+		Sensors:     []SensorInfo{},
+		ThermalZone: make(map[string]float64),
+	}
+
+	zones, _ := filepath.Glob(filepath.Join(root, "class", "thermal", "thermal_zone*"))
+	sort.Strings(zones)
+	for _, zone := range zones {
+		temp, ok := readMilliCelsius(filepath.Join(zone, "temp"))
+		if !ok {
+			continue
+		}
+		name := readTrimmed(filepath.Join(zone, "type"))
+		if _, exists := tempInfo.ThermalZone[name]; name == "" || exists {
+			name = filepath.Base(zone)
+		}
+		tempInfo.ThermalZone[name] = temp
+	}
+
+	sensors := readHwmonSensors(root)
+	for _, s := range sensors {
+		tempInfo.Sensors = append(tempInfo.Sensors, s.SensorInfo)
+	}
+
+	tempInfo.CPUTemp = pickCPUTemperature(sensors, tempInfo.ThermalZone)
+	return tempInfo
+}
+
+// readHwmonSensors reads every temp*_input under class/hwmon
+func readHwmonSensors(root string) []hwmonSensor {
+	var sensors []hwmonSensor
+
+	chips, _ := filepath.Glob(filepath.Join(root, "class", "hwmon", "hwmon*"))
+	sort.Strings(chips)
+	for _, dir := range chips {
+		chip := readTrimmed(filepath.Join(dir, "name"))
+		if chip == "" {
+			chip = filepath.Base(dir)
+		}
+
+		inputs, _ := filepath.Glob(filepath.Join(dir, "temp*_input"))
+		sort.Slice(inputs, func(i, j int) bool {
+			return sensorIndex(inputs[i]) < sensorIndex(inputs[j])
+		})
+		for _, input := range inputs {
+			temp, ok := readMilliCelsius(input)
+			if !ok {
+				continue
+			}
+			prefix := strings.TrimSuffix(input, "_input")
+			label := readTrimmed(prefix + "_label")
+			if label == "" {
+				label = filepath.Base(prefix)
+			}
+			high, _ := readMilliCelsius(prefix + "_max")
+			critical, _ := readMilliCelsius(prefix + "_crit")
+
+			sensors = append(sensors, hwmonSensor{
+				chip:  chip,
+				label: label,
+				SensorInfo: SensorInfo{
+					Name:        chip + " " + label,
+					Device:      filepath.Base(dir),
+					Temperature: temp,
+					High:        high,
+					Critical:    critical,
+				},
+			})
+		}
+	}
+
+	return sensors
+}
+
+// pickCPUTemperature chooses the sensor that best represents the CPU package
+func pickCPUTemperature(sensors []hwmonSensor, zones map[string]float64) float64 {
+	for _, chip := range cpuSensorChips {
+		for _, label := range cpuSensorLabels {
+			for _, s := range sensors {
+				if s.chip == chip && s.label == label {
+					return s.Temperature
+				}
+			}
+		}
+		// Fall back to the first sensor the chip exposes
+		for _, s := range sensors {
+			if s.chip == chip {
+				return s.Temperature
+			}
+		}
+	}
+
+	for _, zone := range cpuThermalZones {
+		if temp, ok := zones[zone]; ok {
+			return temp
+		}
+	}
+	return 0
+}
+
+// sensorIndex extracts N from a tempN_input path for numeric ordering
+func sensorIndex(path string) int {
+	name := strings.TrimPrefix(filepath.Base(path), "temp")
+	n, _ := strconv.Atoi(strings.TrimSuffix(name, "_input"))
+	return n
+}
+
+// readMilliCelsius reads a sysfs value in millidegrees and converts it to °C
+func readMilliCelsius(path string) (float64, bool) {
+	raw := readTrimmed(path)
+	if raw == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value / 1000, true
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,60 @@
+package sysinfo
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReadTemperatures(t *testing.T) {
+	info := readTemperatures(filepath.Join("testdata", "sys"))
+
+	if info.CPUTemp != 51 {
+		t.Errorf("CPUTemp = %.1f, want 51.0 from coretemp package sensor", info.CPUTemp)
+	}
+
+	wantZones := map[string]float64{
+		"acpitz":        27.8,
+		"x86_pkg_temp":  52,
+		"thermal_zone2": 29.8,
+	}
+	if len(info.ThermalZone) != len(wantZones) {
+		t.Fatalf("got %d thermal zones, want %d: %v", len(info.ThermalZone), len(wantZones), info.ThermalZone)
+	}
+	for zone, want := range wantZones {
+		if got := info.ThermalZone[zone]; got != want {
+			t.Errorf("ThermalZone[%q] = %.2f, want %.2f", zone, got, want)
+		}
+	}
+
+	wantSensors := []SensorInfo{
+		{Name: "nvme Composite", Device: "hwmon0", Temperature: 38.85, High: 81.85, Critical: 84.85},
+		{Name: "coretemp Package id 0", Device: "hwmon1", Temperature: 51, High: 100, Critical: 100},
+		{Name: "coretemp Core 0", Device: "hwmon1", Temperature: 49},
+		{Name: "coretemp temp10", Device: "hwmon1", Temperature: 47},
+	}
+	if len(info.Sensors) != len(wantSensors) {
+		t.Fatalf("got %d sensors, want %d: %+v", len(info.Sensors), len(wantSensors), info.Sensors)
+	}
+	for i, want := range wantSensors {
+		if info.Sensors[i] != want {
+			t.Errorf("Sensors[%d] = %+v, want %+v", i, info.Sensors[i], want)
+		}
+	}
+}
+
+func TestReadTemperaturesFallsBackToThermalZone(t *testing.T) {
+	zones := map[string]float64{"acpitz": 30, "x86_pkg_temp": 45}
+	if got := pickCPUTemperature(nil, zones); got != 45 {
+		t.Errorf("CPU temperature = %.1f, want 45.0 from x86_pkg_temp", got)
+	}
+}
+
+func TestReadTemperaturesMissingRoot(t *testing.T) {
+	info := readTemperatures(filepath.Join("testdata", "missing"))
+	if info.CPUTemp != 0 || len(info.Sensors) != 0 || len(info.ThermalZone) != 0 {
+		t.Errorf("expected empty temperature info, got %+v", info)
+	}
+	if info.Sensors == nil || info.ThermalZone == nil {
+		t.Error("Sensors and ThermalZone should be non-nil for JSON output")
+	}
+}
@@ -0,0 +1,168 @@
+package webserver
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/junler/sysinfo/internal/auth"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// identityKey stores the caller's auth.Identity in the gin context
+const identityKey = "identity"
+
+// authEnabled reports whether requests must authenticate
+func (ws *WebServer) authEnabled() bool {
+	return len(ws.authenticators) > 0
+}
+
+// identify returns who sent r, trying the login session first
+func (ws *WebServer) identify(r *http.Request) (auth.Identity, bool) {
+	if id, ok := ws.sessions.Authenticate(r); ok {
+		return id, true
+	}
+	for _, a := range ws.authenticators {
+		if id, ok := a.Authenticate(r); ok {
+			return id, true
+		}
+	}
+	return auth.Identity{}, false
+}
+
+// authorize lets through callers with the needed role. Pages send
+// unauthenticated visitors to the login page, API requests get a 401.
+func (ws *WebServer) authorize(need auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ws.authEnabled() {
+			return
+		}
+		id, ok := ws.identify(c.Request)
+		if !ok {
+			ws.unauthorized(c)
+			return
+		}
+		if !id.Role.Allows(need) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this needs the " + string(need) + " role"})
+			return
+		}
+		c.Set(identityKey, id)
+	}
+}
+
+func (ws *WebServer) unauthorized(c *gin.Context) {
+	path := c.Request.URL.Path
+	if ws.loginEnabled() && !strings.HasPrefix(path, "/api/") && path != "/metrics" {
+		c.Redirect(http.StatusSeeOther, "/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+		c.Abort()
+		return
+	}
+	// Ask for basic auth unless the dashboard's session merely expired,
+	// which would pop up the browser's password dialog
+	if _, err := c.Cookie(auth.SessionCookie); err != nil && ws.basicAuth() {
+		c.Header("WWW-Authenticate", `Basic realm="sysinfo", charset="UTF-8"`)
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+}
+
+// privileged reports whether the caller may see process command lines and
+// logged in users
+func (ws *WebServer) privileged(c *gin.Context) bool {
+	if !ws.authEnabled() {
+		return true
+	}
+	id, ok := c.Get(identityKey)
+	return ok && id.(auth.Identity).Role.Allows(auth.RoleAdmin)
+}
+
+// redact hides what only admins may see from less privileged callers,
+// leaving the shared snapshot untouched
+func (ws *WebServer) redact(c *gin.Context, info *sysinfo.SystemInfo) *sysinfo.SystemInfo {
+	if ws.privileged(c) {
+		return info
+	}
+	redacted := *info
+	redacted.TopProcesses = nil
+	redacted.Users = nil
+	return &redacted
+}
+
+// basicAuth reports whether any authenticator takes passwords
+func (ws *WebServer) basicAuth() bool {
+	for _, a := range ws.authenticators {
+		if _, ok := a.(*auth.Users); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loginEnabled reports whether the login page can sign anyone in
+func (ws *WebServer) loginEnabled() bool {
+	for _, a := range ws.authenticators {
+		if _, ok := a.(auth.PasswordVerifier); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// postLogin signs in with a name and password, or a token as the password,
+// and starts a session
+func (ws *WebServer) postLogin(c *gin.Context) {
+	next := c.PostForm("next")
+	// Only return to pages of this server
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/"
+	}
+	name, password := c.PostForm("username"), c.PostForm("password")
+	for _, a := range ws.authenticators {
+		verifier, ok := a.(auth.PasswordVerifier)
+		if !ok {
+			continue
+		}
+		id, ok := verifier.Verify(name, password)
+		if !ok {
+			continue
+		}
+		token, expires, err := ws.sessions.Create(id, time.Now())
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Error starting session")
+			return
+		}
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     auth.SessionCookie,
+			Value:    token,
+			Path:     "/",
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   c.Request.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		c.Redirect(http.StatusSeeOther, next)
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/login?error=1&next="+url.QueryEscape(next))
+}
+
+// postLogout ends the caller's session
+func (ws *WebServer) postLogout(c *gin.Context) {
+	if token, err := c.Cookie(auth.SessionCookie); err == nil {
+		ws.sessions.Delete(token)
+	}
+	http.SetCookie(c.Writer, &http.Cookie{Name: auth.SessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	c.Redirect(http.StatusSeeOther, "/login")
+}
+
+// getSession tells the dashboard who is signed in
+func (ws *WebServer) getSession(c *gin.Context) {
+	if !ws.authEnabled() {
+		c.JSON(http.StatusOK, gin.H{"auth": false, "role": auth.RoleAdmin})
+		return
+	}
+	id := c.MustGet(identityKey).(auth.Identity)
+	_, err := c.Cookie(auth.SessionCookie)
+	c.JSON(http.StatusOK, gin.H{"auth": true, "name": id.Name, "role": id.Role, "session": err == nil})
+}
@@ -0,0 +1,147 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/junler/sysinfo/internal/auth"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func TestAuth(t *testing.T) {
+	tokens, err := auth.ParseTokens(strings.NewReader("viewer:r34d\nops:adm1n:admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _ := auth.HashPassword("alice", "wonderland", auth.RoleReadOnly)
+	users, err := auth.ParseUsers(strings.NewReader(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := NewWebServer(Config{
+		CollectOptions: sysinfo.Options{Collectors: []string{sysinfo.SectionHost}},
+		Auth:           []auth.Authenticator{tokens, users},
+	})
+	srv := httptest.NewServer(ws.router)
+	defer srv.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	do := func(method, path, token string, cookie *http.Cookie) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for _, tc := range []struct {
+		path, token string
+		want        int
+	}{
+		{"/api/health", "", http.StatusOK},
+		{"/api/info", "", http.StatusUnauthorized},
+		{"/api/info", "wrong", http.StatusUnauthorized},
+		{"/metrics", "", http.StatusUnauthorized},
+		{"/api/info", "r34d", http.StatusOK},
+		{"/api/processes", "r34d", http.StatusForbidden},
+		{"/api/users", "r34d", http.StatusForbidden},
+		{"/api/processes", "adm1n", http.StatusOK},
+		{"/api/users", "adm1n", http.StatusOK},
+		{"/login", "", http.StatusOK},
+	} {
+		if resp := do(http.MethodGet, tc.path, tc.token, nil); resp.StatusCode != tc.want {
+			t.Errorf("GET %s with %q: %s, want %d", tc.path, tc.token, resp.Status, tc.want)
+		}
+	}
+
+	resp := do(http.MethodGet, "/api/info", "", nil)
+	if got := resp.Header.Get("WWW-Authenticate"); !strings.HasPrefix(got, "Basic") {
+		t.Errorf("WWW-Authenticate = %q", got)
+	}
+	resp = do(http.MethodGet, "/?node=db1", "", nil)
+	if loc := resp.Header.Get("Location"); resp.StatusCode != http.StatusSeeOther || loc != "/login?next="+url.QueryEscape("/?node=db1") {
+		t.Errorf("dashboard without login: %s to %q", resp.Status, loc)
+	}
+
+	login := func(username, password, next string) *http.Response {
+		form := url.Values{"username": {username}, "password": {password}, "next": {next}}
+		resp, err := client.PostForm(srv.URL+"/login", form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := login("alice", "wrong", "/"); !strings.Contains(resp.Header.Get("Location"), "error=1") {
+		t.Errorf("wrong password redirects to %q", resp.Header.Get("Location"))
+	}
+	if resp := login("alice", "wonderland", "//evil.example"); resp.Header.Get("Location") != "/" {
+		t.Errorf("login redirects off site to %q", resp.Header.Get("Location"))
+	}
+	resp = login("alice", "wonderland", "/fleet")
+	var session *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == auth.SessionCookie {
+			session = c
+		}
+	}
+	if resp.Header.Get("Location") != "/fleet" || session == nil || !session.HttpOnly {
+		t.Fatalf("login: %s to %q, cookie %+v", resp.Status, resp.Header.Get("Location"), session)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/session", nil)
+	req.AddCookie(session)
+	sresp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var who struct {
+		Name string
+		Role auth.Role
+	}
+	json.NewDecoder(sresp.Body).Decode(&who)
+	sresp.Body.Close()
+	if who.Name != "alice" || who.Role != auth.RoleReadOnly {
+		t.Errorf("session = %+v", who)
+	}
+
+	do(http.MethodPost, "/logout", "", session)
+	if resp := do(http.MethodGet, "/api/info", "", session); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("after logout: %s", resp.Status)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tokens, _ := auth.ParseTokens(strings.NewReader("viewer:r34d"))
+	ws := NewWebServer(Config{Auth: []auth.Authenticator{tokens}})
+	info := &sysinfo.SystemInfo{
+		TopProcesses: []sysinfo.ProcessInfo{{PID: 1, CommandLine: "mysqld --password=hunter2"}},
+		Users:        []sysinfo.UserInfo{{User: "root"}},
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(identityKey, auth.Identity{Name: "viewer", Role: auth.RoleReadOnly})
+	if got := ws.redact(c, info); got.TopProcesses != nil || got.Users != nil {
+		t.Errorf("readonly caller sees %+v", got)
+	}
+	if len(info.TopProcesses) != 1 {
+		t.Error("redact changed the shared snapshot")
+	}
+	c.Set(identityKey, auth.Identity{Name: "ops", Role: auth.RoleAdmin})
+	if got := ws.redact(c, info); got != info {
+		t.Error("admin sees a redacted snapshot")
+	}
+}
@@ -0,0 +1,87 @@
+package webserver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long a collected snapshot is reused by default
+const DefaultCacheTTL = 5 * time.Second
+
+// snapshot is a collected value and the time it was collected
+type snapshot[T any] struct {
+	value       T
+	collectedAt time.Time
+}
+
+// Age returns how long ago the snapshot was collected
+func (s snapshot[T]) Age() time.Duration {
+	return time.Since(s.collectedAt)
+}
+
+// flight is an in-progress collection that concurrent callers wait on
+type flight[T any] struct {
+	done chan struct{}
+	snap snapshot[T]
+	err  error
+}
+
+// snapshotCache reuses a collected value for ttl and deduplicates concurrent
+// refreshes, so parallel requests share one collection instead of each
+// running their own
+type snapshotCache[T any] struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]snapshot[T]
+	flights map[string]*flight[T]
+}
+
+func newSnapshotCache[T any](ttl time.Duration) *snapshotCache[T] {
+	return &snapshotCache[T]{
+		ttl:     ttl,
+		entries: make(map[string]snapshot[T]),
+		flights: make(map[string]*flight[T]),
+	}
+}
+
+// get returns the cached snapshot for key if it is younger than the TTL,
+// otherwise it joins or starts a collection. The collection runs detached
+// from ctx so one caller going away doesn't fail the others waiting on it.
+func (c *snapshotCache[T]) get(ctx context.Context, key string, collect func(context.Context) (T, error)) (snapshot[T], error) {
+	c.mu.Lock()
+	if snap, ok := c.entries[key]; ok && snap.Age() < c.ttl {
+		c.mu.Unlock()
+		return snap, nil
+	}
+	f, ok := c.flights[key]
+	if !ok {
+		f = &flight[T]{done: make(chan struct{})}
+		c.flights[key] = f
+		go c.run(context.WithoutCancel(ctx), key, f, collect)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.snap, f.err
+	case <-ctx.Done():
+		return snapshot[T]{}, ctx.Err()
+	}
+}
+
+func (c *snapshotCache[T]) run(ctx context.Context, key string, f *flight[T], collect func(context.Context) (T, error)) {
+	value, err := collect(ctx)
+	f.snap = snapshot[T]{value: value, collectedAt: time.Now()}
+	f.err = err
+
+	c.mu.Lock()
+	delete(c.flights, key)
+	if err == nil && c.ttl > 0 {
+		c.entries[key] = f.snap
+	}
+	c.mu.Unlock()
+
+	close(f.done)
+}
@@ -0,0 +1,97 @@
+package webserver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshotCacheSingleFlight(t *testing.T) {
+	cache := newSnapshotCache[int](time.Minute)
+
+	var calls int32
+	release := make(chan struct{})
+	collect := func(context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			snap, err := cache.get(context.Background(), "k", collect)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = snap.value
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("collect ran %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("result %d = %d, want 42", i, v)
+		}
+	}
+
+	// A fresh snapshot is served without collecting again
+	if _, err := cache.get(context.Background(), "k", collect); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("collect ran %d times within TTL, want 1", n)
+	}
+}
+
+func TestSnapshotCacheExpires(t *testing.T) {
+	cache := newSnapshotCache[int](10 * time.Millisecond)
+
+	var calls int32
+	collect := func(context.Context) (int, error) {
+		return int(atomic.AddInt32(&calls, 1)), nil
+	}
+
+	first, _ := cache.get(context.Background(), "k", collect)
+	time.Sleep(20 * time.Millisecond)
+	second, _ := cache.get(context.Background(), "k", collect)
+
+	if first.value == second.value {
+		t.Errorf("expected a new collection after the TTL, got %d twice", first.value)
+	}
+}
+
+func TestSnapshotCacheCallerCancel(t *testing.T) {
+	cache := newSnapshotCache[int](time.Minute)
+
+	release := make(chan struct{})
+	collect := func(ctx context.Context) (int, error) {
+		<-release
+		return 7, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.get(ctx, "k", collect); err == nil {
+		t.Error("expected the cancelled caller to return an error")
+	}
+
+	// The shared collection is unaffected by the first caller leaving
+	close(release)
+	snap, err := cache.get(context.Background(), "k", collect)
+	if err != nil || snap.value != 7 {
+		t.Errorf("got %d, %v; want 7, nil", snap.value, err)
+	}
+}
@@ -0,0 +1,110 @@
+package webserver
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/junler/sysinfo/internal/fleet"
+)
+
+// postAgentPush records the snapshots an agent pushed. With agent tokens
+// configured the push must carry one of them as a bearer token.
+func (ws *WebServer) postAgentPush(c *gin.Context) {
+	if !ws.validAgentToken(c.GetHeader("Authorization")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing agent token"})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, fleet.MaxPushSize)
+	push, err := fleet.DecodePush(body, strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip"))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid push: " + err.Error()})
+		return
+	}
+	accepted, err := ws.central.Record(push, c.ClientIP(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": accepted})
+}
+
+// validAgentToken checks an Authorization header against the agent tokens
+func (ws *WebServer) validAgentToken(header string) bool {
+	if len(ws.agentTokens) == 0 {
+		return true
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	valid := false
+	for _, t := range ws.agentTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (ws *WebServer) getNodes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"nodes": ws.central.Nodes()})
+}
+
+func (ws *WebServer) getNode(c *gin.Context) {
+	status, _, ok := ws.central.Node(c.Param("node"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown node " + c.Param("node")})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// getNodeInfo serves a node's latest snapshot in the same shape as /api/info
+func (ws *WebServer) getNodeInfo(c *gin.Context) {
+	snap, ok := ws.nodeSnapshot(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, ws.redact(c, snap.Info))
+}
+
+// getNodePorts serves a node's latest ports in the same shape as /api/ports
+func (ws *WebServer) getNodePorts(c *gin.Context) {
+	snap, ok := ws.nodeSnapshot(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, snap.Ports)
+}
+
+func (ws *WebServer) getNodeHistory(c *gin.Context) {
+	store := ws.central.History(c.Param("node"))
+	if store == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no history for node " + c.Param("node")})
+		return
+	}
+	serveHistory(c, store)
+}
+
+// nodeSnapshot returns the latest snapshot of the requested node. On
+// failure it writes the error response and returns false.
+func (ws *WebServer) nodeSnapshot(c *gin.Context) (fleet.Snapshot, bool) {
+	status, snap, ok := ws.central.Node(c.Param("node"))
+	if !ok || snap.Info == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown node " + c.Param("node")})
+		return fleet.Snapshot{}, false
+	}
+	setSnapshotHeaders(c, snap.CollectedAt)
+	c.Header("X-Node-Status", string(status.Status))
+	return snap, true
+}
@@ -0,0 +1,90 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junler/sysinfo/internal/fleet"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+func TestCentralPush(t *testing.T) {
+	ws := NewWebServer(Config{Central: fleet.NewRegistry(fleet.RegistryConfig{HistoryRetention: time.Hour}), AcceptPushes: true, AgentTokens: []string{"s3cret"}})
+	srv := httptest.NewServer(ws.router)
+	defer srv.Close()
+
+	push := func(token string) *http.Response {
+		var body bytes.Buffer
+		info := &sysinfo.SystemInfo{Hostname: "db1", ProcessCount: 42, Collectors: []string{sysinfo.SectionHost}}
+		ports := []sysinfo.PortInfo{{Port: "5432", Protocol: "tcp"}}
+		err := fleet.EncodePush(&body, &fleet.Push{Node: "db1", Interval: 15,
+			Snapshots: []fleet.Snapshot{{CollectedAt: time.Now(), Info: info, Ports: ports}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+fleet.PushPath, &body)
+		req.Header.Set("Content-Encoding", "gzip")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	for _, token := range []string{"", "wrong"} {
+		if resp := push(token); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("push with token %q: %s", token, resp.Status)
+		}
+	}
+	if resp := push("s3cret"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("push: %s", resp.Status)
+	}
+
+	get := func(path string, v interface{}) int {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+	var nodes struct{ Nodes []fleet.NodeStatus }
+	if get("/api/nodes", &nodes); len(nodes.Nodes) != 1 || nodes.Nodes[0].Name != "db1" || nodes.Nodes[0].Status != fleet.StatusOnline {
+		t.Errorf("nodes = %+v", nodes)
+	}
+	var info sysinfo.SystemInfo
+	if get("/api/nodes/db1/info", &info); info.ProcessCount != 42 {
+		t.Errorf("node info = %+v", info)
+	}
+	var ports []sysinfo.PortInfo
+	if get("/api/nodes/db1/ports", &ports); len(ports) != 1 || ports[0].Port != "5432" {
+		t.Errorf("node ports = %+v", ports)
+	}
+	var metrics struct{ Metrics []string }
+	if get("/api/nodes/db1/history", &metrics); len(metrics.Metrics) != 1 || metrics.Metrics[0] != "processes.count" {
+		t.Errorf("node metrics = %+v", metrics)
+	}
+	if code := get("/api/nodes/nope/info", nil); code != http.StatusNotFound {
+		t.Errorf("unknown node: %d", code)
+	}
+}
+
+func TestPollingOnlyTakesNoPushes(t *testing.T) {
+	ws := NewWebServer(Config{Central: fleet.NewRegistry(fleet.RegistryConfig{})})
+	w := httptest.NewRecorder()
+	ws.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fleet.PushPath, strings.NewReader(`{"node":"x","snapshots":[]}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("push to a polling-only server: %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/junler/sysinfo/internal/history"
+)
+
+// defaultHistoryRange is how far back /api/history looks without ?since=
+const defaultHistoryRange = time.Hour
+
+// getHistory serves recorded metrics. ?metric= takes a comma separated list
+// of names or prefixes ending in "*"; without it the available metrics are
+// listed. ?since= and ?until= accept a duration back from now ("2h"), an
+// RFC 3339 time or Unix seconds, ?step= combines points into buckets and
+// ?agg= picks avg (default), min or max for combined points.
+func (ws *WebServer) getHistory(c *gin.Context) {
+	serveHistory(c, ws.history)
+}
+
+// serveHistory answers a history query from store
+func serveHistory(c *gin.Context, store history.Store) {
+	spec := c.Query("metric")
+	if spec == "" {
+		c.JSON(http.StatusOK, gin.H{"metrics": store.Metrics()})
+		return
+	}
+
+	now := time.Now()
+	q := history.Query{Since: now.Add(-defaultHistoryRange), Until: now, Aggregate: c.Query("agg")}
+	for _, m := range strings.Split(spec, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			q.Metrics = append(q.Metrics, m)
+		}
+	}
+
+	var err error
+	if s := c.Query("since"); s != "" {
+		if q.Since, err = parseHistoryTime(s, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since: " + err.Error()})
+			return
+		}
+	}
+	if s := c.Query("until"); s != "" {
+		if q.Until, err = parseHistoryTime(s, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until: " + err.Error()})
+			return
+		}
+	}
+	if s := c.Query("step"); s != "" {
+		if q.Step, err = time.ParseDuration(s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid step %q", s)})
+			return
+		}
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, err := store.Query(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if series == nil {
+		series = []history.Series{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"since":  q.Since.UTC().Format(time.RFC3339),
+		"until":  q.Until.UTC().Format(time.RFC3339),
+		"step":   q.Step.Seconds(),
+		"series": series,
+	})
+}
+
+// parseHistoryTime reads a duration before now, an RFC 3339 time or Unix seconds
+func parseHistoryTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a duration, RFC 3339 time or Unix timestamp", s)
+}
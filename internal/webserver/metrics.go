@@ -0,0 +1,39 @@
+package webserver
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/junler/sysinfo/internal/metrics"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// getMetrics renders the shared snapshot for Prometheus. Clients that
+// accept OpenMetrics get that format, everyone else the classic text format.
+func (ws *WebServer) getMetrics(c *gin.Context) {
+	info, ok := ws.systemInfo(c)
+	if !ok {
+		return
+	}
+
+	// Ports are optional for the exposition; a failure just omits them
+	var ports []sysinfo.PortInfo
+	if snap, err := ws.openPorts(c.Request.Context()); err == nil {
+		ports = snap.value
+	}
+
+	openMetrics := strings.Contains(c.GetHeader("Accept"), "application/openmetrics-text")
+	contentType := metrics.ContentTypeText
+	if openMetrics {
+		contentType = metrics.ContentTypeOpenMetrics
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.Write(&buf, info, ports, openMetrics); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
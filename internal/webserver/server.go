@@ -1,28 +1,99 @@
 package webserver
 
 import (
+	"context"
 	"embed"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/junler/sysinfo/internal/alert"
+	"github.com/junler/sysinfo/internal/anomaly"
+	"github.com/junler/sysinfo/internal/auth"
+	"github.com/junler/sysinfo/internal/fleet"
+	"github.com/junler/sysinfo/internal/history"
 	"github.com/junler/sysinfo/internal/sysinfo"
 )
 
 //go:embed web/*
 var WebFiles embed.FS
 
+// Config holds the settings for a WebServer
+type Config struct {
+	Port string
+	// CollectOptions bounds how long each request may spend collecting
+	CollectOptions sysinfo.Options
+	// CacheTTL is how long a collected snapshot is shared between requests
+	CacheTTL time.Duration
+	// MetricsOnly serves just /metrics and /api/health for headless exporters
+	MetricsOnly bool
+	// History backs /api/history; nil leaves the endpoint out
+	History history.Store
+	// Alerts backs /api/alerts; nil leaves the endpoint out
+	Alerts *alert.Engine
+	// Anomalies backs /api/anomalies; nil leaves the endpoint out
+	Anomalies *anomaly.Detector
+	// Central serves /api/nodes and the fleet overview; nil leaves them out
+	Central *fleet.Registry
+	// AcceptPushes records the snapshots agents push into Central
+	AcceptPushes bool
+	// AgentTokens are the bearer tokens agents may push with; empty
+	// accepts any push
+	AgentTokens []string
+	// Auth checks the credentials of every request but health checks and
+	// agent pushes; empty leaves the server open to anyone who reaches it
+	Auth []auth.Authenticator
+	// TLS serves HTTPS instead of plain HTTP; nil serves plain HTTP
+	TLS *TLSConfig
+}
+
 type WebServer struct {
-	router *gin.Engine
-	port   string
+	router      *gin.Engine
+	port        string
+	collect     sysinfo.Options
+	metricsOnly bool
+	history     history.Store
+	alerts      *alert.Engine
+	anomalies   *anomaly.Detector
+	central     *fleet.Registry
+	pushes      bool
+	agentTokens []string
+	tls         *TLSConfig
+
+	authenticators []auth.Authenticator
+	sessions       *auth.Sessions
+
+	infoCache  *snapshotCache[*sysinfo.SystemInfo]
+	portsCache *snapshotCache[[]sysinfo.PortInfo]
+	streams    *streamHub
 }
 
-func NewWebServer(port string) *WebServer {
+func NewWebServer(cfg Config) *WebServer {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
 	ws := &WebServer{
-		router: router,
-		port:   port,
+		router:         router,
+		port:           cfg.Port,
+		collect:        cfg.CollectOptions,
+		metricsOnly:    cfg.MetricsOnly,
+		history:        cfg.History,
+		alerts:         cfg.Alerts,
+		anomalies:      cfg.Anomalies,
+		central:        cfg.Central,
+		pushes:         cfg.AcceptPushes,
+		agentTokens:    cfg.AgentTokens,
+		tls:            cfg.TLS,
+		authenticators: cfg.Auth,
+		infoCache:      newSnapshotCache[*sysinfo.SystemInfo](cfg.CacheTTL),
+		portsCache:     newSnapshotCache[[]sysinfo.PortInfo](cfg.CacheTTL),
+	}
+	ws.streams = newStreamHub(ws.collectStream)
+	if ws.authEnabled() {
+		ws.sessions = auth.NewSessions(auth.DefaultSessionTTL)
 	}
 
 	ws.setupRoutes()
@@ -30,50 +101,100 @@ func NewWebServer(port string) *WebServer {
 }
 
 func (ws *WebServer) setupRoutes() {
+	read := ws.authorize(auth.RoleReadOnly)
+	ws.router.GET("/metrics", read, ws.getMetrics)
+	if ws.metricsOnly {
+		ws.router.GET("/api/health", ws.healthCheck)
+		return
+	}
+
 	// Serve static files from embedded filesystem
 	ws.router.StaticFS("/static", http.FS(WebFiles))
 
-	// Serve the main page
-	ws.router.GET("/", func(c *gin.Context) {
-		data, err := WebFiles.ReadFile("web/index.html")
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Error loading page")
-			return
-		}
-		c.Data(http.StatusOK, "text/html; charset=utf-8", data)
-	})
+	if ws.loginEnabled() {
+		ws.router.GET("/login", servePage("web/login.html"))
+		ws.router.POST("/login", ws.postLogin)
+		ws.router.POST("/logout", ws.postLogout)
+	}
+
+	// Serve the main page, and the fleet overview on a central server
+	ws.router.GET("/", read, servePage("web/index.html"))
+	if ws.central != nil {
+		ws.router.GET("/fleet", read, servePage("web/fleet.html"))
+	}
+
+	// Health checks and agent pushes, which have tokens of their own, need
+	// no login
+	ws.router.GET("/api/health", ws.healthCheck)
+	if ws.central != nil && ws.pushes {
+		ws.router.POST("/api/agent/push", ws.postAgentPush)
+	}
+
+	// Process command lines and logged in users are for admins only
+	admin := ws.router.Group("/api", ws.authorize(auth.RoleAdmin))
+	{
+		admin.GET("/processes", ws.getTopProcesses)
+		admin.GET("/users", ws.getUsers)
+	}
 
 	// API endpoints
-	api := ws.router.Group("/api")
+	api := ws.router.Group("/api", read)
 	{
 		api.GET("/info", ws.getSystemInfo)
 		api.GET("/ports", ws.getPorts)
-		api.GET("/health", ws.healthCheck)
-		api.GET("/processes", ws.getTopProcesses)
+		api.GET("/session", ws.getSession)
 		api.GET("/monitoring", ws.getMonitoringData)
 		api.GET("/temperature", ws.getTemperature)
 		api.GET("/iostats", ws.getIOStats)
-		api.GET("/users", ws.getUsers)
 		api.GET("/services", ws.getServices)
+		api.GET("/stream", ws.getStream)
+		if ws.history != nil {
+			api.GET("/history", ws.getHistory)
+		}
+		if ws.alerts != nil {
+			api.GET("/alerts", ws.getAlerts)
+		}
+		if ws.anomalies != nil {
+			api.GET("/anomalies", ws.getAnomalies)
+		}
+		if ws.central != nil {
+			api.GET("/nodes", ws.getNodes)
+			api.GET("/nodes/:node", ws.getNode)
+			api.GET("/nodes/:node/info", ws.getNodeInfo)
+			api.GET("/nodes/:node/ports", ws.getNodePorts)
+			api.GET("/nodes/:node/history", ws.getNodeHistory)
+		}
+	}
+}
+
+// servePage serves an embedded HTML page
+func servePage(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := WebFiles.ReadFile(name)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Error loading page")
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", data)
 	}
 }
 
 func (ws *WebServer) getSystemInfo(c *gin.Context) {
-	info, err := sysinfo.GetSystemInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	info, ok := ws.systemInfo(c)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, info)
+	c.JSON(http.StatusOK, ws.redact(c, info))
 }
 
 func (ws *WebServer) getPorts(c *gin.Context) {
-	ports, err := sysinfo.GetOpenPorts()
+	snap, err := ws.openPorts(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, ports)
+	setSnapshotHeaders(c, snap.collectedAt)
+	c.JSON(http.StatusOK, snap.value)
 }
 
 func (ws *WebServer) healthCheck(c *gin.Context) {
@@ -81,20 +202,19 @@ func (ws *WebServer) healthCheck(c *gin.Context) {
 }
 
 func (ws *WebServer) getTopProcesses(c *gin.Context) {
-	info, err := sysinfo.GetSystemInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	info, ok := ws.systemInfo(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"processes": info.TopProcesses})
 }
 
 func (ws *WebServer) getMonitoringData(c *gin.Context) {
-	info, err := sysinfo.GetSystemInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	info, ok := ws.systemInfo(c)
+	if !ok {
 		return
 	}
+	info = ws.redact(c, info)
 
 	// Return a subset of monitoring data for dashboard
 	monitoringData := gin.H{
@@ -113,47 +233,123 @@ func (ws *WebServer) getMonitoringData(c *gin.Context) {
 		},
 		"top_processes": info.TopProcesses[:min(10, len(info.TopProcesses))],
 		"disk":          info.Disk,
+		"errors":        info.Errors,
+		"warnings":      info.Warnings,
 	}
 
 	c.JSON(http.StatusOK, monitoringData)
 }
 
 func (ws *WebServer) getTemperature(c *gin.Context) {
-	info, err := sysinfo.GetSystemInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	info, ok := ws.systemInfo(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, info.Temperature)
 }
 
 func (ws *WebServer) getIOStats(c *gin.Context) {
-	info, err := sysinfo.GetSystemInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	info, ok := ws.systemInfo(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, info.IOStats)
 }
 
 func (ws *WebServer) getUsers(c *gin.Context) {
-	info, err := sysinfo.GetSystemInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	info, ok := ws.systemInfo(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"users": info.Users})
 }
 
 func (ws *WebServer) getServices(c *gin.Context) {
-	info, err := sysinfo.GetSystemInfo()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	info, ok := ws.systemInfo(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"services": info.SystemServices})
 }
 
+// getAlerts lists pending, firing and recently resolved alerts. ?state=
+// limits the list to one state.
+func (ws *WebServer) getAlerts(c *gin.Context) {
+	alerts := ws.alerts.Alerts()
+	if state := c.Query("state"); state != "" {
+		filtered := alerts[:0]
+		for _, a := range alerts {
+			if string(a.State) == state {
+				filtered = append(filtered, a)
+			}
+		}
+		alerts = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// getAnomalies lists active and recently ended anomalies. ?state= limits
+// the list to one state.
+func (ws *WebServer) getAnomalies(c *gin.Context) {
+	anomalies := ws.anomalies.Anomalies()
+	if state := c.Query("state"); state != "" {
+		filtered := anomalies[:0]
+		for _, a := range anomalies {
+			if string(a.State) == state {
+				filtered = append(filtered, a)
+			}
+		}
+		anomalies = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"anomalies": anomalies})
+}
+
+// systemInfo returns the shared snapshot for the request's collector
+// selection, honouring ?collectors= to override the server's default. The
+// returned SystemInfo is shared between requests and must not be modified.
+// On failure it writes the error response and returns false.
+func (ws *WebServer) systemInfo(c *gin.Context) (*sysinfo.SystemInfo, bool) {
+	opts := ws.collect
+	if spec, ok := c.GetQuery("collectors"); ok {
+		opts.Collectors = sysinfo.ParseCollectorList(spec)
+	}
+	collectors, err := sysinfo.ResolveCollectors(opts.Collectors)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	// Key on the resolved names so equivalent selections share a snapshot
+	var names []string
+	for _, collector := range collectors {
+		names = append(names, collector.Name())
+	}
+	opts.Collectors = names
+
+	snap, err := ws.infoCache.get(c.Request.Context(), strings.Join(names, ","), func(ctx context.Context) (*sysinfo.SystemInfo, error) {
+		return sysinfo.GetSystemInfoContext(ctx, opts)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	setSnapshotHeaders(c, snap.collectedAt)
+	return snap.value, true
+}
+
+// openPorts returns the shared snapshot of listening ports
+func (ws *WebServer) openPorts(ctx context.Context) (snapshot[[]sysinfo.PortInfo], error) {
+	return ws.portsCache.get(ctx, "", func(context.Context) ([]sysinfo.PortInfo, error) {
+		return sysinfo.GetOpenPorts()
+	})
+}
+
+// setSnapshotHeaders tells clients when the data they receive was collected
+func setSnapshotHeaders(c *gin.Context, collectedAt time.Time) {
+	c.Header("X-Collected-At", collectedAt.UTC().Format(time.RFC3339Nano))
+	c.Header("Age", strconv.Itoa(int(time.Since(collectedAt).Seconds())))
+}
+
 // min returns the smaller of two integers
 func min(a, b int) int {
 	if a < b {
@@ -162,6 +358,52 @@ func min(a, b int) int {
 	return b
 }
 
-func (ws *WebServer) Start() error {
-	return ws.router.Run(":" + ws.port)
+// shutdownTimeout bounds how long Start waits for requests in flight once
+// its context is done
+const shutdownTimeout = 5 * time.Second
+
+// Start serves until ctx is done, then shuts down gracefully and returns
+// nil. Errors listening are returned right away.
+func (ws *WebServer) Start(ctx context.Context) error {
+	if ws.tls != nil {
+		return ws.startTLS(ctx)
+	}
+	return serve(ctx, newServer(ctx, ":"+ws.port, ws.router))
+}
+
+// newServer creates an http.Server whose request contexts end with ctx, so
+// streams don't hold up the shutdown
+func newServer(ctx context.Context, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:        addr,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+}
+
+// serve runs the servers, over HTTPS for those with a TLSConfig, until one
+// fails or ctx is done, then shuts all of them down
+func serve(ctx context.Context, servers ...*http.Server) error {
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			if srv.TLSConfig != nil {
+				errs <- srv.ListenAndServeTLS("", "")
+			} else {
+				errs <- srv.ListenAndServe()
+			}
+		}()
+	}
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		srv.Shutdown(shutdownCtx)
+	}
+	return err
 }
@@ -0,0 +1,357 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/junler/sysinfo/internal/sysinfo"
+)
+
+// Bounds and defaults for /api/stream
+const (
+	defaultStreamInterval = 2 * time.Second
+	minStreamInterval     = time.Second
+	maxStreamInterval     = 5 * time.Minute
+
+	// streamKeepAlive is how often an idle stream sends a comment so
+	// proxies don't time the connection out
+	streamKeepAlive = 15 * time.Second
+	// streamWriteTimeout is how long a client may take to accept one write
+	// before it is considered stuck and disconnected
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamTopic is a part of the system state that can be streamed. Its
+// payload uses the same field names as /api/info so clients can merge it
+// into a full snapshot.
+type streamTopic struct {
+	collectors []string
+	payload    func(info *sysinfo.SystemInfo, ports []sysinfo.PortInfo) gin.H
+}
+
+var streamTopics = map[string]streamTopic{
+	"cpu": {
+		collectors: []string{sysinfo.SectionCPU, sysinfo.SectionLoad},
+		payload: func(info *sysinfo.SystemInfo, _ []sysinfo.PortInfo) gin.H {
+			return gin.H{"cpu": info.CPU, "load_average": info.LoadAverage}
+		},
+	},
+	"memory": {
+		collectors: []string{sysinfo.SectionMemory, sysinfo.SectionSwap},
+		payload: func(info *sysinfo.SystemInfo, _ []sysinfo.PortInfo) gin.H {
+			return gin.H{"memory": info.Memory, "swap": info.Swap}
+		},
+	},
+	"processes": {
+		// process_count comes from the host collector, so it is left to
+		// /api/info rather than sent as 0
+		collectors: []string{sysinfo.SectionProcesses},
+		payload: func(info *sysinfo.SystemInfo, _ []sysinfo.PortInfo) gin.H {
+			return gin.H{"top_processes": info.TopProcesses}
+		},
+	},
+	"network": {
+		collectors: []string{sysinfo.SectionNetwork},
+		payload: func(info *sysinfo.SystemInfo, _ []sysinfo.PortInfo) gin.H {
+			return gin.H{"network": info.Network}
+		},
+	},
+	"ports": {
+		payload: func(_ *sysinfo.SystemInfo, ports []sysinfo.PortInfo) gin.H {
+			return gin.H{"ports": ports}
+		},
+	},
+}
+
+// defaultStreamTopics are streamed when a client doesn't pick any
+var defaultStreamTopics = []string{"cpu", "memory", "network"}
+
+// parseStreamTopics validates a comma separated topic list and returns it
+// sorted and without duplicates
+func parseStreamTopics(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return defaultStreamTopics, nil
+	}
+	seen := make(map[string]bool)
+	var topics []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := streamTopics[name]; !ok {
+			return nil, fmt.Errorf("unknown topic %q (available: %s)", name, strings.Join(streamTopicNames(), ", "))
+		}
+		seen[name] = true
+		topics = append(topics, name)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+func streamTopicNames() []string {
+	names := make([]string, 0, len(streamTopics))
+	for name := range streamTopics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// streamClient is one connected client. Updates are coalesced per topic
+// rather than queued, so a client that reads slower than the feed produces
+// skips intermediate snapshots instead of growing a backlog.
+type streamClient struct {
+	mu      sync.Mutex
+	pending map[string][]byte
+	notify  chan struct{}
+}
+
+func newStreamClient() *streamClient {
+	return &streamClient{pending: make(map[string][]byte), notify: make(chan struct{}, 1)}
+}
+
+// offer replaces any unsent update of the topic and wakes the writer
+func (c *streamClient) offer(topic string, data []byte) {
+	c.mu.Lock()
+	c.pending[topic] = data
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the unsent updates and clears them
+func (c *streamClient) take() map[string][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.pending
+	c.pending = make(map[string][]byte)
+	return pending
+}
+
+// streamFeed collects one topic selection at one interval on behalf of all
+// clients subscribed to it
+type streamFeed struct {
+	key      string
+	topics   []string
+	interval time.Duration
+	stop     context.CancelFunc
+
+	mu      sync.Mutex
+	last    map[string][]byte // latest payload of each topic
+	clients map[*streamClient]bool
+}
+
+// publish sends the payloads that changed since the last collection
+func (f *streamFeed) publish(payloads map[string][]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for topic, data := range payloads {
+		if bytes.Equal(f.last[topic], data) {
+			continue
+		}
+		f.last[topic] = data
+		for c := range f.clients {
+			c.offer(topic, data)
+		}
+	}
+}
+
+// streamHub shares feeds between clients. A feed starts with its first
+// subscriber and stops when the last one leaves.
+type streamHub struct {
+	// collect gathers the payload of each topic
+	collect func(ctx context.Context, topics []string) (map[string][]byte, error)
+
+	mu    sync.Mutex
+	feeds map[string]*streamFeed
+}
+
+func newStreamHub(collect func(ctx context.Context, topics []string) (map[string][]byte, error)) *streamHub {
+	return &streamHub{collect: collect, feeds: make(map[string]*streamFeed)}
+}
+
+// subscribe attaches a new client to the feed for topics and interval. The
+// client starts with the feed's current state so it doesn't wait a full
+// interval for its first update.
+func (h *streamHub) subscribe(topics []string, interval time.Duration) (*streamFeed, *streamClient) {
+	key := strings.Join(topics, ",") + "@" + interval.String()
+	client := newStreamClient()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, ok := h.feeds[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		f = &streamFeed{
+			key:      key,
+			topics:   topics,
+			interval: interval,
+			stop:     cancel,
+			last:     make(map[string][]byte),
+			clients:  make(map[*streamClient]bool),
+		}
+		h.feeds[key] = f
+		go h.run(ctx, f)
+	}
+
+	f.mu.Lock()
+	f.clients[client] = true
+	for topic, data := range f.last {
+		client.offer(topic, data)
+	}
+	f.mu.Unlock()
+	return f, client
+}
+
+func (h *streamHub) unsubscribe(f *streamFeed, client *streamClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f.mu.Lock()
+	delete(f.clients, client)
+	idle := len(f.clients) == 0
+	f.mu.Unlock()
+	if idle {
+		f.stop()
+		delete(h.feeds, f.key)
+	}
+}
+
+func (h *streamHub) run(ctx context.Context, f *streamFeed) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		// A failed collection keeps the previous state; clients see no update
+		if payloads, err := h.collect(ctx, f.topics); err == nil {
+			f.publish(payloads)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectStream gathers the payloads of topics, running only the collectors
+// they need. Ports come from the shared ports snapshot.
+func (ws *WebServer) collectStream(ctx context.Context, topics []string) (map[string][]byte, error) {
+	var collectors []string
+	wantPorts := false
+	for _, name := range topics {
+		collectors = append(collectors, streamTopics[name].collectors...)
+		wantPorts = wantPorts || name == "ports"
+	}
+
+	info := &sysinfo.SystemInfo{}
+	if len(collectors) > 0 {
+		opts := ws.collect
+		opts.Collectors = collectors
+		var err error
+		if info, err = sysinfo.GetSystemInfoContext(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	var ports []sysinfo.PortInfo
+	if wantPorts {
+		snap, err := ws.openPorts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ports = snap.value
+	}
+
+	payloads := make(map[string][]byte, len(topics))
+	for _, name := range topics {
+		data, err := json.Marshal(streamTopics[name].payload(info, ports))
+		if err != nil {
+			return nil, err
+		}
+		payloads[name] = data
+	}
+	return payloads, nil
+}
+
+// getStream serves live updates as Server-Sent Events. ?topics= picks from
+// cpu, memory, processes, network and ports, ?interval= sets how often the
+// state is collected. Each event is named after its topic and only sent
+// when that topic changed.
+func (ws *WebServer) getStream(c *gin.Context) {
+	topics, err := parseStreamTopics(c.Query("topics"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !ws.privileged(c) {
+		// Like /api/info, leave out the processes for less privileged callers
+		topics = slices.DeleteFunc(slices.Clone(topics), func(t string) bool { return t == "processes" })
+		if len(topics) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "the processes topic needs the admin role"})
+			return
+		}
+	}
+	interval := defaultStreamInterval
+	if s := c.Query("interval"); s != "" {
+		if interval, err = time.ParseDuration(s); err != nil || interval < minStreamInterval || interval > maxStreamInterval {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid interval %q: want a duration between %s and %s", s, minStreamInterval, maxStreamInterval)})
+			return
+		}
+	}
+
+	feed, client := ws.streams.subscribe(topics, interval)
+	defer ws.streams.unsubscribe(feed, client)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	rc := http.NewResponseController(c.Writer)
+	write := func(buf []byte) bool {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := c.Writer.Write(buf); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	// Ask browsers to reconnect after one interval if the connection drops
+	if !write([]byte(fmt.Sprintf("retry: %d\n\n", interval.Milliseconds()))) {
+		return
+	}
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var buf bytes.Buffer
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			buf.WriteString(": keep-alive\n\n")
+		case <-client.notify:
+			pending := client.take()
+			for _, topic := range topics {
+				if data, ok := pending[topic]; ok {
+					fmt.Fprintf(&buf, "event: %s\ndata: %s\n\n", topic, data)
+				}
+			}
+		}
+		if buf.Len() > 0 && !write(buf.Bytes()) {
+			return
+		}
+	}
+}
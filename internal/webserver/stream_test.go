@@ -0,0 +1,155 @@
+package webserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseStreamTopics(t *testing.T) {
+	topics, err := parseStreamTopics(" Ports,cpu,,cpu ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cpu", "ports"}; !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics = %v, want %v", topics, want)
+	}
+	if topics, _ := parseStreamTopics(""); !reflect.DeepEqual(topics, defaultStreamTopics) {
+		t.Errorf("default topics = %v", topics)
+	}
+	if _, err := parseStreamTopics("cpu,disks"); err == nil {
+		t.Error("expected an error for an unknown topic")
+	}
+}
+
+func TestStreamClientCoalesces(t *testing.T) {
+	c := newStreamClient()
+	c.offer("cpu", []byte("1"))
+	c.offer("cpu", []byte("2"))
+	c.offer("memory", []byte("3"))
+
+	<-c.notify
+	got := c.take()
+	if string(got["cpu"]) != "2" || string(got["memory"]) != "3" || len(got) != 2 {
+		t.Errorf("take() = %q, want only the newest update of each topic", got)
+	}
+	select {
+	case <-c.notify:
+		t.Error("notify should hold at most one wakeup")
+	default:
+	}
+}
+
+func TestStreamHubSharesFeeds(t *testing.T) {
+	var calls int32
+	hub := newStreamHub(func(ctx context.Context, topics []string) (map[string][]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		// cpu changes on every collection, memory never does
+		return map[string][]byte{"cpu": []byte{byte(atomic.LoadInt32(&calls))}, "memory": []byte("m")}, nil
+	})
+
+	f1, c1 := hub.subscribe([]string{"cpu", "memory"}, time.Hour)
+	f2, c2 := hub.subscribe([]string{"cpu", "memory"}, time.Hour)
+	if f1 != f2 {
+		t.Fatal("clients with the same selection should share a feed")
+	}
+	<-c1.notify
+	time.Sleep(20 * time.Millisecond)
+	if got := c2.take(); len(got) != 2 {
+		t.Errorf("second client got %q, want both topics", got)
+	}
+	c1.take()
+
+	// Unchanged topics are not sent again
+	f1.publish(map[string][]byte{"cpu": []byte("new"), "memory": []byte("m")})
+	if got := c1.take(); len(got) != 1 || string(got["cpu"]) != "new" {
+		t.Errorf("after publish got %q, want only cpu", got)
+	}
+
+	hub.unsubscribe(f1, c1)
+	hub.unsubscribe(f2, c2)
+	if len(hub.feeds) != 0 {
+		t.Errorf("feed still running after its last client left")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("collected %d times, want 1 for both clients", n)
+	}
+}
+
+func TestCollectStreamPayloads(t *testing.T) {
+	ws := NewWebServer(Config{})
+	payloads, err := ws.collectStream(context.Background(), []string{"memory", "processes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var memory struct {
+		Memory struct {
+			Total uint64 `json:"total"`
+		} `json:"memory"`
+	}
+	if err := json.Unmarshal(payloads["memory"], &memory); err != nil || memory.Memory.Total == 0 {
+		t.Errorf("memory payload %s", payloads["memory"])
+	}
+
+	// Every field is filled by the topic's own collectors; a field that
+	// needs another collector would be sent as a zero and overwrite the
+	// dashboard's value
+	var processes map[string]json.RawMessage
+	if err := json.Unmarshal(payloads["processes"], &processes); err != nil {
+		t.Fatal(err)
+	}
+	var top []map[string]any
+	json.Unmarshal(processes["top_processes"], &top)
+	if len(top) == 0 || len(processes) != 1 {
+		t.Errorf("processes payload %s", payloads["processes"])
+	}
+}
+
+func TestGetStream(t *testing.T) {
+	ws := NewWebServer(Config{})
+	ws.streams = newStreamHub(func(ctx context.Context, topics []string) (map[string][]byte, error) {
+		return map[string][]byte{"memory": []byte(`{"memory":{"total":1}}`)}, nil
+	})
+	srv := httptest.NewServer(ws.router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/stream?topics=unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown topic: status %d, want 400", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/stream?topics=memory&interval=1s", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && !strings.HasPrefix(scanner.Text(), "data:") {
+		lines = append(lines, scanner.Text())
+	}
+	lines = append(lines, scanner.Text())
+	want := []string{"retry: 1000", "", "event: memory", `data: {"memory":{"total":1}}`}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("stream started with %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,247 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TLSConfig serves a WebServer over HTTPS
+type TLSConfig struct {
+	// CertFile and KeyFile hold the PEM certificate chain and key. They
+	// are generated with a self-signed certificate when both are missing,
+	// and reloaded when they change.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, when set, holds the PEM CA certificates client
+	// certificates must be signed by; clients without one are refused
+	ClientCAFile string
+	// RedirectPort, when set, serves plain HTTP on this port redirecting
+	// every request to HTTPS
+	RedirectPort string
+}
+
+// Bounds for generated and reloaded certificates
+const (
+	selfSignedValidity = 365 * 24 * time.Hour
+	// tlsReloadCheck is how often handshakes check the files for changes
+	tlsReloadCheck = 10 * time.Second
+)
+
+// startTLS serves HTTPS, and the HTTP redirect when configured, until one
+// of them fails or ctx is done
+func (ws *WebServer) startTLS(ctx context.Context) error {
+	if err := EnsureCertificate(ws.tls.CertFile, ws.tls.KeyFile); err != nil {
+		return err
+	}
+	reloader := &tlsReloader{certFile: ws.tls.CertFile, keyFile: ws.tls.KeyFile, caFile: ws.tls.ClientCAFile}
+	if err := reloader.reload(); err != nil {
+		return err
+	}
+
+	srv := newServer(ctx, ":"+ws.port, ws.router)
+	srv.TLSConfig = &tls.Config{GetConfigForClient: reloader.config}
+	servers := []*http.Server{srv}
+	if ws.tls.RedirectPort != "" {
+		servers = append(servers, newServer(ctx, ":"+ws.tls.RedirectPort, redirectToHTTPS(ws.port)))
+	}
+	return serve(ctx, servers...)
+}
+
+// redirectToHTTPS sends every request to the same URL on the HTTPS port
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// tlsReloader hands out TLS settings built from the certificate, key and
+// client CA files, rebuilding them when the files change
+type tlsReloader struct {
+	certFile, keyFile, caFile string
+
+	mu      sync.Mutex
+	cfg     *tls.Config
+	modTime time.Time
+	checked time.Time
+}
+
+// config returns the current TLS settings for a handshake
+func (r *tlsReloader) config(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	check := time.Since(r.checked) >= tlsReloadCheck
+	r.mu.Unlock()
+	if check {
+		if err := r.reload(); err != nil {
+			// Files being replaced may be half written, keep serving the
+			// previous certificate until they are complete
+			log.Printf("Error reloading TLS certificate: %v", err)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg, nil
+}
+
+// reload rebuilds the settings if any of the files changed since the last
+// successful load
+func (r *tlsReloader) reload() error {
+	r.mu.Lock()
+	r.checked = time.Now()
+	last := r.modTime
+	r.mu.Unlock()
+
+	modTime, err := latestModTime(r.certFile, r.keyFile, r.caFile)
+	if err != nil {
+		return err
+	}
+	if !modTime.After(last) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if r.caFile != "" {
+		data, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%s: no certificates found", r.caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cfg != nil {
+		log.Printf("Reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cfg, r.modTime = cfg, modTime
+	return nil
+}
+
+// latestModTime returns when the newest of the files changed
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// EnsureCertificate generates a self-signed certificate for this host in
+// certFile and keyFile when neither exists yet
+func EnsureCertificate(certFile, keyFile string) error {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if !errors.Is(certErr, fs.ErrNotExist) || !errors.Is(keyErr, fs.ErrNotExist) {
+		// Loading reports a missing half of the pair
+		return nil
+	}
+
+	certPEM, keyPEM, err := selfSignedCertificate(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(keyFile, keyPEM, 0o600); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(certFile, certPEM, 0o644); err != nil {
+		return err
+	}
+	block, _ := pem.Decode(certPEM)
+	log.Printf("Generated a self-signed certificate in %s (SHA-256 fingerprint %X)", certFile, sha256.Sum256(block.Bytes))
+	return nil
+}
+
+// selfSignedCertificate creates a certificate for the host name, localhost
+// and the loopback addresses
+func selfSignedCertificate(now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	hostname, _ := os.Hostname()
+	names := []string{"localhost"}
+	if hostname != "" && hostname != "localhost" {
+		names = append([]string{hostname}, names...)
+	}
+	// Client auth lets a quick setup reuse the certificate, and itself as
+	// the client CA, on both ends of mTLS
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: names[0], Organization: []string{"sysinfo self-signed"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              names,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// writeFileAtomic replaces path with data so readers never see part of it
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
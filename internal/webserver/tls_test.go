@@ -0,0 +1,131 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem")
+	if err := EnsureCertificate(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(pair.Certificate[0])
+	if err := cert.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if fi, _ := os.Stat(keyFile); fi.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode %v", fi.Mode().Perm())
+	}
+
+	// An existing certificate is kept
+	before, _ := os.ReadFile(certFile)
+	if err := EnsureCertificate(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(certFile); string(after) != string(before) {
+		t.Error("existing certificate replaced")
+	}
+}
+
+func TestTLSReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := EnsureCertificate(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	r := &tlsReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	first := r.cfg.Certificates[0].Certificate[0]
+
+	certPEM, keyPEM, err := selfSignedCertificate(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, certPEM, 0o644)
+	os.WriteFile(keyFile, keyPEM, 0o600)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+
+	// Handshakes only look at the files every tlsReloadCheck
+	cfg, _ := r.config(nil)
+	if string(cfg.Certificates[0].Certificate[0]) != string(first) {
+		t.Error("reloaded before the check interval")
+	}
+	r.checked = time.Time{}
+	cfg, _ = r.config(nil)
+	if string(cfg.Certificates[0].Certificate[0]) == string(first) {
+		t.Error("changed certificate not reloaded")
+	}
+
+	// A broken certificate keeps the previous one in use
+	os.WriteFile(certFile, []byte("garbage"), 0o644)
+	later = later.Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	r.checked = time.Time{}
+	if got, _ := r.config(nil); got != cfg {
+		t.Error("broken certificate replaced the working one")
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := EnsureCertificate(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	// The self-signed certificate doubles as the client CA and certificate
+	r := &tlsReloader{certFile: certFile, keyFile: keyFile, caFile: certFile}
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	srv.TLS = &tls.Config{GetConfigForClient: r.config}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	pem, _ := os.ReadFile(certFile)
+	roots.AppendCertsFromPEM(pem)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+	if _, err := client().Get(srv.URL); err == nil {
+		t.Error("client without a certificate accepted")
+	}
+	pair, _ := tls.LoadX509KeyPair(certFile, keyFile)
+	resp, err := client(pair).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	for _, tc := range []struct{ port, host, want string }{
+		{"8443", "example.com:8080", "https://example.com:8443/api/info?x=1"},
+		{"443", "example.com:80", "https://example.com/api/info?x=1"},
+		{"8443", "example.com", "https://example.com:8443/api/info?x=1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/info?x=1", nil)
+		req.Host = tc.host
+		w := httptest.NewRecorder()
+		redirectToHTTPS(tc.port).ServeHTTP(w, req)
+		if loc := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || loc != tc.want {
+			t.Errorf("%s on port %s: %d to %q, want %q", tc.host, tc.port, w.Code, loc, tc.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// TimeChart draws time series as line charts on a canvas. It is bundled
+// with the dashboard so the history charts work without network access.
+(function (global) {
+    'use strict';
+
+    const PALETTE = ['#4f46e5', '#059669', '#dc2626', '#d97706', '#0891b2', '#7c3aed', '#db2777', '#65a30d'];
+    const PAD = { left: 64, right: 12, top: 28, bottom: 28 };
+
+    class TimeChart {
+        // options: min, max (fixed y bounds, max defaults to the data),
+        // format (y value to label), legend (default true)
+        constructor(canvas, options = {}) {
+            this.canvas = canvas;
+            this.options = Object.assign({ min: 0, max: null, format: v => v.toFixed(1), legend: true }, options);
+            this.series = [];
+            this.range = null;
+            this.hoverX = null;
+
+            canvas.addEventListener('mousemove', e => {
+                this.hoverX = e.offsetX;
+                this.draw();
+            });
+            canvas.addEventListener('mouseleave', () => {
+                this.hoverX = null;
+                this.draw();
+            });
+            if (global.ResizeObserver) {
+                new ResizeObserver(() => this.draw()).observe(canvas);
+            }
+        }
+
+        // series: [{ label, points: [[unixMs, value], ...] }]
+        // range: { from, to } in Unix milliseconds
+        setData(series, range) {
+            this.series = series.map((s, i) => Object.assign({ color: PALETTE[i % PALETTE.length] }, s));
+            this.range = range;
+            this.draw();
+        }
+
+        draw() {
+            const canvas = this.canvas;
+            const width = canvas.clientWidth, height = canvas.clientHeight;
+            if (!width || !height || !this.range) return;
+
+            const dpr = global.devicePixelRatio || 1;
+            canvas.width = width * dpr;
+            canvas.height = height * dpr;
+            const ctx = canvas.getContext('2d');
+            ctx.setTransform(dpr, 0, 0, dpr, 0, 0);
+            ctx.clearRect(0, 0, width, height);
+            ctx.font = '11px ui-sans-serif, system-ui, sans-serif';
+
+            const plot = { x: PAD.left, y: PAD.top, w: width - PAD.left - PAD.right, h: height - PAD.top - PAD.bottom };
+            const { from, to } = this.range;
+            const [yMin, yMax, yStep] = this.yScale();
+            const xOf = t => plot.x + (t - from) / (to - from) * plot.w;
+            const yOf = v => plot.y + plot.h - (v - yMin) / (yMax - yMin) * plot.h;
+
+            // Grid and axis labels
+            ctx.strokeStyle = '#e5e7eb';
+            ctx.fillStyle = '#6b7280';
+            ctx.lineWidth = 1;
+            ctx.textAlign = 'right';
+            ctx.textBaseline = 'middle';
+            for (let v = yMin; v <= yMax + yStep / 2; v += yStep) {
+                const y = Math.round(yOf(v)) + 0.5;
+                ctx.beginPath();
+                ctx.moveTo(plot.x, y);
+                ctx.lineTo(plot.x + plot.w, y);
+                ctx.stroke();
+                ctx.fillText(this.options.format(v), plot.x - 6, y);
+            }
+            ctx.textAlign = 'center';
+            ctx.textBaseline = 'top';
+            const ticks = Math.max(2, Math.floor(plot.w / 110));
+            for (let i = 0; i <= ticks; i++) {
+                const t = from + (to - from) * i / ticks;
+                ctx.fillText(formatTime(t, to - from), xOf(t), plot.y + plot.h + 8);
+            }
+
+            if (!this.series.some(s => s.points.length > 0)) {
+                ctx.textBaseline = 'middle';
+                ctx.fillText('No data yet', plot.x + plot.w / 2, plot.y + plot.h / 2);
+                return;
+            }
+
+            // Lines, broken where samples are missing
+            ctx.save();
+            ctx.beginPath();
+            ctx.rect(plot.x, plot.y, plot.w, plot.h);
+            ctx.clip();
+            ctx.lineWidth = 1.5;
+            ctx.lineJoin = 'round';
+            for (const s of this.series) {
+                const gap = gapThreshold(s.points);
+                ctx.strokeStyle = s.color;
+                ctx.beginPath();
+                s.points.forEach(([t, v], i) => {
+                    if (i === 0 || t - s.points[i - 1][0] > gap) {
+                        ctx.moveTo(xOf(t), yOf(v));
+                    } else {
+                        ctx.lineTo(xOf(t), yOf(v));
+                    }
+                });
+                ctx.stroke();
+            }
+            ctx.restore();
+
+            if (this.options.legend) this.drawLegend(ctx, plot);
+            if (this.hoverX !== null && this.hoverX >= plot.x && this.hoverX <= plot.x + plot.w) {
+                this.drawTooltip(ctx, plot, from + (this.hoverX - plot.x) / plot.w * (to - from), xOf);
+            }
+        }
+
+        // yScale returns rounded bounds and a grid step for the visible data
+        yScale() {
+            let max = this.options.max;
+            if (max === null) {
+                max = 0;
+                for (const s of this.series) {
+                    for (const [, v] of s.points) max = Math.max(max, v);
+                }
+            }
+            const min = this.options.min;
+            const step = niceStep((max - min) / 4 || 1);
+            return [min, Math.max(min + step, Math.ceil(max / step) * step), step];
+        }
+
+        drawLegend(ctx, plot) {
+            ctx.textAlign = 'left';
+            ctx.textBaseline = 'middle';
+            let x = plot.x;
+            for (const s of this.series) {
+                const w = ctx.measureText(s.label).width + 24;
+                if (x + w > plot.x + plot.w) break;
+                ctx.fillStyle = s.color;
+                ctx.fillRect(x, 10, 10, 3);
+                ctx.fillStyle = '#374151';
+                ctx.fillText(s.label, x + 14, 12);
+                x += w;
+            }
+        }
+
+        // drawTooltip lists the value of each series nearest to time t,
+        // largest first and capped so charts with many series stay readable
+        drawTooltip(ctx, plot, t, xOf) {
+            const rows = [];
+            for (const s of this.series) {
+                const p = nearest(s.points, t);
+                if (p) rows.push({ label: s.label, color: s.color, t: p[0], v: p[1] });
+            }
+            if (rows.length === 0) return;
+            rows.sort((a, b) => b.v - a.v);
+            rows.length = Math.min(rows.length, 8);
+
+            const x = xOf(rows[0].t);
+            ctx.strokeStyle = '#9ca3af';
+            ctx.beginPath();
+            ctx.moveTo(Math.round(x) + 0.5, plot.y);
+            ctx.lineTo(Math.round(x) + 0.5, plot.y + plot.h);
+            ctx.stroke();
+
+            const lines = [new Date(rows[0].t).toLocaleString()]
+                .concat(rows.map(r => r.label + ': ' + this.options.format(r.v)));
+            const w = Math.max(...lines.map(l => ctx.measureText(l).width)) + 20;
+            const h = lines.length * 15 + 8;
+            const bx = x + w + 12 > plot.x + plot.w ? x - w - 8 : x + 8;
+            ctx.fillStyle = 'rgba(255, 255, 255, 0.95)';
+            ctx.strokeStyle = '#d1d5db';
+            ctx.fillRect(bx, plot.y, w, h);
+            ctx.strokeRect(bx + 0.5, plot.y + 0.5, w, h);
+            ctx.textAlign = 'left';
+            ctx.textBaseline = 'top';
+            lines.forEach((line, i) => {
+                if (i > 0) {
+                    ctx.fillStyle = rows[i - 1].color;
+                    ctx.fillRect(bx + 6, plot.y + 8 + i * 15, 6, 6);
+                }
+                ctx.fillStyle = '#111827';
+                ctx.fillText(line, bx + (i > 0 ? 16 : 6), plot.y + 5 + i * 15);
+            });
+        }
+    }
+
+    function niceStep(raw) {
+        const exp = Math.pow(10, Math.floor(Math.log10(raw)));
+        const f = raw / exp;
+        return (f <= 1 ? 1 : f <= 2 ? 2 : f <= 2.5 ? 2.5 : f <= 5 ? 5 : 10) * exp;
+    }
+
+    // gapThreshold is the spacing above which two points are not joined
+    function gapThreshold(points) {
+        const deltas = [];
+        for (let i = 1; i < points.length; i++) deltas.push(points[i][0] - points[i - 1][0]);
+        if (deltas.length === 0) return Infinity;
+        deltas.sort((a, b) => a - b);
+        return deltas[Math.floor(deltas.length / 2)] * 3;
+    }
+
+    function nearest(points, t) {
+        let best = null;
+        for (const p of points) {
+            if (best === null || Math.abs(p[0] - t) < Math.abs(best[0] - t)) best = p;
+        }
+        return best;
+    }
+
+    function formatTime(t, span) {
+        const d = new Date(t);
+        const time = d.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+        if (span <= 24 * 3600 * 1000) return time;
+        return d.toLocaleDateString([], { month: '2-digit', day: '2-digit' }) + ' ' + time;
+    }
+
+    global.TimeChart = TimeChart;
+})(window);